@@ -0,0 +1,46 @@
+package main
+
+import (
+	"Go-Microservice-Template/internal/config"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+const configUsage = `usage: server config <command> [flags]
+
+Commands:
+  print    print every setting, where its value came from, secrets redacted
+`
+
+// runConfig implements the "config" subcommand.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprint(os.Stderr, configUsage)
+		return errUsage
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), configUsage+"\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	var cfgOpts config.Options
+	cfgOpts.RegisterFlags(fs)
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(cfgOpts)
+	if err != nil {
+		return err
+	}
+
+	// One KEY=value line per setting, annotated with its source
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, s := range cfg.Settings() {
+		fmt.Fprintf(tw, "%s=%s\t# %s\n", s.Key, s.Value, s.Source)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"Go-Microservice-Template/internal/config"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// runHealthcheck implements the "healthcheck" subcommand, used as the
+// container HEALTHCHECK so that the image needs no HTTP client. It exits
+// non-zero unless the server on this host answers healthy in time.
+func runHealthcheck(args []string) error {
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	checkGRPC := fs.Bool("grpc", false, "probe the gRPC health service instead of HTTP /health")
+	timeout := fs.Duration("timeout", 3*time.Second, "how long to wait for an answer")
+	var cfgOpts config.Options
+	cfgOpts.RegisterFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(cfgOpts)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if *checkGRPC {
+		return checkGRPCHealth(ctx, fmt.Sprintf("localhost:%d", cfg.GRPCPort))
+	}
+	return checkHTTPHealth(ctx, fmt.Sprintf("http://localhost:%d/health", cfg.HTTPPort))
+}
+
+func checkHTTPHealth(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+func checkGRPCHealth(ctx context.Context, target string) error {
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("%s is %s", target, resp.GetStatus())
+	}
+	return nil
+}
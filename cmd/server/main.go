@@ -1,179 +1,212 @@
 package main
 
 import (
+	"Go-Microservice-Template/internal/auth"
 	"Go-Microservice-Template/internal/config"
 	"Go-Microservice-Template/internal/handler"
 	"Go-Microservice-Template/internal/middleware"
-	"Go-Microservice-Template/internal/repository"
-	"Go-Microservice-Template/internal/service"
-	"context"
+	"Go-Microservice-Template/internal/model"
+	"errors"
+	"flag"
 	"fmt"
-	"net"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
+	"strings"
 
-	"github.com/go-chi/chi"
-	"github.com/go-chi/cors"
+	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
-func main() {
-	// Load configuration
-	cfg, err := config.Load()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
-		os.Exit(1)
-	}
+const usage = `usage: server [command] [flags]
 
-	// Setup structured logging
-	setupLogger(cfg.LogLevel)
-	log.Info().Str("version", cfg.Version).Msg("starting microservice")
+Commands:
+  serve          start the HTTP and gRPC servers (the default)
+  migrate        apply, roll back or list database migrations
+  create-admin   create an account with the admin role
+  config print   print the resolved configuration with secrets redacted
+  healthcheck    exit non-zero unless the local server reports healthy
 
-	// Initialize dependencies
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+Run "server <command> -h" for the flags of a command.
+`
 
-	// Database connection
-	db, err := repository.NewPostgresPool(ctx, cfg.DatabaseURL())
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to connect to database")
-	}
+// errUsage is returned by subcommands for invalid arguments that have
+// already been explained on stderr.
+var errUsage = errors.New("invalid usage")
 
-	// Redis connection
-	cache, err := repository.NewRedisClient(ctx, cfg.RedisURL())
-	if err != nil {
-		log.Warn().Err(err).Msg("failed to connect to Redis, continuing without cache")
-	} else {
-		defer cache.Close()
-		log.Info().Msg("connected to Redis")
-	}
-	// Build layers (Dependency Injection)
-	userRepo := repository.NewUserRepository(db)
-	userCache := repository.NewUserCache(cache, 5*time.Minute)
-	userService := service.NewUserService(userRepo, userCache)
-	httpHandler := handler.NewHTTPHandler(userService)
-	grpcHandler := handler.NewGRPCHandler(userService)
-
-	// ── HTTP Server ──────────────────────────────────────
-	router := setupHTTPRouter(cfg, httpHandler)
-	httpServer := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.HTTPPort),
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+func main() {
+	// Without a command the binary serves, as it always has
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	// ── gRPC Server ──────────────────────────────────────
-	grpcServer := setupGRPCServer(cfg, grpcHandler)
-
-	// ── Start servers ────────────────────────────────────
-	errChan := make(chan error, 2)
-
-	// Start HTTP
-	go func() {
-		log.Info().Int("port", cfg.HTTPPort).Msg("HTTP server starting")
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errChan <- fmt.Errorf("HTTP server error: %w", err)
-		}
-	}()
+	var run func(args []string) error
+	switch command {
+	case "serve":
+		run = runServe
+	case "migrate":
+		run = runMigrate
+	case "create-admin":
+		run = runCreateAdmin
+	case "config":
+		run = runConfig
+	case "healthcheck":
+		run = runHealthcheck
+	case "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
 
-	// Start gRPC
-	go func() {
-		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
-		if err != nil {
-			errChan <- fmt.Errorf("gRPC listen error: %w", err)
+	if err := run(args); err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
 			return
+		case errors.Is(err, errUsage):
+			os.Exit(2)
 		}
-		log.Info().Int("port", cfg.GRPCPort).Msg("gRPC server starting")
-		if err := grpcServer.Serve(lis); err != nil {
-			errChan <- fmt.Errorf("gRPC server error: %w", err)
-		}
-	}()
-
-	// ── Graceful Shutdown ────────────────────────────────
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
-	select {
-	case sig := <-quit:
-		log.Info().Str("signal", sig.String()).Msg("shutting down gracefully")
-	case err := <-errChan:
-		log.Error().Err(err).Msg("server error, shutting down")
+		fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+		os.Exit(1)
 	}
+}
 
-	// Give active connections 30 seconds to finish
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer shutdownCancel()
-
-	// Shutdown HTTP
-	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-		log.Error().Err(err).Msg("HTTP server forced shutdown")
+// parseFlags parses args into fs, which has already reported any error.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
 	}
-
-	// Shutdown gRPC
-	grpcServer.GracefulStop()
-
-	log.Info().Msg("server stopped cleanly")
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return errUsage
+	}
+	return nil
 }
 
-func setupLogger(level string) {
+func setupLogger(cfg *config.Config) {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
+	setLogLevel(cfg.LogLevel)
+
+	// Pretty logging for development, JSON for production
+	if cfg.Env != "production" {
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
+	}
+}
+
+// setLogLevel applies level, which has been validated by config.Load.
+func setLogLevel(level string) {
 	lvl, err := zerolog.ParseLevel(level)
 	if err != nil {
 		lvl = zerolog.InfoLevel
 	}
 	zerolog.SetGlobalLevel(lvl)
-
-	// Pretty logging for development, JSON for production
-	if os.Getenv("APP_ENV") != "production" {
-		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
-	}
 }
 
-func setupHTTPRouter(cfg *config.Config, h *handler.HTTPHandler) *chi.Mux {
+func setupHTTPRouter(
+	h *handler.HTTPHandler,
+	issuer *auth.Issuer,
+	revocations middleware.TokenRevocationChecker,
+	apiKeys middleware.APIKeyAuthenticator,
+	policies *httpPolicies,
+) *chi.Mux {
 	r := chi.NewRouter()
 
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
+	r.Use(middleware.TraceMiddleware)
+	r.Use(policies.clientIP.Handler)
+	r.Use(policies.cors.Handler)
 
 	// Health & metrics (public)
 	r.Get("/health", h.Health)
 	r.Get("/readiness", h.Readiness)
 	r.Get("/metrics", h.Metrics)
+	r.Get("/.well-known/jwks.json", h.JWKS)
 
 	r.Route("/api/v1", func(r chi.Router) {
 		// Public routes
-		r.Post("/auth/login", h.Login)
-		r.Post("/auth/register", h.Register)
+		r.Group(func(r chi.Router) {
+			r.Use(policies.publicLimit.Handler)
+
+			r.Post("/auth/login", h.Login)
+			r.Post("/auth/register", h.Register)
+			r.Post("/auth/refresh", h.Refresh)
+			r.Post("/auth/mfa/verify", h.VerifyMFA)
+			r.Get("/auth/oidc/{provider}/login", h.OIDCLogin)
+			r.Get("/auth/oidc/{provider}/callback", h.OIDCCallback)
+			r.Post("/auth/password/forgot", h.ForgotPassword)
+			r.Post("/auth/password/reset", h.ResetPassword)
+			r.Get("/auth/verify", h.VerifyEmail)
+			r.Post("/auth/verify", h.VerifyEmail)
+			r.Post("/auth/verify/resend", h.ResendVerification)
+		})
 
 		// Protected routes
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.JWTAuthMiddleware(cfg.JWTSecret))
-			r.Use(middleware.RateLimitMiddleware(100, time.Minute))
+			r.Use(middleware.JWTAuthMiddleware(issuer, revocations, apiKeys))
+			r.Use(policies.protectedLimit.Handler)
+
+			r.Post("/auth/logout", h.Logout)
+
+			r.Route("/me", func(r chi.Router) {
+				r.With(middleware.RequireAPIKeyPermission(model.PermMeRead)).Get("/", h.GetMe)
+
+				// Email, password and MFA changes need an interactive login
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.RejectAPIKeys)
+					r.Put("/", h.UpdateMe)
+					r.Post("/password", h.ChangePassword)
+					r.Post("/mfa/enroll", h.EnrollMFA)
+					r.Post("/mfa/enable", h.EnableMFA)
+					r.Post("/mfa/disable", h.DisableMFA)
+				})
+			})
 
 			r.Route("/users", func(r chi.Router) {
-				r.Get("/", h.ListUsers)
-				r.Post("/", h.CreateUser)
+				r.With(middleware.RequirePermission(model.PermUsersRead)).Get("/", h.ListUsers)
+				r.With(middleware.RequirePermission(model.PermUsersWrite)).Post("/", h.CreateUser)
 				r.Route("/{id}", func(r chi.Router) {
-					r.Get("/", h.GetUser)
-					r.Put("/", h.UpdateUser)
-					r.Delete("/", h.DeleteUser)
+					// Users may read and edit their own account; admins manage
+					// everyone. API keys are limited to their scopes throughout
+					r.With(middleware.RequireSelfOrPermission(model.PermUsersRead, userIDParam)).Get("/", h.GetUser)
+					r.With(middleware.RequireSelfOrPermission(model.PermUsersWrite, userIDParam)).Put("/", h.UpdateUser)
+					r.With(middleware.RequirePermission(model.PermUsersDelete)).Delete("/", h.DeleteUser)
+					r.With(middleware.RequirePermission(model.PermSessionsRevoke)).Delete("/sessions", h.RevokeSessions)
+					r.With(middleware.RequirePermission(model.PermUsersWrite)).Post("/unlock", h.UnlockUser)
+
+					r.With(middleware.RequireSelfOrPermission(model.PermRolesRead, userIDParam)).Get("/roles", h.ListUserRoles)
+					r.With(middleware.RequirePermission(model.PermRolesWrite)).Post("/roles", h.AssignRole)
+					r.With(middleware.RequirePermission(model.PermRolesWrite)).Delete("/roles/{role}", h.UnassignRole)
+
+					r.With(middleware.RequireSelfOrPermission(model.PermUsersRead, userIDParam)).Get("/api-keys", h.ListAPIKeys)
+					r.With(middleware.RejectAPIKeys, middleware.RequireSelfOrPermission(model.PermUsersWrite, userIDParam)).Post("/api-keys", h.CreateAPIKey)
+					r.With(middleware.RequireSelfOrPermission(model.PermUsersWrite, userIDParam)).Delete("/api-keys/{keyID}", h.RevokeAPIKey)
+				})
+			})
+
+			r.Route("/roles", func(r chi.Router) {
+				r.With(middleware.RequirePermission(model.PermRolesRead)).Get("/", h.ListRoles)
+				r.With(middleware.RequirePermission(model.PermRolesWrite)).Post("/", h.CreateRole)
+				r.Route("/{name}", func(r chi.Router) {
+					r.With(middleware.RequirePermission(model.PermRolesRead)).Get("/", h.GetRole)
+					r.With(middleware.RequirePermission(model.PermRolesWrite)).Put("/permissions", h.SetRolePermissions)
+					r.With(middleware.RequirePermission(model.PermRolesWrite)).Delete("/", h.DeleteRole)
 				})
 			})
+
+			r.Route("/permissions", func(r chi.Router) {
+				r.With(middleware.RequirePermission(model.PermRolesRead)).Get("/", h.ListPermissions)
+				r.With(middleware.RequirePermission(model.PermRolesWrite)).Post("/", h.CreatePermission)
+			})
 		})
 
 	})
@@ -181,16 +214,42 @@ func setupHTTPRouter(cfg *config.Config, h *handler.HTTPHandler) *chi.Mux {
 	return r
 }
 
-func setupGRPCServer(cfg *config.Config, h *handler.GRPCHandler) *grpc.Server {
+// userIDParam extracts the target user ID from /users/{id} routes.
+func userIDParam(r *http.Request) string {
+	return chi.URLParam(r, "id")
+}
+
+func setupGRPCServer(
+	h *handler.GRPCHandler,
+	rh *handler.GRPCRoleHandler,
+	issuer *auth.Issuer,
+	revocations middleware.TokenRevocationChecker,
+	apiKeys middleware.APIKeyAuthenticator,
+) *grpc.Server {
+	rules := h.MethodRules()
+	for method, rule := range rh.MethodRules() {
+		rules[method] = rule
+	}
+	// Probed by "server healthcheck -grpc" and orchestrators
+	rules[healthpb.Health_Check_FullMethodName] = middleware.GRPCMethodRule{Public: true}
+
 	opts := []grpc.ServerOption{
 		grpc.MaxRecvMsgSize(4 * 1024 * 1024), // 4MB
-
+		grpc.ChainUnaryInterceptor(
+			middleware.GRPCTraceInterceptor(),
+			middleware.GRPCLoggingInterceptor(),
+			middleware.GRPCAuthInterceptor(issuer, revocations, apiKeys, rules),
+			middleware.GRPCAuthorizationInterceptor(rules),
+		),
 	}
 
 	server := grpc.NewServer(opts...)
 
 	// Register services
 	h.Register(server)
+	rh.Register(server)
+
+	healthpb.RegisterHealthServer(server, health.NewServer())
 
 	// Enable reflection for debugging
 	reflection.Register(server)
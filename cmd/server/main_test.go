@@ -0,0 +1,152 @@
+package main
+
+import (
+	pb "Go-Microservice-Template/api/user"
+	"Go-Microservice-Template/internal/auth"
+	"Go-Microservice-Template/internal/config"
+	"Go-Microservice-Template/internal/handler"
+	"Go-Microservice-Template/internal/middleware"
+	"Go-Microservice-Template/internal/model"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeAPIKeys authenticates every key as a key of owner, a regular user,
+// with scopes.
+type fakeAPIKeys struct {
+	owner  uuid.UUID
+	scopes []model.Permission
+}
+
+func (f fakeAPIKeys) AuthenticateAPIKey(context.Context, string) (*model.APIKeyPrincipal, error) {
+	return &model.APIKeyPrincipal{KeyID: uuid.New(), UserID: f.owner, Role: model.RoleUser, Permissions: f.scopes}, nil
+}
+
+// noRevocations never reports a token as revoked.
+type noRevocations struct{}
+
+func (noRevocations) IsTokenRevoked(context.Context, string, uuid.UUID, time.Time) (bool, error) {
+	return false, nil
+}
+
+func testIssuer(t *testing.T) *auth.Issuer {
+	t.Helper()
+	keys, err := auth.NewKeySet(nil, "", "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth.NewIssuer(keys, time.Hour, 24*time.Hour)
+}
+
+func TestReadOnlyAPIKeyCannotTakeOverAccount(t *testing.T) {
+	owner := uuid.New()
+	issuer := testIssuer(t)
+	cfg := &config.Config{
+		CORSAllowedOrigins: []string{"*"},
+		RateLimitPublic:    1000,
+		RateLimitProtected: 1000,
+		RateLimitWindow:    time.Minute,
+	}
+	// The services are never reached: every request must stop at the router
+	router := setupHTTPRouter(
+		handler.NewHTTPHandler(nil, nil, nil, issuer),
+		issuer,
+		noRevocations{},
+		fakeAPIKeys{owner: owner, scopes: []model.Permission{model.PermMeRead}},
+		newHTTPPolicies(cfg),
+	)
+
+	self := "/api/v1/users/" + owner.String()
+	tests := []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, self + "/api-keys", `{"name":"escalated","scopes":["users:write"]}`},
+		{http.MethodPut, self, `{"email":"attacker@example.com"}`},
+		{http.MethodPut, "/api/v1/me", `{"email":"attacker@example.com"}`},
+		{http.MethodPost, "/api/v1/me/password", `{"current_password":"x","new_password":"y"}`},
+		{http.MethodPost, "/api/v1/me/mfa/enroll", `{}`},
+		{http.MethodPost, "/api/v1/me/mfa/enable", `{"code":"123456"}`},
+		{http.MethodPost, "/api/v1/me/mfa/disable", `{"code":"123456"}`},
+		{http.MethodDelete, self + "/api-keys/" + uuid.NewString(), ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-API-Key", "sk_test")
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d; body: %s", rec.Code, http.StatusForbidden, rec.Body)
+			}
+		})
+	}
+}
+
+func TestReadOnlyAPIKeyGRPCRules(t *testing.T) {
+	owner := uuid.New()
+	issuer := testIssuer(t)
+	h := handler.NewGRPCHandler(nil)
+	rules := h.MethodRules()
+
+	// Authentication and authorization chained as in setupGRPCServer
+	authn := middleware.GRPCAuthInterceptor(issuer, noRevocations{},
+		fakeAPIKeys{owner: owner, scopes: []model.Permission{model.PermMeRead}}, rules)
+	authz := middleware.GRPCAuthorizationInterceptor(rules)
+	call := func(method string, req interface{}) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "sk_test"))
+		_, err := authn(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return authz(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, interface{}) (interface{}, error) {
+				return "ok", nil
+			})
+		})
+		return err
+	}
+
+	email := "attacker@example.com"
+	tests := []struct {
+		name   string
+		method string
+		req    interface{}
+		want   codes.Code
+	}{
+		{"get me", pb.UserService_GetMe_FullMethodName, &emptypb.Empty{}, codes.OK},
+		{"get self needs users:read", pb.UserService_GetUser_FullMethodName, &pb.GetUserRequest{Id: owner.String()}, codes.PermissionDenied},
+		{"update me", pb.UserService_UpdateMe_FullMethodName, &pb.UpdateMeRequest{}, codes.PermissionDenied},
+		{"change password", pb.UserService_ChangePassword_FullMethodName, &pb.ChangePasswordRequest{}, codes.PermissionDenied},
+		{"update self", pb.UserService_UpdateUser_FullMethodName, &pb.UpdateUserRequest{Id: owner.String(), Email: &email}, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(call(tt.method, tt.req)); got != tt.want {
+				t.Errorf("code = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyCannotChangeEmailThroughGRPCHandler(t *testing.T) {
+	owner := uuid.New()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, owner.String())
+	ctx = context.WithValue(ctx, middleware.APIKeyIDKey, uuid.NewString())
+	ctx = context.WithValue(ctx, middleware.PermissionsKey, []model.Permission{model.PermUsersWrite})
+
+	email := "attacker@example.com"
+	_, err := handler.NewGRPCHandler(nil).UpdateUser(ctx, &pb.UpdateUserRequest{Id: owner.String(), Email: &email})
+	if got := status.Code(err); got != codes.PermissionDenied {
+		t.Errorf("code = %s, want %s", got, codes.PermissionDenied)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"Go-Microservice-Template/internal/config"
+	"Go-Microservice-Template/internal/migrate"
+	"Go-Microservice-Template/internal/repository"
+	"Go-Microservice-Template/migrations"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `usage: server migrate <command> [flags]
+
+Commands:
+  up                 apply every pending migration
+  down [-steps N]    roll back the latest N migrations (default 1)
+  status             list migrations and whether they are applied
+`
+
+// runMigrate implements the "migrate" subcommand against the configured
+// database, using the migrations embedded in the binary.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return errUsage
+	}
+	command := args[0]
+
+	fs := flag.NewFlagSet("migrate "+command, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage+"\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	var cfgOpts config.Options
+	cfgOpts.RegisterFlags(fs)
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return errors.New("-steps must be at least 1")
+	}
+
+	cfg, err := config.Load(cfgOpts)
+	if err != nil {
+		return err
+	}
+	setupLogger(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := repository.NewPostgresPool(ctx, cfg.DatabaseURL(), nil)
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", len(applied))
+
+	case "down":
+		rolledBack, err := m.Down(ctx, *steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %d migration(s)\n", len(rolledBack))
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(statuses)
+
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	return nil
+}
+
+func printMigrationStatus(statuses []migrate.Status) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", "-"
+		if s.AppliedAt != nil {
+			state, appliedAt = "applied", s.AppliedAt.Local().Format(time.RFC3339)
+		}
+		switch {
+		case s.Unknown:
+			state = "unknown"
+		case s.Modified:
+			state = "modified"
+		}
+		fmt.Fprintf(tw, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	tw.Flush()
+}
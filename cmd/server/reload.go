@@ -0,0 +1,239 @@
+package main
+
+import (
+	"Go-Microservice-Template/internal/auth"
+	"Go-Microservice-Template/internal/config"
+	"Go-Microservice-Template/internal/middleware"
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/go-chi/cors"
+	"github.com/rs/zerolog/log"
+)
+
+// reloadable lists the settings a reload applies while serving. Changes to
+// any other setting are logged and take effect after a restart.
+var reloadable = map[string]bool{
+	"LOG_LEVEL":                true,
+	"CORS_ALLOWED_ORIGINS":     true,
+	"RATE_LIMIT_PUBLIC":        true,
+	"RATE_LIMIT_PROTECTED":     true,
+	"RATE_LIMIT_WINDOW":        true,
+	"TRUSTED_PROXIES":          true,
+	"CACHE_TTL":                true,
+	"JWT_SECRET":               true,
+	"JWT_SIGNING_KEYS":         true,
+	"JWT_ACTIVE_KEY_ID":        true,
+	"DB_PASSWORD":              true, // used for new connections
+	"REDIS_PASSWORD":           true,
+	"SECRETS_REFRESH_INTERVAL": true,
+}
+
+// ── HTTP Policies ─────────────────────────────────────────
+
+// httpPolicies are the middlewares of the HTTP router that follow
+// configuration reloads.
+type httpPolicies struct {
+	clientIP       *middleware.ClientIPResolver
+	cors           *middleware.CORS
+	publicLimit    *middleware.RateLimiter
+	protectedLimit *middleware.RateLimiter
+}
+
+func newHTTPPolicies(cfg *config.Config) *httpPolicies {
+	return &httpPolicies{
+		clientIP: middleware.NewClientIPResolver(cfg.TrustedProxyPrefixes()),
+		cors: middleware.NewCORS(cors.Options{
+			AllowedOrigins:   cfg.CORSAllowedOrigins,
+			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key", "X-Request-ID", "traceparent"},
+			ExposedHeaders:   []string{"Link", "Retry-After", "X-Request-ID"},
+			AllowCredentials: true,
+			MaxAge:           300,
+		}),
+		publicLimit:    middleware.NewRateLimiter(cfg.RateLimitPublic, cfg.RateLimitWindow),
+		protectedLimit: middleware.NewRateLimiter(cfg.RateLimitProtected, cfg.RateLimitWindow),
+	}
+}
+
+func (p *httpPolicies) apply(cfg *config.Config) {
+	p.clientIP.SetTrustedProxies(cfg.TrustedProxyPrefixes())
+	p.cors.SetAllowedOrigins(cfg.CORSAllowedOrigins)
+	p.publicLimit.SetLimit(cfg.RateLimitPublic, cfg.RateLimitWindow)
+	p.protectedLimit.SetLimit(cfg.RateLimitProtected, cfg.RateLimitWindow)
+}
+
+// ── Reloading ─────────────────────────────────────────────
+
+// reloader keeps the running server in line with its configuration: it
+// reloads the configuration on SIGHUP and applies rotated secrets.
+type reloader struct {
+	opts     config.Options
+	svc      *services
+	policies *httpPolicies
+
+	mu sync.Mutex
+	// cfg is the configuration last loaded, including changes that only
+	// take effect after a restart.
+	cfg          *config.Config
+	secrets      *config.SecretWatcher
+	stopSecrets  context.CancelFunc
+	stopReloads  chan struct{}
+	reloadSignal chan os.Signal
+}
+
+// newReloader creates a reloader for cfg, loaded from opts. Secrets are
+// available from it at once; nothing is reloaded until start.
+func newReloader(opts config.Options, cfg *config.Config) *reloader {
+	return &reloader{opts: opts, cfg: cfg, secrets: cfg.WatchSecrets()}
+}
+
+// secret returns the current value of the secret setting key.
+func (r *reloader) secret(key string) string {
+	r.mu.Lock()
+	secrets := r.secrets
+	r.mu.Unlock()
+	return secrets.Value(key)
+}
+
+// start begins refreshing secrets and reloading on SIGHUP, applying both to
+// svc and policies, until stop is called.
+func (r *reloader) start(svc *services, policies *httpPolicies) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.svc = svc
+	r.policies = policies
+	r.watchSecrets()
+
+	r.stopReloads = make(chan struct{})
+	r.reloadSignal = make(chan os.Signal, 1)
+	signal.Notify(r.reloadSignal, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-r.stopReloads:
+				return
+			case <-r.reloadSignal:
+				log.Info().Msg("SIGHUP received, reloading configuration")
+				r.reload()
+			}
+		}
+	}()
+}
+
+func (r *reloader) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	signal.Stop(r.reloadSignal)
+	close(r.stopReloads)
+	r.stopSecrets()
+}
+
+// watchSecrets starts r.secrets refreshing. r.mu must be held.
+func (r *reloader) watchSecrets() {
+	secrets := r.secrets
+	secrets.OnChange(func(changed []string) { r.secretsRotated(secrets, changed) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.stopSecrets = cancel
+	go secrets.Run(ctx)
+}
+
+// reload loads the configuration again and applies the reloadable changes.
+// An invalid configuration is rejected as a whole, keeping the current one.
+func (r *reloader) reload() {
+	next, err := config.Load(r.opts)
+	if err != nil {
+		log.Error().Err(err).Msg("rejected configuration reload, keeping the current configuration")
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changes := r.cfg.Diff(next)
+	if len(changes) == 0 {
+		log.Info().Msg("configuration unchanged")
+		return
+	}
+
+	reloadKeys := false
+	for _, c := range changes {
+		reloadKeys = reloadKeys || strings.HasPrefix(c.Key, "JWT_") && reloadable[c.Key]
+	}
+
+	// Everything that can fail is done before anything is applied
+	var keys *auth.KeySet
+	if reloadKeys {
+		if keys, err = newKeySet(next, next.JWTSecret); err != nil {
+			log.Error().Err(err).Msg("rejected configuration reload, keeping the current configuration")
+			return
+		}
+	}
+
+	for _, c := range changes {
+		event := log.Info()
+		msg := "configuration changed"
+		if !reloadable[c.Key] {
+			event = log.Warn()
+			msg = "configuration change takes effect after a restart"
+		}
+		event.Str("key", c.Key).Str("old", c.Old).Str("new", c.New).Msg(msg)
+	}
+
+	setLogLevel(next.LogLevel)
+	r.policies.apply(next)
+	r.svc.userCache.SetTTL(next.CacheTTL)
+	if keys != nil {
+		r.svc.issuer.SetKeys(keys)
+	}
+
+	// Secrets are read from the new references and intervals from now on
+	r.stopSecrets()
+	r.cfg = next
+	r.secrets = next.WatchSecrets()
+	r.watchSecrets()
+
+	log.Info().Int("changes", len(changes)).Msg("configuration reloaded")
+}
+
+// secretsRotated puts secrets rotated in secrets into effect. Database and
+// Redis passwords need nothing: new connections ask for them.
+func (r *reloader) secretsRotated(secrets *config.SecretWatcher, changed []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if secrets != r.secrets {
+		// Replaced by a reload, which read the secrets again
+		return
+	}
+
+	reloadKeys := false
+	for _, key := range changed {
+		switch {
+		case key == "DB_PASSWORD", key == "REDIS_PASSWORD":
+			log.Info().Str("secret", key).Msg("new connections use the rotated password")
+		case key == "JWT_SECRET", strings.HasPrefix(key, config.SigningKeySecret("")):
+			reloadKeys = true
+		default:
+			log.Warn().Str("secret", key).Msg("rotated secret takes effect after a restart")
+		}
+	}
+	if !reloadKeys {
+		return
+	}
+
+	keys, err := newKeySet(r.cfg, secrets.Value("JWT_SECRET"))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to reload JWT signing keys, keeping the current ones")
+		return
+	}
+	r.svc.issuer.SetKeys(keys)
+	log.Info().Msg("reloaded JWT signing keys")
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"Go-Microservice-Template/internal/auth"
+	"Go-Microservice-Template/internal/config"
+	"Go-Microservice-Template/internal/handler"
+	"Go-Microservice-Template/internal/notify"
+	"Go-Microservice-Template/internal/repository"
+	"Go-Microservice-Template/internal/service"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+)
+
+// runServe implements the "serve" subcommand: it starts the HTTP and gRPC
+// servers, or only one of them, and shuts them down on SIGINT or SIGTERM.
+// SIGHUP reloads the configuration.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	httpOnly := fs.Bool("http-only", false, "serve only the HTTP API")
+	grpcOnly := fs.Bool("grpc-only", false, "serve only the gRPC API")
+	var cfgOpts config.Options
+	cfgOpts.RegisterFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *httpOnly && *grpcOnly {
+		return errors.New("-http-only and -grpc-only are mutually exclusive")
+	}
+
+	// Load configuration
+	cfg, err := config.Load(cfgOpts)
+	if err != nil {
+		return err
+	}
+
+	// Setup structured logging
+	setupLogger(cfg)
+	log.Info().Str("version", cfg.Version).Msg("starting microservice")
+	log.Debug().Stringer("config", cfg).Msg("resolved configuration")
+
+	// Secrets read from files are re-read while running and the
+	// configuration on SIGHUP; connections and keys use the current values
+	reloads := newReloader(cfgOpts, cfg)
+
+	// Initialize dependencies
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Database connection
+	db, err := repository.NewPostgresPool(ctx, cfg.DatabaseURL(), func() string { return reloads.secret("DB_PASSWORD") })
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	defer db.Close()
+
+	// Redis connection
+	cache, err := repository.NewRedisClient(ctx, cfg.RedisURL(), func() string { return reloads.secret("REDIS_PASSWORD") })
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to connect to Redis, continuing without cache")
+	} else {
+		defer cache.Close()
+		log.Info().Msg("connected to Redis")
+	}
+
+	svc, err := newServices(cfg, db, cache)
+	if err != nil {
+		return err
+	}
+
+	policies := newHTTPPolicies(cfg)
+	reloads.start(svc, policies)
+	defer reloads.stop()
+
+	// ── Start servers ────────────────────────────────────
+	errChan := make(chan error, 2)
+
+	var httpServer *http.Server
+	if !*grpcOnly {
+		httpHandler := handler.NewHTTPHandler(svc.users, svc.roles, svc.apiKeys, svc.issuer)
+		httpServer = &http.Server{
+			Addr:         fmt.Sprintf(":%d", cfg.HTTPPort),
+			Handler:      setupHTTPRouter(httpHandler, svc.issuer, svc.users, svc.apiKeys, policies),
+			ReadTimeout:  cfg.HTTPReadTimeout,
+			WriteTimeout: cfg.HTTPWriteTimeout,
+			IdleTimeout:  cfg.HTTPIdleTimeout,
+		}
+
+		go func() {
+			log.Info().Int("port", cfg.HTTPPort).Msg("HTTP server starting")
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errChan <- fmt.Errorf("HTTP server error: %w", err)
+			}
+		}()
+	}
+
+	var grpcServer *grpc.Server
+	if !*httpOnly {
+		grpcServer = setupGRPCServer(
+			handler.NewGRPCHandler(svc.users),
+			handler.NewGRPCRoleHandler(svc.roles),
+			svc.issuer, svc.users, svc.apiKeys,
+		)
+
+		go func() {
+			lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+			if err != nil {
+				errChan <- fmt.Errorf("gRPC listen error: %w", err)
+				return
+			}
+			log.Info().Int("port", cfg.GRPCPort).Msg("gRPC server starting")
+			if err := grpcServer.Serve(lis); err != nil {
+				errChan <- fmt.Errorf("gRPC server error: %w", err)
+			}
+		}()
+	}
+
+	// ── Graceful Shutdown ────────────────────────────────
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	var serveErr error
+	select {
+	case sig := <-quit:
+		log.Info().Str("signal", sig.String()).Msg("shutting down gracefully")
+	case serveErr = <-errChan:
+		log.Error().Err(serveErr).Msg("server error, shutting down")
+	}
+
+	// Give active connections time to finish
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer shutdownCancel()
+
+	if httpServer != nil {
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("HTTP server forced shutdown")
+		}
+	}
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+
+	log.Info().Msg("server stopped cleanly")
+	return serveErr
+}
+
+// services holds the application layer shared by the subcommands.
+type services struct {
+	issuer    *auth.Issuer
+	userCache repository.UserCache
+	users     service.UserService
+	roles     service.RoleService
+	apiKeys   service.APIKeyService
+}
+
+// newServices builds the repositories and services (dependency injection).
+// cache may be nil, in which case Redis-backed features degrade as they do
+// when Redis is unreachable.
+func newServices(cfg *config.Config, db *pgxpool.Pool, cache *redis.Client) (*services, error) {
+	keys, err := newKeySet(cfg, cfg.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	issuer := auth.NewIssuer(
+		keys,
+		cfg.JWTExpiration,
+		cfg.JWTRefreshExpiration,
+	)
+
+	notifier, err := notify.New(cfg.Notifier, cfg.NotifierFile)
+	if err != nil {
+		return nil, fmt.Errorf("create notifier: %w", err)
+	}
+
+	var breached *service.BreachedPasswords
+	if cfg.PasswordBreachedFile != "" {
+		breached, err = service.LoadBreachedPasswords(cfg.PasswordBreachedFile)
+		if err != nil {
+			return nil, fmt.Errorf("load breached password list: %w", err)
+		}
+		log.Info().Int("entries", breached.Len()).Msg("loaded breached password list")
+	}
+
+	hasher, err := auth.NewPasswordHasher(auth.PasswordHashConfig{
+		Algorithm:         cfg.PasswordHashAlgorithm,
+		BcryptCost:        cfg.PasswordBcryptCost,
+		Argon2Memory:      uint32(cfg.PasswordArgon2Memory),
+		Argon2Iterations:  uint32(cfg.PasswordArgon2Time),
+		Argon2Parallelism: uint8(cfg.PasswordArgon2Threads),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("configure password hashing: %w", err)
+	}
+
+	mfaKeys, err := auth.NewMFAKeys(cfg.MFASecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("configure mfa keys: %w", err)
+	}
+
+	userRepo := repository.NewUserRepository(db)
+	userCache := repository.NewUserCache(cache, cfg.CacheTTL)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	tokenDenylist := repository.NewTokenDenylist(cache, db)
+	roleRepo := repository.NewRoleRepository(db)
+	actionTokenRepo := repository.NewActionTokenRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	oidcProviders := make([]*auth.OIDCProvider, 0, len(cfg.OIDCProviders))
+	for _, p := range cfg.OIDCProviders {
+		oidcProviders = append(oidcProviders, auth.NewOIDCProvider(auth.OIDCProviderConfig{
+			Name:         p.Name,
+			IssuerURL:    p.IssuerURL,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		}))
+	}
+	loginGuard := service.NewLoginGuard(repository.NewLoginAttemptStore(cache, cfg.LoginMemoryEntries), service.LockoutPolicy{
+		MaxAttempts:     cfg.LoginMaxAttempts,
+		IPMaxAttempts:   cfg.LoginIPMaxAttempts,
+		LockoutDuration: cfg.LoginLockout,
+		BaseDelay:       time.Second,
+	})
+	userService := service.NewUserService(
+		userRepo,
+		userCache,
+		refreshTokenRepo,
+		tokenDenylist,
+		roleRepo,
+		actionTokenRepo,
+		mfaRepo,
+		identityRepo,
+		apiKeyRepo,
+		oidcProviders,
+		issuer,
+		hasher,
+		mfaKeys,
+		loginGuard,
+		notifier,
+		service.AccountOptions{
+			PasswordResetTTL:     cfg.PasswordResetTTL,
+			EmailVerificationTTL: cfg.EmailVerificationTTL,
+			VerifyURL:            cfg.PublicURL + "/api/v1/auth/verify",
+			RequireVerifiedEmail: cfg.RequireEmailVerification,
+			MFAIssuer:            cfg.MFAIssuer,
+			MFAChallengeTTL:      cfg.MFAChallengeTTL,
+			PasswordPolicy: service.PasswordPolicy{
+				MinLength:     cfg.PasswordMinLength,
+				MaxBytes:      cfg.PasswordMaxBytes,
+				RequireUpper:  cfg.PasswordRequireUpper,
+				RequireLower:  cfg.PasswordRequireLower,
+				RequireDigit:  cfg.PasswordRequireDigit,
+				RequireSymbol: cfg.PasswordRequireSymbol,
+				Breached:      breached,
+			},
+		},
+	)
+
+	return &services{
+		issuer:    issuer,
+		userCache: userCache,
+		users:     userService,
+		roles:     service.NewRoleService(roleRepo, userRepo, userCache),
+		apiKeys:   service.NewAPIKeyService(apiKeyRepo, userRepo, roleRepo),
+	}, nil
+}
+
+// newKeySet loads the token signing keys, using secret when no key files
+// are configured.
+func newKeySet(cfg *config.Config, secret string) (*auth.KeySet, error) {
+	signingKeys := make([]auth.KeyConfig, 0, len(cfg.JWTSigningKeys))
+	for _, k := range cfg.JWTSigningKeys {
+		signingKeys = append(signingKeys, auth.KeyConfig{ID: k.ID, Path: k.Path})
+	}
+	keys, err := auth.NewKeySet(signingKeys, cfg.JWTActiveKeyID, secret)
+	if err != nil {
+		return nil, fmt.Errorf("load JWT signing keys: %w", err)
+	}
+	return keys, nil
+}
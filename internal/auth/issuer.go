@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidToken is returned by Verify for malformed, expired or badly
+// signed tokens.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// PurposeMFAChallenge marks tokens that prove the password step of a
+// two-step login and can only be exchanged for a session once the second
+// factor is verified.
+const PurposeMFAChallenge = "mfa_challenge"
+
+// challengeType is the "typ" header of challenge tokens. Access tokens keep
+// the default "JWT".
+const challengeType = "challenge+jwt"
+
+// Claims are the claims carried by every access token. Purpose is empty for
+// access tokens and set for single-purpose challenge tokens, which also
+// carry it as their audience.
+type Claims struct {
+	Email       string   `json:"email"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"perms,omitempty"`
+	Purpose     string   `json:"purpose,omitempty"`
+	// IssuedAtMicros is the issue time in Unix microseconds. "iat" keeps
+	// whole seconds, as verifiers treating it as an integer expect.
+	IssuedAtMicros int64 `json:"iat_us,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IssueTime returns when the token was issued, to the microsecond if it
+// carries "iat_us". Revoking a user's sessions compares against it, so that
+// a session started within the same second right after is kept.
+func (c *Claims) IssueTime() time.Time {
+	if c.IssuedAtMicros != 0 {
+		return time.UnixMicro(c.IssuedAtMicros)
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.Time
+	}
+	return time.Time{}
+}
+
+// Issuer issues and verifies access tokens. It is built once from config and
+// shared by the service layer, which signs, and the middleware, which
+// verifies, so both always agree on keys and lifetimes.
+type Issuer struct {
+	mu   sync.RWMutex
+	keys *KeySet
+	// retired still verify the tokens they signed before being replaced by
+	// SetKeys, until those have expired, so that rotating keys signs nobody
+	// out. Newest first.
+	retired []retiredKeys
+	// challengeTTL is the longest lifetime of a challenge token issued so far.
+	challengeTTL time.Duration
+
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// retiredKeys is a key set replaced by SetKeys, accepted until every token
+// it signed has expired.
+type retiredKeys struct {
+	keys  *KeySet
+	until time.Time
+}
+
+// NewIssuer creates an Issuer signing with keys. accessTTL bounds access
+// tokens; refreshTTL is exposed for the refresh token store.
+func NewIssuer(keys *KeySet, accessTTL, refreshTTL time.Duration) *Issuer {
+	return &Issuer{keys: keys, accessTTL: accessTTL, refreshTTL: refreshTTL}
+}
+
+// SetKeys replaces the key set, e.g. after a key or secret was rotated. New
+// tokens are signed with keys at once; the replaced set keeps verifying the
+// tokens it signed until the last of them has expired, however often keys
+// are rotated meanwhile.
+func (i *Issuer) SetKeys(keys *KeySet) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	lifetime := i.accessTTL
+	if i.challengeTTL > lifetime {
+		lifetime = i.challengeTTL
+	}
+
+	retired := []retiredKeys{{keys: i.keys, until: now.Add(lifetime)}}
+	for _, r := range i.retired {
+		if now.Before(r.until) {
+			retired = append(retired, r)
+		}
+	}
+	i.retired = retired
+	i.keys = keys
+}
+
+// keySets returns the current key set and the retired ones still accepted,
+// newest first.
+func (i *Issuer) keySets() (current *KeySet, retired []*KeySet) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	now := time.Now()
+	for _, r := range i.retired {
+		if now.Before(r.until) {
+			retired = append(retired, r.keys)
+		}
+	}
+	return i.keys, retired
+}
+
+// Issue signs an access token for the subject and returns it with its expiry.
+// A fresh "jti" is assigned so the token can be revoked individually.
+func (i *Issuer) Issue(subject uuid.UUID, email, role string, permissions []string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(i.accessTTL)
+
+	claims := Claims{
+		Email:          email,
+		Role:           role,
+		Permissions:    permissions,
+		IssuedAtMicros: now.UnixMicro(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   subject.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	keys, _ := i.keySets()
+	token, err := keys.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// Verify parses tokenStr and checks its signature and expiry. Challenge
+// tokens are rejected so they can never be used as access tokens: they are
+// signed with a key that only verifies challenges, and carry a "typ" header
+// and audience that access tokens never have.
+func (i *Issuer) Verify(tokenStr string) (*Claims, error) {
+	return i.verify(tokenStr, "")
+}
+
+// IssueChallenge signs a short-lived token for the subject that is only
+// accepted by VerifyChallenge with the same purpose. It is signed with the
+// key set's challenge key, which is never published in the JWKS.
+func (i *Issuer) IssueChallenge(subject uuid.UUID, purpose string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	claims := Claims{
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   subject.String(),
+			Audience:  jwt.ClaimStrings{purpose},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	i.mu.Lock()
+	if ttl > i.challengeTTL {
+		i.challengeTTL = ttl
+	}
+	i.mu.Unlock()
+
+	keys, _ := i.keySets()
+	token, err := keys.SignChallenge(claims, challengeType)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// VerifyChallenge parses a challenge token issued for purpose.
+func (i *Issuer) VerifyChallenge(tokenStr, purpose string) (*Claims, error) {
+	return i.verify(tokenStr, purpose)
+}
+
+// verify parses an access token if purpose is empty, and a challenge token
+// for purpose otherwise.
+func (i *Issuer) verify(tokenStr, purpose string) (*Claims, error) {
+	current, retired := i.keySets()
+
+	var (
+		claims Claims
+		token  *jwt.Token
+		err    error
+	)
+	for _, ks := range append([]*KeySet{current}, retired...) {
+		keyfunc := ks.Keyfunc
+		if purpose != "" {
+			keyfunc = ks.ChallengeKeyfunc
+		}
+		claims = Claims{}
+		if token, err = jwt.ParseWithClaims(tokenStr, &claims, keyfunc); err == nil {
+			break
+		}
+	}
+	if err != nil || !token.Valid || claims.Purpose != purpose {
+		return nil, ErrInvalidToken
+	}
+
+	typ, _ := token.Header["typ"].(string)
+	if purpose == "" {
+		if typ == challengeType || len(claims.Audience) > 0 {
+			return nil, ErrInvalidToken
+		}
+	} else if typ != challengeType || !claims.VerifyAudience(purpose, true) {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// RefreshTTL returns how long refresh tokens stay valid.
+func (i *Issuer) RefreshTTL() time.Duration {
+	return i.refreshTTL
+}
+
+// JWKS returns the public verification keys, including those of retired
+// key sets while their tokens are still accepted.
+func (i *Issuer) JWKS() JWKS {
+	current, retired := i.keySets()
+
+	set := current.JWKS()
+	if len(retired) == 0 {
+		return set
+	}
+
+	published := make(map[string]bool, len(set.Keys))
+	for _, k := range set.Keys {
+		published[k.KeyID] = true
+	}
+	for _, ks := range retired {
+		for _, k := range ks.JWKS().Keys {
+			if !published[k.KeyID] {
+				published[k.KeyID] = true
+				set.Keys = append(set.Keys, k)
+			}
+		}
+	}
+	sort.Slice(set.Keys, func(a, b int) bool { return set.Keys[a].KeyID < set.Keys[b].KeyID })
+
+	return set
+}
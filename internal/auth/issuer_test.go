@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// writeRSAKey writes a new PKCS#8 RSA private key to dir and returns its
+// config.
+func writeRSAKey(t *testing.T, dir, id string) KeyConfig {
+	t.Helper()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, id+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return KeyConfig{ID: id, Path: path}
+}
+
+func hmacKeys(t *testing.T, secret string) *KeySet {
+	t.Helper()
+	keys, err := NewKeySet(nil, "", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func rsaKeys(t *testing.T, id string) *KeySet {
+	t.Helper()
+	keys, err := NewKeySet([]KeyConfig{writeRSAKey(t, t.TempDir(), id)}, id, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// jwksKeyfunc verifies like a downstream service that only knows the
+// published JWKS.
+func jwksKeyfunc(set JWKS) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		for _, k := range set.Keys {
+			if k.KeyID != kid || k.KeyType != "RSA" {
+				continue
+			}
+			n, _ := base64.RawURLEncoding.DecodeString(k.N)
+			e, _ := base64.RawURLEncoding.DecodeString(k.E)
+			return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+		}
+		return nil, ErrUnknownKey
+	}
+}
+
+func TestChallengeTokensAreNotAccessTokens(t *testing.T) {
+	subject := uuid.New()
+
+	tests := []struct {
+		name string
+		keys *KeySet
+	}{
+		{"hmac", hmacKeys(t, "test-secret")},
+		{"rsa", rsaKeys(t, "k1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := NewIssuer(tt.keys, time.Hour, 24*time.Hour)
+
+			challenge, _, err := issuer.IssueChallenge(subject, PurposeMFAChallenge, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			access, _, err := issuer.Issue(subject, "a@example.com", "user", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := issuer.Verify(challenge); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify(challenge) err = %v, want %v", err, ErrInvalidToken)
+			}
+			if _, err := issuer.VerifyChallenge(access, PurposeMFAChallenge); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("VerifyChallenge(access) err = %v, want %v", err, ErrInvalidToken)
+			}
+			if _, err := issuer.VerifyChallenge(challenge, "other_purpose"); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("VerifyChallenge(other purpose) err = %v, want %v", err, ErrInvalidToken)
+			}
+
+			claims, err := issuer.VerifyChallenge(challenge, PurposeMFAChallenge)
+			if err != nil {
+				t.Fatalf("VerifyChallenge: %v", err)
+			}
+			if claims.Subject != subject.String() {
+				t.Errorf("subject = %s, want %s", claims.Subject, subject)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(challenge, &Claims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if typ := parsed.Header["typ"]; typ != challengeType {
+				t.Errorf("typ = %v, want %s", typ, challengeType)
+			}
+			if aud := parsed.Claims.(*Claims).Audience; len(aud) != 1 || aud[0] != PurposeMFAChallenge {
+				t.Errorf("aud = %v, want [%s]", aud, PurposeMFAChallenge)
+			}
+		})
+	}
+}
+
+func TestChallengeTokensFailJWKSVerification(t *testing.T) {
+	issuer := NewIssuer(rsaKeys(t, "k1"), time.Hour, 24*time.Hour)
+	jwks := issuer.JWKS()
+
+	access, _, err := issuer.Issue(uuid.New(), "a@example.com", "user", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(access, jwksKeyfunc(jwks)); err != nil {
+		t.Fatalf("access token does not verify against the JWKS: %v", err)
+	}
+
+	challenge, _, err := issuer.IssueChallenge(uuid.New(), PurposeMFAChallenge, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(challenge, jwksKeyfunc(jwks)); err == nil {
+		t.Error("challenge token verified against the published JWKS")
+	}
+}
+
+func TestVerifyRejectsAccessSignedTokensWithAudience(t *testing.T) {
+	keys := hmacKeys(t, "test-secret")
+	issuer := NewIssuer(keys, time.Hour, 24*time.Hour)
+
+	// A token signed with the access key that claims to be a challenge
+	now := time.Now()
+	forged, err := keys.Sign(Claims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   uuid.NewString(),
+		Audience:  jwt.ClaimStrings{PurposeMFAChallenge},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := issuer.Verify(forged); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify err = %v, want %v", err, ErrInvalidToken)
+	}
+	if _, err := issuer.VerifyChallenge(forged, PurposeMFAChallenge); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("VerifyChallenge err = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestIssueTimeKeepsMicroseconds(t *testing.T) {
+	issuer := NewIssuer(hmacKeys(t, "test-secret"), time.Hour, 24*time.Hour)
+
+	before := time.Now().Truncate(time.Microsecond)
+	token, _, err := issuer.Issue(uuid.New(), "a@example.com", "user", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+
+	claims, err := issuer.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issued := claims.IssueTime(); issued.Before(before) || issued.After(after) {
+		t.Errorf("issue time %s not within [%s, %s]", issued, before, after)
+	}
+	if claims.IssuedAt.Unix() != claims.IssueTime().Unix() || claims.IssuedAt.Nanosecond() != 0 {
+		t.Errorf("iat = %s, want the whole second of %s", claims.IssuedAt.Time, claims.IssueTime())
+	}
+}
+
+func TestSetKeysKeepsEveryRetiredSetUntilItsTokensExpire(t *testing.T) {
+	k1, k2, k3 := rsaKeys(t, "k1"), rsaKeys(t, "k2"), hmacKeys(t, "rotated-secret")
+	issuer := NewIssuer(k1, time.Hour, 24*time.Hour)
+	subject := uuid.New()
+
+	issue := func() (access, challenge string) {
+		t.Helper()
+		access, _, err := issuer.Issue(subject, "a@example.com", "user", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		challenge, _, err = issuer.IssueChallenge(subject, PurposeMFAChallenge, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return access, challenge
+	}
+
+	// Two rotations within one access token lifetime
+	access1, challenge1 := issue()
+	issuer.SetKeys(k2)
+	access2, challenge2 := issue()
+	issuer.SetKeys(k3)
+	access3, challenge3 := issue()
+
+	tests := []struct {
+		name              string
+		access, challenge string
+	}{
+		{"signed by k1", access1, challenge1},
+		{"signed by k2", access2, challenge2},
+		{"signed by k3", access3, challenge3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := issuer.Verify(tt.access); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if _, err := issuer.VerifyChallenge(tt.challenge, PurposeMFAChallenge); err != nil {
+				t.Errorf("VerifyChallenge: %v", err)
+			}
+		})
+	}
+
+	var kids []string
+	for _, k := range issuer.JWKS().Keys {
+		kids = append(kids, k.KeyID)
+	}
+	if len(kids) != 2 || kids[0] != "k1" || kids[1] != "k2" {
+		t.Errorf("JWKS key IDs = %v, want [k1 k2]", kids)
+	}
+}
+
+func TestSetKeysDropsRetiredSetsOnceTheirTokensExpired(t *testing.T) {
+	issuer := NewIssuer(rsaKeys(t, "k1"), 50*time.Millisecond, time.Hour)
+
+	issuer.SetKeys(rsaKeys(t, "k2"))
+	if _, retired := issuer.keySets(); len(retired) != 1 {
+		t.Fatalf("%d retired key sets right after rotating, want 1", len(retired))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, retired := issuer.keySets(); len(retired) != 0 {
+		t.Errorf("%d retired key sets after the access token lifetime, want 0", len(retired))
+	}
+	if keys := issuer.JWKS().Keys; len(keys) != 1 || keys[0].KeyID != "k2" {
+		t.Errorf("JWKS = %+v, want only k2", keys)
+	}
+
+	issuer.SetKeys(rsaKeys(t, "k3"))
+	if len(issuer.retired) != 1 {
+		t.Errorf("%d retired key sets kept, want only k2", len(issuer.retired))
+	}
+}
@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrUnknownKey is returned when a token references a key that is not loaded.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeyConfig points at a PEM-encoded key on disk. Private keys can sign and
+// verify; public keys only verify, which is how retired keys are kept around
+// until every token they signed has expired.
+type KeyConfig struct {
+	ID   string
+	Path string
+}
+
+// key is a loaded signing or verification key. private is nil for
+// verification-only keys.
+type key struct {
+	id      string
+	method  jwt.SigningMethod
+	public  crypto.PublicKey
+	private crypto.PrivateKey
+}
+
+// KeySet holds every key accepted for verification and the single key used
+// for signing. Tokens carry the signing key's ID in their "kid" header.
+//
+// Without asymmetric keys the set falls back to HS256 with a shared secret,
+// which is never published in the JWKS.
+//
+// Challenge tokens are signed with a separate HS256 key derived from the
+// active key. It is never published, so verifiers using the JWKS cannot
+// accept a challenge token, and every instance sharing the keys derives the
+// same one.
+type KeySet struct {
+	keys      map[string]*key
+	active    *key
+	challenge []byte
+}
+
+// NewKeySet loads keys from disk and selects activeID for signing. If no
+// keys are configured it returns an HS256 key set using secret.
+func NewKeySet(keys []KeyConfig, activeID, secret string) (*KeySet, error) {
+	if len(keys) == 0 {
+		if secret == "" {
+			return nil, errors.New("either signing keys or a JWT secret is required")
+		}
+		hmacKey := &key{method: jwt.SigningMethodHS256, private: []byte(secret)}
+		return &KeySet{keys: map[string]*key{"": hmacKey}, active: hmacKey, challenge: deriveChallengeKey([]byte(secret))}, nil
+	}
+
+	ks := &KeySet{keys: make(map[string]*key, len(keys))}
+	for _, kc := range keys {
+		if kc.ID == "" {
+			return nil, fmt.Errorf("key %s: id is required", kc.Path)
+		}
+		if _, dup := ks.keys[kc.ID]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", kc.ID)
+		}
+		k, err := loadKey(kc)
+		if err != nil {
+			return nil, err
+		}
+		ks.keys[kc.ID] = k
+	}
+
+	active, ok := ks.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q is not configured", activeID)
+	}
+	if active.private == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeID)
+	}
+	ks.active = active
+
+	der, err := x509.MarshalPKCS8PrivateKey(active.private)
+	if err != nil {
+		return nil, fmt.Errorf("active key %q: %w", activeID, err)
+	}
+	ks.challenge = deriveChallengeKey(der)
+
+	return ks, nil
+}
+
+// deriveChallengeKey derives the challenge signing key from the secret
+// material of the active key.
+func deriveChallengeKey(secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("mfa-challenge-signing-key"))
+	return mac.Sum(nil)
+}
+
+func loadKey(kc KeyConfig) (*key, error) {
+	data, err := os.ReadFile(kc.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read key %s: %w", kc.ID, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM block found", kc.ID)
+	}
+
+	loaded := &key{id: kc.ID}
+
+	switch block.Type {
+	case "PRIVATE KEY", "RSA PRIVATE KEY":
+		var priv any
+		if block.Type == "RSA PRIVATE KEY" {
+			priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		} else {
+			priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse private key %s: %w", kc.ID, err)
+		}
+		switch k := priv.(type) {
+		case *rsa.PrivateKey:
+			loaded.method, loaded.public, loaded.private = jwt.SigningMethodRS256, &k.PublicKey, k
+		case ed25519.PrivateKey:
+			loaded.method, loaded.public, loaded.private = jwt.SigningMethodEdDSA, k.Public(), k
+		default:
+			return nil, fmt.Errorf("key %s: unsupported private key type %T", kc.ID, priv)
+		}
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key %s: %w", kc.ID, err)
+		}
+		switch k := pub.(type) {
+		case *rsa.PublicKey:
+			loaded.method, loaded.public = jwt.SigningMethodRS256, k
+		case ed25519.PublicKey:
+			loaded.method, loaded.public = jwt.SigningMethodEdDSA, k
+		default:
+			return nil, fmt.Errorf("key %s: unsupported public key type %T", kc.ID, pub)
+		}
+	default:
+		return nil, fmt.Errorf("key %s: unsupported PEM block %q", kc.ID, block.Type)
+	}
+
+	if rsaKey, ok := loaded.public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("key %s: RSA keys must be at least 2048 bits", kc.ID)
+	}
+
+	return loaded, nil
+}
+
+// Sign signs claims with the active key and sets the "kid" header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	if ks.active.id != "" {
+		token.Header["kid"] = ks.active.id
+	}
+	return token.SignedString(ks.active.private)
+}
+
+// SignChallenge signs claims with the challenge key, setting the "typ"
+// header to typ.
+func (ks *KeySet) SignChallenge(claims jwt.Claims, typ string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["typ"] = typ
+	return token.SignedString(ks.challenge)
+}
+
+// ChallengeKeyfunc resolves the challenge key for a parsed token.
+func (ks *KeySet) ChallengeKeyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method != jwt.SigningMethodHS256 {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return ks.challenge, nil
+}
+
+// Keyfunc resolves the verification key for a parsed token. It rejects
+// tokens whose algorithm does not match the key named by their "kid".
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	if k.method == jwt.SigningMethodHS256 {
+		return k.private, nil
+	}
+	return k.public, nil
+}
+
+// JWK is a single public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP (Ed25519)
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public halves of every asymmetric key, sorted by ID.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+
+	for _, k := range ks.keys {
+		jwk := JWK{KeyID: k.id, Use: "sig", Algorithm: k.method.Alg()}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue // HMAC secrets are never published
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+
+	return set
+}
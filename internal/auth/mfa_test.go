@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMFAKeysSealSecret(t *testing.T) {
+	keys, err := NewMFAKeys("server-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKeys, err := NewMFAKeys("other-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := uuid.New()
+
+	sealed, err := keys.SealSecret(owner, "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("sealed secret %q contains the plain text", sealed)
+	}
+
+	tests := []struct {
+		name       string
+		keys       *MFAKeys
+		userID     uuid.UUID
+		stored     string
+		want       string
+		wantLegacy bool
+		wantErr    error
+	}{
+		{"sealed", keys, owner, sealed, "JBSWY3DPEHPK3PXP", false, nil},
+		{"legacy plain text", keys, owner, "JBSWY3DPEHPK3PXP", "JBSWY3DPEHPK3PXP", true, nil},
+		{"another user's secret", keys, uuid.New(), sealed, "", false, ErrUndecryptableSecret},
+		{"another key", otherKeys, owner, sealed, "", false, ErrUndecryptableSecret},
+		{"corrupted", keys, owner, sealed[:len(sealed)-2], "", false, ErrUndecryptableSecret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, legacy, err := tt.keys.OpenSecret(tt.userID, tt.stored)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want || legacy != tt.wantLegacy {
+				t.Errorf("OpenSecret = %q, legacy %v, want %q, legacy %v", got, legacy, tt.want, tt.wantLegacy)
+			}
+		})
+	}
+}
+
+func TestMFAKeysHashRecoveryCodeUnderTheServerSecret(t *testing.T) {
+	keys, _ := NewMFAKeys("server-secret")
+	sameKeys, _ := NewMFAKeys("server-secret")
+	otherKeys, _ := NewMFAKeys("other-secret")
+
+	hash := keys.HashRecoveryCode("ABCDEFGHIJKLMNOP")
+	if got := sameKeys.HashRecoveryCode("ABCDEFGHIJKLMNOP"); got != hash {
+		t.Errorf("hash differs under the same secret: %s, want %s", got, hash)
+	}
+	if got := otherKeys.HashRecoveryCode("ABCDEFGHIJKLMNOP"); got == hash {
+		t.Error("hash is the same under another secret")
+	}
+}
@@ -2,18 +2,46 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"net"
+	"net/netip"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all application configuration.
-// Values are loaded from environment variables with sensible defaults.
+// Values are layered: built-in defaults, then an optional YAML or TOML
+// file, then environment variables, then -set flags.
 type Config struct {
 	// Server
 	HTTPPort int
 	GRPCPort int
 	Version  string
 	Env      string
+	// PublicURL is the externally reachable base URL, used in emailed links.
+	PublicURL string
+
+	// HTTP server timeouts and the grace period for in-flight requests
+	// on shutdown.
+	HTTPReadTimeout  time.Duration
+	HTTPWriteTimeout time.Duration
+	HTTPIdleTimeout  time.Duration
+	ShutdownTimeout  time.Duration
+
+	// CORSAllowedOrigins lists the origins allowed to make cross-origin
+	// requests; "*" allows any.
+	CORSAllowedOrigins []string
+
+	// Requests allowed per client IP and RateLimitWindow on the public auth
+	// routes and on the authenticated routes.
+	RateLimitPublic    int
+	RateLimitProtected int
+	RateLimitWindow    time.Duration
+
+	// TrustedProxies lists the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For header identifies the client.
+	TrustedProxies []string
 
 	// Database
 	DBHost     string
@@ -28,83 +56,318 @@ type Config struct {
 	RedisPort     int
 	RedisPassword string
 	RedisDB       int
+	CacheTTL      time.Duration // lifetime of cached users
 
 	// Auth
-	JWTSecret     string
-	JWTExpiration int // hours
+	JWTSecret            string
+	JWTExpiration        time.Duration
+	JWTRefreshExpiration time.Duration
+	// JWTSigningKeys lists PEM key files as "kid=path" pairs. When set they
+	// replace JWTSecret; JWTActiveKeyID picks the key used for signing.
+	JWTSigningKeys []JWTKey
+	JWTActiveKeyID string
+
+	// Login lockout
+	LoginMaxAttempts   int // failures per email before lockout
+	LoginIPMaxAttempts int // failures per client IP before lockout
+	LoginLockout       time.Duration
+	// LoginMemoryEntries bounds the per-replica store of login failures
+	// used while Redis is unavailable.
+	LoginMemoryEntries int
+
+	// Password reset and email verification
+	PasswordResetTTL         time.Duration
+	EmailVerificationTTL     time.Duration
+	RequireEmailVerification bool
+
+	// Password policy. PasswordMaxBytes is capped at bcrypt's 72-byte limit.
+	// PasswordBreachedFile lists SHA-1 hashes or hash prefixes of breached
+	// passwords, one per line; empty disables the check.
+	PasswordMinLength     int
+	PasswordMaxBytes      int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	PasswordBreachedFile  string
+
+	// Password hashing. New hashes use PasswordHashAlgorithm ("argon2id" or
+	// "bcrypt"); older hashes are upgraded when their owner next logs in.
+	PasswordHashAlgorithm string
+	PasswordBcryptCost    int
+	PasswordArgon2Memory  int // KiB
+	PasswordArgon2Time    int // iterations
+	PasswordArgon2Threads int
+
+	// OIDCProviders lists external identity providers enabled for login.
+	OIDCProviders []OIDCProvider
+
+	// Two-factor authentication
+	MFAIssuer       string // name shown in authenticator apps
+	MFAChallengeTTL time.Duration
+	// MFASecretKey encrypts TOTP secrets and keys recovery code hashes.
+	// Changing it invalidates every enrollment.
+	MFASecretKey string
+
+	// Notifications: "log" or "file". NotifierFile is the mailbox file used
+	// by the file notifier.
+	Notifier     string
+	NotifierFile string
 
 	// Logging
 	LogLevel string
+
+	// SecretsRefreshInterval is how often secrets read through a
+	// SecretProvider are re-read; zero disables it.
+	SecretsRefreshInterval time.Duration
+
+	// origins records where each setting's value came from, by key.
+	origins map[string]string
+	// secretRefs holds the reference each secret was read from through
+	// secretProvider, by key, so that it can be read again.
+	secretRefs     map[string]string
+	secretProvider SecretProvider
 }
 
-// Load reads configuration from environment variables.
-func Load() (*Config, error) {
-	cfg := &Config{
-		HTTPPort:      getEnvInt("APP_PORT", 8080),
-		GRPCPort:      getEnvInt("GRPC_PORT", 9090),
-		Version:       getEnv("APP_VERSION", "1.0.0"),
-		Env:           getEnv("APP_ENV", "development"),
-		DBHost:        getEnv("DB_HOST", "localhost"),
-		DBPort:        getEnvInt("DB_PORT", 5432),
-		DBName:        getEnv("DB_NAME", "microservice"),
-		DBUser:        getEnv("DB_USER", "postgres"),
-		DBPassword:    getEnv("DB_PASSWORD", "postgres"),
-		DBSSLMode:     getEnv("DB_SSL_MODE", "disable"),
-		RedisHost:     getEnv("REDIS_HOST", "localhost"),
-		RedisPort:     getEnvInt("REDIS_PORT", 6379),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		RedisDB:       getEnvInt("REDIS_DB", 0),
-		JWTSecret:     getEnv("JWT_SECRET", ""),
-		JWTExpiration: getEnvInt("JWT_EXPIRATION_HOURS", 24),
-		LogLevel:      getEnv("LOG_LEVEL", "info"),
-	}
-
-	if err := cfg.validate(); err != nil {
+// JWTKey points at an RS256 or EdDSA key file identified by its "kid".
+type JWTKey struct {
+	ID   string
+	Path string
+}
+
+// OIDCProvider configures one OpenID Connect provider. Each name listed in
+// OIDC_PROVIDERS is read from OIDC_<NAME>_* settings.
+type OIDCProvider struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Load resolves the configuration from every source and validates it. All
+// problems are reported together in a *ValidationError.
+func Load(opts Options) (*Config, error) {
+	src, err := newSources(opts)
+	if err != nil {
 		return nil, err
 	}
 
+	cfg := &Config{
+		origins:        make(map[string]string),
+		secretRefs:     make(map[string]string),
+		secretProvider: src.provider,
+	}
+	var errs []error
+	for _, s := range cfg.settings() {
+		errs = append(errs, src.apply(s, cfg)...)
+	}
+	cfg.PublicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+
+	// Provider settings are only known once OIDC_PROVIDERS is resolved
+	names := cfg.oidcProviderNames()
+	cfg.OIDCProviders = make([]OIDCProvider, len(names))
+	for i, name := range names {
+		cfg.OIDCProviders[i].Name = name
+		for _, s := range cfg.oidcSettings(i) {
+			errs = append(errs, src.apply(s, cfg)...)
+		}
+	}
+
+	errs = append(errs, src.unknown(cfg.origins)...)
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+
 	return cfg, nil
 }
 
-// DatabaseURL returns the PostgreSQL connection string.
+// DatabaseURL returns the PostgreSQL connection string. Credentials and the
+// database name are escaped, so they may contain any character.
 func (c *Config) DatabaseURL() string {
-	return fmt.Sprintf(
-		"postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		c.DBUser, c.DBPassword, c.DBHost, c.DBPort, c.DBName, c.DBSSLMode,
-	)
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.DBUser, c.DBPassword),
+		Host:     net.JoinHostPort(c.DBHost, strconv.Itoa(c.DBPort)),
+		Path:     "/" + c.DBName,
+		RawQuery: url.Values{"sslmode": {c.DBSSLMode}}.Encode(),
+	}
+	return u.String()
 }
 
-// RedisURL returns the Redis connection string.
+// RedisURL returns the Redis connection string, with the password escaped.
 func (c *Config) RedisURL() string {
+	u := url.URL{
+		Scheme: "redis",
+		Host:   net.JoinHostPort(c.RedisHost, strconv.Itoa(c.RedisPort)),
+		Path:   "/" + strconv.Itoa(c.RedisDB),
+	}
 	if c.RedisPassword != "" {
-		return fmt.Sprintf("redis://:%s@%s:%d/%d", c.RedisPassword, c.RedisHost, c.RedisPort, c.RedisDB)
+		u.User = url.UserPassword("", c.RedisPassword)
+	}
+	return u.String()
+}
+
+// TrustedProxyPrefixes returns TrustedProxies as address ranges, a single
+// address being a range of one.
+func (c *Config) TrustedProxyPrefixes() []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(c.TrustedProxies))
+	for _, p := range c.TrustedProxies {
+		if prefix, err := parseProxy(p); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
 	}
-	return fmt.Sprintf("redis://%s:%d/%d", c.RedisHost, c.RedisPort, c.RedisDB)
+	return prefixes
 }
 
-// validate checks that required configuration is present.
-func (c *Config) validate() error {
-	if c.Env == "production" && c.JWTSecret == "" {
-		return fmt.Errorf("JWT_SECRET is required in production")
+func parseProxy(v string) (netip.Prefix, error) {
+	if strings.Contains(v, "/") {
+		prefix, err := netip.ParsePrefix(v)
+		return prefix.Masked(), err
 	}
-	if c.JWTSecret == "" {
-		c.JWTSecret = "dev-secret-change-in-production"
+	addr, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, err
 	}
-	return nil
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// ValidationError lists every invalid setting found by Load.
+type ValidationError struct {
+	Errors []error
 }
 
-func getEnv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid configuration:")
+	for _, err := range e.Errors {
+		b.WriteString("\n  ")
+		b.WriteString(err.Error())
 	}
-	return fallback
+	return b.String()
 }
 
-func getEnvInt(key string, fallback int) int {
-	if v := os.Getenv(key); v != "" {
-		if i, err := strconv.Atoi(v); err == nil {
-			return i
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// validate checks the resolved values against each other and their allowed
+// ranges, collecting every problem.
+func (c *Config) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	for _, p := range []struct {
+		key  string
+		port int
+	}{{"APP_PORT", c.HTTPPort}, {"GRPC_PORT", c.GRPCPort}, {"DB_PORT", c.DBPort}, {"REDIS_PORT", c.RedisPort}} {
+		check(p.port > 0 && p.port < 65536, "%s: %d is not a valid port", p.key, p.port)
+	}
+	check(c.RedisDB >= 0, "REDIS_DB: must not be negative")
+
+	for _, d := range []struct {
+		key string
+		d   time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", c.HTTPReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.HTTPWriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", c.HTTPIdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"RATE_LIMIT_WINDOW", c.RateLimitWindow},
+		{"CACHE_TTL", c.CacheTTL},
+		{"JWT_EXPIRATION", c.JWTExpiration},
+		{"JWT_REFRESH_EXPIRATION", c.JWTRefreshExpiration},
+		{"LOGIN_LOCKOUT", c.LoginLockout},
+		{"PASSWORD_RESET_TTL", c.PasswordResetTTL},
+		{"EMAIL_VERIFICATION_TTL", c.EmailVerificationTTL},
+		{"MFA_CHALLENGE_TTL", c.MFAChallengeTTL},
+	} {
+		check(d.d > 0, "%s: must be a positive duration", d.key)
+	}
+	check(c.SecretsRefreshInterval >= 0, "SECRETS_REFRESH_INTERVAL: must not be negative")
+
+	if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("APP_PUBLIC_URL: %q is not an absolute http(s) URL", c.PublicURL))
+	}
+
+	check(oneOf(c.DBSSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+		"DB_SSL_MODE: unknown mode %q", c.DBSSLMode)
+	check(oneOf(c.LogLevel, "trace", "debug", "info", "warn", "error", "fatal", "panic", "disabled"),
+		"LOG_LEVEL: unknown level %q", c.LogLevel)
+	check(oneOf(c.Notifier, "log", "file"), "NOTIFIER: unknown notifier %q", c.Notifier)
+	check(oneOf(c.PasswordHashAlgorithm, "argon2id", "bcrypt"),
+		"PASSWORD_HASH_ALGORITHM: unknown algorithm %q", c.PasswordHashAlgorithm)
+
+	check(c.RateLimitPublic > 0 && c.RateLimitProtected > 0,
+		"RATE_LIMIT_PUBLIC and RATE_LIMIT_PROTECTED must be positive")
+	check(len(c.CORSAllowedOrigins) > 0, "CORS_ALLOWED_ORIGINS: must list at least one origin or \"*\"")
+	for _, p := range c.TrustedProxies {
+		_, err := parseProxy(p)
+		check(err == nil, "TRUSTED_PROXIES: %q is not an IP address or CIDR range", p)
+	}
+	check(c.LoginMaxAttempts > 0 && c.LoginIPMaxAttempts > 0,
+		"LOGIN_MAX_ATTEMPTS and LOGIN_IP_MAX_ATTEMPTS must be positive")
+	check(c.LoginMemoryEntries > 0, "LOGIN_MEMORY_ENTRIES: must be positive")
+	check(c.PasswordMinLength >= 1 && c.PasswordMaxBytes >= c.PasswordMinLength && c.PasswordMaxBytes <= 72,
+		"PASSWORD_MIN_LENGTH and PASSWORD_MAX_BYTES must satisfy 1 <= min <= max <= 72")
+	check(c.PasswordBcryptCost >= 4 && c.PasswordBcryptCost <= 31, "PASSWORD_BCRYPT_COST: must be between 4 and 31")
+	check(c.PasswordArgon2Memory >= 1 && c.PasswordArgon2Time >= 1 && c.PasswordArgon2Threads >= 1 && c.PasswordArgon2Threads <= 255,
+		"PASSWORD_ARGON2_* parameters must be positive and parallelism at most 255")
+
+	check(c.MFASecretKey != "", "MFA_SECRET_KEY: must not be empty")
+	check(c.Env != "production" || c.MFASecretKey != devMFASecretKey, "MFA_SECRET_KEY is required in production")
+
+	for _, p := range c.OIDCProviders {
+		prefix := oidcPrefix(p.Name)
+		check(p.IssuerURL != "" && p.ClientID != "",
+			"OIDC provider %q: %sISSUER_URL and %sCLIENT_ID are required", p.Name, prefix, prefix)
+	}
+
+	if len(c.JWTSigningKeys) > 0 {
+		found := false
+		for _, k := range c.JWTSigningKeys {
+			found = found || k.ID == c.JWTActiveKeyID
+		}
+		check(c.JWTActiveKeyID != "", "JWT_ACTIVE_KEY_ID is required when JWT_SIGNING_KEYS is set")
+		check(c.JWTActiveKeyID == "" || found, "JWT_ACTIVE_KEY_ID: %q is not in JWT_SIGNING_KEYS", c.JWTActiveKeyID)
+		return errs
+	}
+	check(c.JWTSecret != "", "JWT_SECRET: must not be empty")
+	check(c.Env != "production" || c.JWTSecret != devJWTSecret,
+		"JWT_SECRET or JWT_SIGNING_KEYS is required in production")
+
+	return errs
+}
+
+func oneOf(v string, allowed ...string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+// parseJWTKeys parses a comma-separated list of "kid=path" pairs.
+func parseJWTKeys(v string) ([]JWTKey, error) {
+	var keys []JWTKey
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, path, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || path == "" {
+			return nil, fmt.Errorf("invalid entry %q, want kid=path", entry)
 		}
+		keys = append(keys, JWTKey{ID: id, Path: path})
 	}
-	return fallback
+	return keys, nil
 }
@@ -0,0 +1,346 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes content to name in a new directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// settingsByKey loads opts and indexes the resulting settings.
+func settingsByKey(t *testing.T, opts Options) (*Config, map[string]Setting) {
+	t.Helper()
+	cfg, err := Load(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byKey := make(map[string]Setting)
+	for _, s := range cfg.Settings() {
+		byKey[s.Key] = s
+	}
+	return cfg, byKey
+}
+
+const tomlConfig = `
+jwt.secret = "file-jwt-secret"
+login.max_attempts = 7
+
+[app]
+port = 8081
+
+[db]
+host = "file-host"
+port = 5433
+name = "fromfile"
+
+[cors]
+allowed_origins = ["https://a.example", "https://b.example"]
+
+[password]
+require_digit = true
+`
+
+const yamlConfig = `
+jwt:
+  secret: file-jwt-secret
+login:
+  max_attempts: 7
+app:
+  port: 8081
+db:
+  host: file-host
+  port: 5433
+  name: fromfile
+cors:
+  allowed_origins: [https://a.example, https://b.example]
+password:
+  require_digit: true
+`
+
+func TestLoadPrecedence(t *testing.T) {
+	for _, file := range []struct{ name, content string }{
+		{"config.toml", tomlConfig},
+		{"config.yaml", yamlConfig},
+	} {
+		t.Run(file.name, func(t *testing.T) {
+			t.Setenv("DB_HOST", "env-host")
+			t.Setenv("DB_PORT", "5434")
+			opts := Options{
+				File:      writeFile(t, file.name, file.content),
+				Overrides: map[string]string{"DB_PORT": "5435"},
+			}
+
+			cfg, settings := settingsByKey(t, opts)
+
+			tests := []struct {
+				key, value, source string
+			}{
+				{"APP_PORT", "8081", file.name},
+				{"DB_HOST", "env-host", "environment"},
+				{"DB_PORT", "5435", "flag"},
+				{"DB_NAME", "fromfile", file.name},
+				{"CORS_ALLOWED_ORIGINS", "https://a.example,https://b.example", file.name},
+				{"LOGIN_MAX_ATTEMPTS", "7", file.name},
+				{"PASSWORD_REQUIRE_DIGIT", "true", file.name},
+				{"JWT_SECRET", redacted, file.name},
+				{"REDIS_HOST", "localhost", "default"},
+			}
+			for _, tt := range tests {
+				got := settings[tt.key]
+				if got.Value != tt.value || got.Source != tt.source {
+					t.Errorf("%s = %q from %s, want %q from %s", tt.key, got.Value, got.Source, tt.value, tt.source)
+				}
+			}
+			if cfg.JWTSecret != "file-jwt-secret" {
+				t.Errorf("JWTSecret = %q, want the file's", cfg.JWTSecret)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name, content string
+		want          string
+	}{
+		{"config.toml", "[db]\nport = \n", "parse config file"},
+		{"config.toml", "[db]\nport = 1\nport = 2\n", "parse config file"},
+		{"config.toml", "[db]\nhost = 1979-05-27T07:32:00Z\n", "DB_HOST: unsupported value"},
+		{"config.toml", "[app]\nprot = 8080\n", "APP_PROT (config.toml): unknown setting"},
+		{"config.yaml", "db: [unclosed\n", "parse config file"},
+		{"config.ini", "APP_PORT=8080\n", "unsupported format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.want, func(t *testing.T) {
+			_, err := Load(Options{File: writeFile(t, tt.name, tt.content)})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateHasNoSideEffects(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"development default", nil, ""},
+		{"production default", map[string]string{"APP_ENV": "production"}, "JWT_SECRET or JWT_SIGNING_KEYS is required in production"},
+		{"production without MFA key", map[string]string{"APP_ENV": "production", "JWT_SECRET": "s3cret"}, "MFA_SECRET_KEY is required in production"},
+		{"production secrets", map[string]string{"APP_ENV": "production", "JWT_SECRET": "s3cret", "MFA_SECRET_KEY": "s3cret-too"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load(Options{})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	cfg, err := Load(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.JWTSecret = ""
+	before := *cfg
+	if errs := cfg.validate(); len(errs) == 0 {
+		t.Error("an empty JWT_SECRET passed validation")
+	}
+	if cfg.JWTSecret != before.JWTSecret {
+		t.Errorf("validate changed JWTSecret to %q", cfg.JWTSecret)
+	}
+}
+
+func TestSecretsAreRedacted(t *testing.T) {
+	const (
+		dbPassword    = "db-password-from-env"
+		redisPassword = "redis-password-from-flag"
+		jwtSecret     = "jwt-secret-from-file"
+		clientSecret  = "client-secret-from-file"
+	)
+	t.Setenv("DB_PASSWORD", dbPassword)
+	opts := Options{
+		File: writeFile(t, "config.toml", fmt.Sprintf(`
+jwt.secret = %q
+oidc.providers = "google"
+oidc.google.issuer_url = "https://accounts.google.com"
+oidc.google.client_id = "id"
+oidc.google.client_secret = %q
+`, jwtSecret, clientSecret)),
+		Overrides: map[string]string{"REDIS_PASSWORD": redisPassword},
+	}
+	cfg, err := Load(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := *cfg
+	rotated.DBPassword = "rotated-db-password"
+
+	var logged bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logged, nil)).Info("config", "config", cfg)
+
+	tests := []struct {
+		name     string
+		rendered string
+	}{
+		{"String", cfg.String()},
+		{"%v", fmt.Sprintf("%v", cfg)},
+		{"%+v", fmt.Sprintf("%+v", *cfg)},
+		{"%#v", fmt.Sprintf("%#v", *cfg)},
+		{"slog", logged.String()},
+		{"Settings", fmt.Sprint(cfg.Settings())},
+		{"Diff", fmt.Sprint(cfg.Diff(&rotated))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, secret := range []string{dbPassword, redisPassword, jwtSecret, clientSecret, rotated.DBPassword} {
+				if strings.Contains(tt.rendered, secret) {
+					t.Errorf("%s reveals %q: %s", tt.name, secret, tt.rendered)
+				}
+			}
+			if !strings.Contains(tt.rendered, redacted) {
+				t.Errorf("%s shows no redacted value: %s", tt.name, tt.rendered)
+			}
+		})
+	}
+}
+
+func TestSecretReferenceOutranksLowerSource(t *testing.T) {
+	secretFile := writeFile(t, "db_password", "from-secret-file\n")
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		flags   map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"file reference over default", map[string]string{"DB_PASSWORD_FILE": secretFile}, nil, "from-secret-file", false},
+		{"flag value over env reference", map[string]string{"DB_PASSWORD_FILE": secretFile}, map[string]string{"DB_PASSWORD": "from-flag"}, "from-flag", false},
+		{"both in one source", map[string]string{"DB_PASSWORD_FILE": secretFile, "DB_PASSWORD": "from-env"}, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load(Options{Overrides: tt.flags})
+			if tt.wantErr {
+				var verr *ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("err = %v, want a *ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DBPassword != tt.want {
+				t.Errorf("DBPassword = %q, want %q", cfg.DBPassword, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectionURLsEscapeCredentials(t *testing.T) {
+	tests := []struct {
+		name, user, password string
+	}{
+		{"plain", "postgres", "postgres"},
+		{"reserved characters", "us@er:x", "p@ss:w/rd?#%&="},
+		{"spaces and unicode", "a user", "pässwörd with spaces"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				DBUser: tt.user, DBPassword: tt.password, DBHost: "db.internal", DBPort: 5432, DBName: "app", DBSSLMode: "require",
+				RedisHost: "cache.internal", RedisPort: 6379, RedisPassword: tt.password, RedisDB: 3,
+			}
+
+			db, err := url.Parse(c.DatabaseURL())
+			if err != nil {
+				t.Fatalf("DatabaseURL %q: %v", c.DatabaseURL(), err)
+			}
+			password, _ := db.User.Password()
+			if db.User.Username() != tt.user || password != tt.password || db.Host != "db.internal:5432" ||
+				db.Path != "/app" || db.Query().Get("sslmode") != "require" {
+				t.Errorf("DatabaseURL %q does not round-trip", c.DatabaseURL())
+			}
+
+			redis, err := url.Parse(c.RedisURL())
+			if err != nil {
+				t.Fatalf("RedisURL %q: %v", c.RedisURL(), err)
+			}
+			password, _ = redis.User.Password()
+			if password != tt.password || redis.Host != "cache.internal:6379" || redis.Path != "/3" {
+				t.Errorf("RedisURL %q does not round-trip", c.RedisURL())
+			}
+		})
+	}
+
+	c := &Config{RedisHost: "localhost", RedisPort: 6379}
+	if got := c.RedisURL(); got != "redis://localhost:6379/0" {
+		t.Errorf("RedisURL without password = %q", got)
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr string
+	}{
+		{"none by default", "", []string{}, ""},
+		{"addresses and ranges", "10.0.0.1, 192.168.1.7/16,2001:db8::/32", []string{"10.0.0.1/32", "192.168.0.0/16", "2001:db8::/32"}, ""},
+		{"IPv4-mapped address", "::ffff:10.0.0.2", []string{"10.0.0.2/32"}, ""},
+		{"host name", "proxy.internal", nil, `TRUSTED_PROXIES: "proxy.internal" is not an IP address or CIDR range`},
+		{"bad range", "10.0.0.0/33", nil, `TRUSTED_PROXIES: "10.0.0.0/33" is not an IP address or CIDR range`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(Options{Overrides: map[string]string{"TRUSTED_PROXIES": tt.value}})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, p := range cfg.TrustedProxyPrefixes() {
+				got = append(got, p.String())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("prefixes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SecretProvider resolves a reference to the current value of a secret.
+// Secrets are resolved again on every refresh, so a provider must return
+// the rotated value once a secret has been rotated.
+type SecretProvider interface {
+	Secret(ctx context.Context, ref string) (string, error)
+}
+
+// FileSecretProvider reads each secret from the file its reference names,
+// as mounted by Docker and Kubernetes secrets. Trailing newlines are
+// dropped.
+type FileSecretProvider struct{}
+
+// Secret implements SecretProvider.
+func (FileSecretProvider) Secret(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		// Most likely caught halfway through a rotation
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// SigningKeySecret names the entry a SecretWatcher keeps for the JWT
+// signing key with the given ID.
+func SigningKeySecret(id string) string {
+	return "JWT_SIGNING_KEYS:" + id
+}
+
+// SecretWatcher holds the current value of every secret setting and
+// periodically re-reads those loaded through a SecretProvider, as well as
+// the JWT signing key files, so that rotations are picked up without a
+// restart.
+type SecretWatcher struct {
+	interval time.Duration
+
+	mu       sync.RWMutex
+	values   map[string]string
+	watched  []watchedSecret
+	handlers []func(changed []string)
+}
+
+type watchedSecret struct {
+	key      string
+	ref      string
+	provider SecretProvider
+}
+
+// WatchSecrets returns a watcher seeded with the secrets of c. It does not
+// re-read anything until Run or Refresh is called.
+func (c *Config) WatchSecrets() *SecretWatcher {
+	w := &SecretWatcher{interval: c.SecretsRefreshInterval, values: make(map[string]string)}
+
+	all := c.settings()
+	for i := range c.OIDCProviders {
+		all = append(all, c.oidcSettings(i)...)
+	}
+	for _, s := range all {
+		if !s.secret {
+			continue
+		}
+		w.values[s.key] = *s.target.(*string)
+		if ref, ok := c.secretRefs[s.key]; ok {
+			w.watched = append(w.watched, watchedSecret{key: s.key, ref: ref, provider: c.secretProvider})
+		}
+	}
+
+	// Key files are compared by content; auth reloads them itself
+	for _, k := range c.JWTSigningKeys {
+		key := SigningKeySecret(k.ID)
+		w.values[key], _ = FileSecretProvider{}.Secret(context.Background(), k.Path)
+		w.watched = append(w.watched, watchedSecret{key: key, ref: k.Path, provider: FileSecretProvider{}})
+	}
+
+	return w
+}
+
+// Value returns the current value of the secret setting key.
+func (w *SecretWatcher) Value(key string) string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.values[key]
+}
+
+// OnChange registers fn to be called after a refresh that changed secrets,
+// with the keys of the changed secrets.
+func (w *SecretWatcher) OnChange(fn func(changed []string)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers = append(w.handlers, fn)
+}
+
+// Run refreshes the secrets every interval until ctx is done. It returns
+// at once if refreshing is disabled or nothing is watched.
+func (w *SecretWatcher) Run(ctx context.Context) {
+	if w.interval <= 0 || len(w.watched) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh re-reads every watched secret and notifies the handlers of
+// changes. A secret that cannot be read keeps its previous value.
+func (w *SecretWatcher) Refresh(ctx context.Context) {
+	var changed []string
+	for _, s := range w.watched {
+		value, err := s.provider.Secret(ctx, s.ref)
+		if err != nil {
+			log.Warn().Err(err).Str("secret", s.key).Msg("failed to refresh secret, keeping the previous value")
+			continue
+		}
+
+		w.mu.Lock()
+		if w.values[s.key] != value {
+			w.values[s.key] = value
+			changed = append(changed, s.key)
+		}
+		w.mu.Unlock()
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+	log.Info().Strs("secrets", changed).Msg("secrets rotated")
+
+	w.mu.RLock()
+	handlers := w.handlers
+	w.mu.RUnlock()
+	for _, fn := range handlers {
+		fn(changed)
+	}
+}
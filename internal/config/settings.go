@@ -0,0 +1,327 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redacted replaces the value of secret settings in anything displayed.
+const redacted = "[REDACTED]"
+
+// devJWTSecret is the default JWT_SECRET, which is rejected in production.
+const devJWTSecret = "dev-secret-change-in-production"
+
+// devMFASecretKey is the default MFA_SECRET_KEY, which is rejected in
+// production.
+const devMFASecretKey = "dev-mfa-key-change-in-production"
+
+// setting binds a configuration key to a Config field.
+type setting struct {
+	key string
+	def string
+	// target is a *string, *int, *bool, *time.Duration, *[]string,
+	// *[]JWTKey or *oidcNames.
+	target interface{}
+	// secret settings are redacted when displayed and may instead be read
+	// through the SecretProvider from a reference in KEY_FILE.
+	secret bool
+	// legacy names the variable the setting used to be read from, holding a
+	// bare number of legacyUnit. It is consulted when key is not set.
+	legacy     string
+	legacyUnit time.Duration
+}
+
+// settings lists every fixed setting of c with its default.
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "APP_PORT", def: "8080", target: &c.HTTPPort},
+		{key: "GRPC_PORT", def: "9090", target: &c.GRPCPort},
+		{key: "APP_VERSION", def: "1.0.0", target: &c.Version},
+		{key: "APP_ENV", def: "development", target: &c.Env},
+		{key: "APP_PUBLIC_URL", def: "http://localhost:8080", target: &c.PublicURL},
+		{key: "HTTP_READ_TIMEOUT", def: "15s", target: &c.HTTPReadTimeout},
+		{key: "HTTP_WRITE_TIMEOUT", def: "15s", target: &c.HTTPWriteTimeout},
+		{key: "HTTP_IDLE_TIMEOUT", def: "60s", target: &c.HTTPIdleTimeout},
+		{key: "SHUTDOWN_TIMEOUT", def: "30s", target: &c.ShutdownTimeout},
+		{key: "CORS_ALLOWED_ORIGINS", def: "*", target: &c.CORSAllowedOrigins},
+		{key: "RATE_LIMIT_PUBLIC", def: "20", target: &c.RateLimitPublic},
+		{key: "RATE_LIMIT_PROTECTED", def: "100", target: &c.RateLimitProtected},
+		{key: "RATE_LIMIT_WINDOW", def: "1m", target: &c.RateLimitWindow},
+		{key: "TRUSTED_PROXIES", target: &c.TrustedProxies},
+		{key: "DB_HOST", def: "localhost", target: &c.DBHost},
+		{key: "DB_PORT", def: "5432", target: &c.DBPort},
+		{key: "DB_NAME", def: "microservice", target: &c.DBName},
+		{key: "DB_USER", def: "postgres", target: &c.DBUser},
+		{key: "DB_PASSWORD", def: "postgres", target: &c.DBPassword, secret: true},
+		{key: "DB_SSL_MODE", def: "disable", target: &c.DBSSLMode},
+		{key: "REDIS_HOST", def: "localhost", target: &c.RedisHost},
+		{key: "REDIS_PORT", def: "6379", target: &c.RedisPort},
+		{key: "REDIS_PASSWORD", target: &c.RedisPassword, secret: true},
+		{key: "REDIS_DB", def: "0", target: &c.RedisDB},
+		{key: "CACHE_TTL", def: "5m", target: &c.CacheTTL},
+		{key: "JWT_SECRET", def: devJWTSecret, target: &c.JWTSecret, secret: true},
+		{key: "JWT_EXPIRATION", def: "24h", target: &c.JWTExpiration, legacy: "JWT_EXPIRATION_HOURS", legacyUnit: time.Hour},
+		{key: "JWT_REFRESH_EXPIRATION", def: "720h", target: &c.JWTRefreshExpiration, legacy: "JWT_REFRESH_EXPIRATION_HOURS", legacyUnit: time.Hour},
+		{key: "JWT_SIGNING_KEYS", target: &c.JWTSigningKeys},
+		{key: "JWT_ACTIVE_KEY_ID", target: &c.JWTActiveKeyID},
+		{key: "LOGIN_MAX_ATTEMPTS", def: "5", target: &c.LoginMaxAttempts},
+		{key: "LOGIN_IP_MAX_ATTEMPTS", def: "20", target: &c.LoginIPMaxAttempts},
+		{key: "LOGIN_LOCKOUT", def: "15m", target: &c.LoginLockout, legacy: "LOGIN_LOCKOUT_MINUTES", legacyUnit: time.Minute},
+		{key: "LOGIN_MEMORY_ENTRIES", def: "10000", target: &c.LoginMemoryEntries},
+		{key: "PASSWORD_RESET_TTL", def: "30m", target: &c.PasswordResetTTL, legacy: "PASSWORD_RESET_TTL_MINUTES", legacyUnit: time.Minute},
+		{key: "EMAIL_VERIFICATION_TTL", def: "48h", target: &c.EmailVerificationTTL, legacy: "EMAIL_VERIFICATION_TTL_HOURS", legacyUnit: time.Hour},
+		{key: "REQUIRE_EMAIL_VERIFICATION", def: "false", target: &c.RequireEmailVerification},
+		{key: "PASSWORD_MIN_LENGTH", def: "8", target: &c.PasswordMinLength},
+		{key: "PASSWORD_MAX_BYTES", def: "72", target: &c.PasswordMaxBytes},
+		{key: "PASSWORD_REQUIRE_UPPER", def: "false", target: &c.PasswordRequireUpper},
+		{key: "PASSWORD_REQUIRE_LOWER", def: "false", target: &c.PasswordRequireLower},
+		{key: "PASSWORD_REQUIRE_DIGIT", def: "false", target: &c.PasswordRequireDigit},
+		{key: "PASSWORD_REQUIRE_SYMBOL", def: "false", target: &c.PasswordRequireSymbol},
+		{key: "PASSWORD_BREACHED_FILE", target: &c.PasswordBreachedFile},
+		{key: "PASSWORD_HASH_ALGORITHM", def: "argon2id", target: &c.PasswordHashAlgorithm},
+		{key: "PASSWORD_BCRYPT_COST", def: "10", target: &c.PasswordBcryptCost},
+		{key: "PASSWORD_ARGON2_MEMORY_KB", def: "65536", target: &c.PasswordArgon2Memory},
+		{key: "PASSWORD_ARGON2_ITERATIONS", def: "3", target: &c.PasswordArgon2Time},
+		{key: "PASSWORD_ARGON2_PARALLELISM", def: "2", target: &c.PasswordArgon2Threads},
+		{key: "OIDC_PROVIDERS", target: &oidcNames{c}},
+		{key: "MFA_ISSUER", def: "Go-Microservice-Template", target: &c.MFAIssuer},
+		{key: "MFA_SECRET_KEY", def: devMFASecretKey, target: &c.MFASecretKey, secret: true},
+		{key: "MFA_CHALLENGE_TTL", def: "5m", target: &c.MFAChallengeTTL, legacy: "MFA_CHALLENGE_TTL_MINUTES", legacyUnit: time.Minute},
+		{key: "NOTIFIER", def: "log", target: &c.Notifier},
+		{key: "NOTIFIER_FILE", def: "notifications.log", target: &c.NotifierFile},
+		{key: "LOG_LEVEL", def: "info", target: &c.LogLevel},
+		{key: "SECRETS_REFRESH_INTERVAL", def: "1m", target: &c.SecretsRefreshInterval},
+	}
+}
+
+// oidcSettings lists the settings of the i-th OIDC provider, read from
+// OIDC_<NAME>_* keys.
+func (c *Config) oidcSettings(i int) []setting {
+	p := &c.OIDCProviders[i]
+	prefix := oidcPrefix(p.Name)
+	return []setting{
+		{key: prefix + "ISSUER_URL", target: &p.IssuerURL},
+		{key: prefix + "CLIENT_ID", target: &p.ClientID},
+		{key: prefix + "CLIENT_SECRET", target: &p.ClientSecret, secret: true},
+		{key: prefix + "REDIRECT_URL", def: c.PublicURL + "/api/v1/auth/oidc/" + p.Name + "/callback", target: &p.RedirectURL},
+		{key: prefix + "SCOPES", target: &p.Scopes},
+	}
+}
+
+func oidcPrefix(name string) string {
+	return "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+// oidcNames is the target of OIDC_PROVIDERS: it lists the names of
+// c.OIDCProviders, whose own settings are resolved afterwards.
+type oidcNames struct{ c *Config }
+
+func (c *Config) oidcProviderNames() []string {
+	names := make([]string, len(c.OIDCProviders))
+	for i, p := range c.OIDCProviders {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// set parses raw into the setting's field.
+func (s setting) set(raw string) error {
+	switch t := s.target.(type) {
+	case *string:
+		*t = raw
+	case *int:
+		v, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("not an integer")
+		}
+		*t = v
+	case *bool:
+		v, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("not a boolean")
+		}
+		*t = v
+	case *time.Duration:
+		v, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf(`not a duration such as "15s" or "24h"`)
+		}
+		*t = v
+	case *[]string:
+		*t = splitList(raw)
+	case *[]JWTKey:
+		keys, err := parseJWTKeys(raw)
+		if err != nil {
+			return err
+		}
+		*t = keys
+	case *oidcNames:
+		t.c.OIDCProviders = nil
+		for _, name := range splitList(strings.ToLower(raw)) {
+			t.c.OIDCProviders = append(t.c.OIDCProviders, OIDCProvider{Name: name})
+		}
+	default:
+		panic(fmt.Sprintf("config: setting %s has unsupported type %T", s.key, s.target))
+	}
+	return nil
+}
+
+// setLegacy parses raw as a bare number of s.legacyUnit.
+func (s setting) setLegacy(raw string) error {
+	v, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("not an integer")
+	}
+	*s.target.(*time.Duration) = time.Duration(v) * s.legacyUnit
+	return nil
+}
+
+// format renders the setting's current value in the form set accepts,
+// with secrets redacted.
+func (s setting) format() string {
+	v := s.raw()
+	if s.secret && v != "" {
+		return redacted
+	}
+	return v
+}
+
+// raw renders the setting's current value in the form set accepts.
+func (s setting) raw() string {
+	var v string
+	switch t := s.target.(type) {
+	case *string:
+		v = *t
+	case *int:
+		v = strconv.Itoa(*t)
+	case *bool:
+		v = strconv.FormatBool(*t)
+	case *time.Duration:
+		v = t.String()
+	case *[]string:
+		v = strings.Join(*t, ",")
+	case *[]JWTKey:
+		pairs := make([]string, len(*t))
+		for i, k := range *t {
+			pairs[i] = k.ID + "=" + k.Path
+		}
+		v = strings.Join(pairs, ",")
+	case *oidcNames:
+		v = strings.Join(t.c.oidcProviderNames(), ",")
+	}
+	return v
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ── Display ───────────────────────────────────────────────
+
+// Setting is one resolved configuration value, safe to display.
+type Setting struct {
+	Key    string
+	Value  string // "[REDACTED]" for secrets that are set
+	Source string // "default", "environment", "flag" or the config file
+}
+
+// Settings lists every setting with its value and where the value came
+// from. Secrets are redacted.
+func (c *Config) Settings() []Setting {
+	all := c.settings()
+	for i := range c.OIDCProviders {
+		all = append(all, c.oidcSettings(i)...)
+	}
+
+	out := make([]Setting, 0, len(all))
+	for _, s := range all {
+		source := c.origins[s.key]
+		if source == "" {
+			source = "default"
+		}
+		out = append(out, Setting{Key: s.key, Value: s.format(), Source: source})
+	}
+	return out
+}
+
+// Change is a setting whose value differs between two configurations.
+type Change struct {
+	Key string
+	Old string // "[REDACTED]" for secrets that are set
+	New string
+}
+
+// Diff lists the settings whose value differs in next, in the order of
+// Settings. Secrets are compared by value but redacted.
+func (c *Config) Diff(next *Config) []Change {
+	type value struct{ raw, shown string }
+	values := func(cfg *Config) ([]string, map[string]value) {
+		all := cfg.settings()
+		for i := range cfg.OIDCProviders {
+			all = append(all, cfg.oidcSettings(i)...)
+		}
+		keys := make([]string, len(all))
+		byKey := make(map[string]value, len(all))
+		for i, s := range all {
+			keys[i] = s.key
+			byKey[s.key] = value{raw: s.raw(), shown: s.format()}
+		}
+		return keys, byKey
+	}
+	oldKeys, oldValues := values(c)
+	newKeys, newValues := values(next)
+
+	var changes []Change
+	for _, key := range oldKeys {
+		o, n := oldValues[key], newValues[key]
+		if o.raw != n.raw {
+			changes = append(changes, Change{Key: key, Old: o.shown, New: n.shown})
+		}
+	}
+	// Settings of OIDC providers that were added
+	for _, key := range newKeys {
+		if _, ok := oldValues[key]; !ok && newValues[key].raw != "" {
+			changes = append(changes, Change{Key: key, New: newValues[key].shown})
+		}
+	}
+	return changes
+}
+
+// String renders the configuration as KEY=value pairs, with secrets
+// redacted, so that it can be logged or printed safely.
+func (c Config) String() string {
+	var b strings.Builder
+	for i, s := range c.Settings() {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(s.Key)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(s.Value))
+	}
+	return b.String()
+}
+
+// GoString keeps %#v from printing the secret fields.
+func (c Config) GoString() string {
+	return "config.Config{" + c.String() + "}"
+}
+
+// LogValue implements slog.LogValuer with secrets redacted.
+func (c Config) LogValue() slog.Value {
+	settings := c.Settings()
+	attrs := make([]slog.Attr, len(settings))
+	for i, s := range settings {
+		attrs[i] = slog.String(s.Key, s.Value)
+	}
+	return slog.GroupValue(attrs...)
+}
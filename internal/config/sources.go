@@ -0,0 +1,279 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Options selects the configuration sources besides the environment.
+type Options struct {
+	// File is a YAML or TOML file read beneath the environment. When empty,
+	// $CONFIG_FILE is used if set.
+	File string
+	// Overrides take precedence over every other source. Keys are setting
+	// names as in the environment, e.g. "APP_PORT".
+	Overrides map[string]string
+	// SecretProvider resolves the references in KEY_FILE settings. It
+	// defaults to FileSecretProvider, where references are file paths.
+	SecretProvider SecretProvider
+}
+
+// RegisterFlags adds -config and the repeatable -set KEY=VALUE to fs.
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.File, "config", "", "YAML or TOML configuration `file` (default $CONFIG_FILE)")
+	fs.Func("set", "override a setting as `KEY=VALUE`; may be repeated", func(v string) error {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("want KEY=VALUE")
+		}
+		if o.Overrides == nil {
+			o.Overrides = make(map[string]string)
+		}
+		o.Overrides[normalizeKey(key)] = value
+		return nil
+	})
+}
+
+// Ranks of the sources, from the lowest precedence.
+const (
+	rankUnset = iota
+	rankFile
+	rankEnv
+	rankFlag
+)
+
+// sources resolves setting keys in order of precedence: flags, then the
+// environment, then the config file.
+type sources struct {
+	overrides map[string]string
+	file      map[string]string
+	fileName  string
+	provider  SecretProvider
+}
+
+func newSources(opts Options) (*sources, error) {
+	src := &sources{overrides: opts.Overrides, provider: opts.SecretProvider}
+	if src.provider == nil {
+		src.provider = FileSecretProvider{}
+	}
+
+	path := opts.File
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		return src, nil
+	}
+
+	values, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	src.file = values
+	src.fileName = filepath.Base(path)
+
+	return src, nil
+}
+
+// lookup returns the value of key from the highest ranked source setting
+// it, or rankUnset.
+func (src *sources) lookup(key string) (value, origin string, rank int) {
+	if v, ok := src.overrides[key]; ok {
+		return v, "flag", rankFlag
+	}
+	if v := os.Getenv(key); v != "" {
+		return v, "environment", rankEnv
+	}
+	if v, ok := src.file[key]; ok {
+		return v, src.fileName, rankFile
+	}
+	return "", "", rankUnset
+}
+
+// apply resolves s into cfg and records its origin. A value that does not
+// parse is reported and replaced by the default, so that validation does
+// not report it a second time.
+func (src *sources) apply(s setting, cfg *Config) []error {
+	origins := cfg.origins
+	origins[s.key] = ""
+	if s.legacy != "" {
+		origins[s.legacy] = ""
+	}
+
+	raw, origin, rank := src.lookup(s.key)
+
+	// KEY_FILE wins over KEY set in a lower ranked source
+	if s.secret {
+		refKey := s.key + "_FILE"
+		origins[refKey] = ""
+		if ref, refOrigin, refRank := src.lookup(refKey); refRank != rankUnset {
+			if refRank == rank {
+				return []error{fmt.Errorf("%s and %s are both set (%s)", s.key, refKey, origin)}
+			}
+			if refRank > rank {
+				value, err := src.provider.Secret(context.Background(), ref)
+				if err != nil {
+					return []error{describe(refKey, ref, refOrigin, false, err)}
+				}
+				cfg.secretRefs[s.key] = ref
+				origins[s.key] = refOrigin + " (" + refKey + ")"
+				_ = s.set(value) // Secrets are strings
+				return nil
+			}
+		}
+	}
+
+	if rank == rankUnset && s.legacy != "" {
+		if raw, origin, rank := src.lookup(s.legacy); rank != rankUnset {
+			origins[s.key] = origin + " (" + s.legacy + ")"
+			if err := s.setLegacy(raw); err != nil {
+				_ = s.set(s.def)
+				return []error{describe(s.legacy, raw, origin, s.secret, err)}
+			}
+			return nil
+		}
+	}
+	if rank == rankUnset {
+		// Defaults always parse
+		_ = s.set(s.def)
+		return nil
+	}
+
+	origins[s.key] = origin
+	if err := s.set(raw); err != nil {
+		_ = s.set(s.def)
+		return []error{describe(s.key, raw, origin, s.secret, err)}
+	}
+	return nil
+}
+
+// unknown reports keys in the file or the overrides that name no setting,
+// which are most likely typos. known holds every key resolved by apply.
+func (src *sources) unknown(known map[string]string) []error {
+	var errs []error
+	check := func(values map[string]string, origin string) {
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			if _, ok := known[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			errs = append(errs, fmt.Errorf("%s (%s): unknown setting", key, origin))
+		}
+	}
+	check(src.file, src.fileName)
+	check(src.overrides, "flag")
+	return errs
+}
+
+func describe(key, raw, origin string, secret bool, err error) error {
+	if secret {
+		return fmt.Errorf("%s (%s): %w", key, origin, err)
+	}
+	return fmt.Errorf("%s=%q (%s): %w", key, raw, origin, err)
+}
+
+// normalizeKey maps a file or flag key to a setting name: "db-host" and
+// "db_host" become "DB_HOST".
+func normalizeKey(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(key), "-", "_"))
+}
+
+// ── Config Files ──────────────────────────────────────────
+
+// readFile reads a YAML or TOML file, chosen by extension, into setting
+// names and values. Nested tables are joined with underscores, so
+//
+//	db:
+//	  host: localhost
+//
+// sets DB_HOST, and lists become comma-separated values.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var tree map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q, want .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", tree, values); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]interface{}, out map[string]string) error {
+	for k, v := range tree {
+		key := normalizeKey(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch v := v.(type) {
+		case nil:
+			continue
+		case map[string]interface{}:
+			if err := flatten(key, v, out); err != nil {
+				return err
+			}
+			continue
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				s, err := scalar(item)
+				if err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				items[i] = s
+			}
+			out[key] = strings.Join(items, ",")
+		default:
+			s, err := scalar(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			out[key] = s
+		}
+	}
+	return nil
+}
+
+func scalar(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", v)
+	}
+}
@@ -1,13 +1,30 @@
 package handler
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	pb "Go-Microservice-Template/api/user"
+	"Go-Microservice-Template/internal/middleware"
+	"Go-Microservice-Template/internal/model"
 	"Go-Microservice-Template/internal/service"
+	"Go-Microservice-Template/internal/validation"
 
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // GRPCHandler handles gRPC requests.
 type GRPCHandler struct {
+	pb.UnimplementedUserServiceServer
 	userService service.UserService
 }
 
@@ -17,9 +34,317 @@ func NewGRPCHandler(us service.UserService) *GRPCHandler {
 }
 
 // Register registers gRPC services with the server.
-// NOTE: After generating protobuf code with `make proto`,
-// uncomment the service registration below.
 func (h *GRPCHandler) Register(server *grpc.Server) {
-	// pb.RegisterUserServiceServer(server, h)
+	pb.RegisterUserServiceServer(server, h)
 	// Add more service registrations here
 }
+
+// MethodRules returns the authorization rules for every RPC served by h.
+// Callers may read and update their own account; everything else requires
+// the matching permission.
+func (h *GRPCHandler) MethodRules() map[string]middleware.GRPCMethodRule {
+	return map[string]middleware.GRPCMethodRule{
+		pb.UserService_CreateUser_FullMethodName: {Permission: model.PermUsersWrite},
+		pb.UserService_GetUser_FullMethodName:    {Permission: model.PermUsersRead, AllowSelf: true},
+		pb.UserService_UpdateUser_FullMethodName: {Permission: model.PermUsersWrite, AllowSelf: true},
+		pb.UserService_DeleteUser_FullMethodName: {Permission: model.PermUsersDelete},
+		pb.UserService_ListUsers_FullMethodName:  {Permission: model.PermUsersRead},
+
+		pb.UserService_ForgotPassword_FullMethodName: {Public: true},
+		pb.UserService_ResetPassword_FullMethodName:  {Public: true},
+
+		// Self-service methods act on the caller and need no permission,
+		// except from API keys, which are limited to their scopes and may
+		// not change the account's email or password
+		pb.UserService_GetMe_FullMethodName:          {APIKeyPermission: model.PermMeRead},
+		pb.UserService_UpdateMe_FullMethodName:       {DenyAPIKeys: true},
+		pb.UserService_ChangePassword_FullMethodName: {DenyAPIKeys: true},
+	}
+}
+
+// ── UserService RPCs ──────────────────────────────────────
+
+// CreateUser creates a new user account.
+func (h *GRPCHandler) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.UserResponse, error) {
+	in := model.CreateUserRequest{
+		Email:    req.GetEmail(),
+		Name:     req.GetName(),
+		Password: req.GetPassword(),
+	}
+	if err := validateRPC(ctx, in); err != nil {
+		return nil, err
+	}
+
+	user, err := h.userService.Register(ctx, in)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "create user")
+	}
+
+	return toUserResponse(user), nil
+}
+
+// GetUser retrieves a user by ID.
+func (h *GRPCHandler) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.UserResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, grpcProblem(ctx, errInvalidUserID)
+	}
+
+	user, err := h.userService.GetByID(ctx, id)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "get user")
+	}
+
+	return toUserResponse(user), nil
+}
+
+// UpdateUser applies a partial update to an existing user.
+func (h *GRPCHandler) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UserResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, grpcProblem(ctx, errInvalidUserID)
+	}
+
+	in := model.UpdateUserRequest{
+		Email: req.Email,
+		Name:  req.Name,
+	}
+	if err := validateRPC(ctx, in); err != nil {
+		return nil, err
+	}
+	if in.Email != nil && middleware.IsAPIKey(ctx) {
+		return nil, grpcProblem(ctx, errKeyChangesEmail)
+	}
+
+	user, err := h.userService.Update(ctx, id, in)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "update user")
+	}
+
+	return toUserResponse(user), nil
+}
+
+// DeleteUser soft-deletes a user.
+func (h *GRPCHandler) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, grpcProblem(ctx, errInvalidUserID)
+	}
+
+	if err := h.userService.Delete(ctx, id); err != nil {
+		return nil, toGRPCError(ctx, err, "delete user")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ListUsers returns a paginated list of users.
+func (h *GRPCHandler) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	params := model.DefaultListParams()
+
+	if p := int(req.GetPage()); p > 0 {
+		params.Page = p
+	}
+	if ps := int(req.GetPageSize()); ps > 0 && ps <= 100 {
+		params.PageSize = ps
+	}
+	if v := req.GetSearch(); v != "" {
+		params.Search = v
+	}
+	if v := req.GetSortBy(); v != "" {
+		params.SortBy = v
+	}
+	if v := req.GetSortDir(); v != "" {
+		params.SortDir = v
+	}
+
+	result, err := h.userService.List(ctx, params)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "list users")
+	}
+
+	users := make([]*pb.UserResponse, 0, len(result.Items))
+	for i := range result.Items {
+		users = append(users, toUserResponse(&result.Items[i]))
+	}
+
+	return &pb.ListUsersResponse{
+		Users:      users,
+		Total:      result.Total,
+		Page:       int32(result.Page),
+		PageSize:   int32(result.PageSize),
+		TotalPages: int32(result.TotalPages),
+	}, nil
+}
+
+// ForgotPassword sends a password reset token if the account exists.
+func (h *GRPCHandler) ForgotPassword(ctx context.Context, req *pb.ForgotPasswordRequest) (*emptypb.Empty, error) {
+	in := model.ForgotPasswordRequest{Email: req.GetEmail()}
+	if err := validateRPC(ctx, in); err != nil {
+		return nil, err
+	}
+
+	if err := h.userService.ForgotPassword(ctx, in.Email); err != nil {
+		return nil, toGRPCError(ctx, err, "forgot password")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ResetPassword sets a new password using a reset token.
+func (h *GRPCHandler) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*emptypb.Empty, error) {
+	in := model.ResetPasswordRequest{Token: req.GetToken(), Password: req.GetPassword()}
+	if err := validateRPC(ctx, in); err != nil {
+		return nil, err
+	}
+
+	if err := h.userService.ResetPassword(ctx, in.Token, in.Password); err != nil {
+		return nil, toGRPCError(ctx, err, "reset password")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// GetMe returns the authenticated caller's own account.
+func (h *GRPCHandler) GetMe(ctx context.Context, _ *emptypb.Empty) (*pb.UserResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, grpcProblem(ctx, service.ErrUnauthenticated)
+	}
+
+	user, err := h.userService.GetByID(ctx, userID)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "get me")
+	}
+
+	return toUserResponse(user), nil
+}
+
+// UpdateMe updates the caller's own name or email.
+func (h *GRPCHandler) UpdateMe(ctx context.Context, req *pb.UpdateMeRequest) (*pb.UserResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, grpcProblem(ctx, service.ErrUnauthenticated)
+	}
+
+	in := model.UpdateUserRequest{
+		Email: req.Email,
+		Name:  req.Name,
+	}
+	if err := validateRPC(ctx, in); err != nil {
+		return nil, err
+	}
+
+	user, err := h.userService.Update(ctx, userID, in)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "update me")
+	}
+
+	return toUserResponse(user), nil
+}
+
+// ChangePassword changes the caller's password and returns a fresh session;
+// every other session is signed out.
+func (h *GRPCHandler) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.SessionResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, grpcProblem(ctx, service.ErrUnauthenticated)
+	}
+
+	in := model.ChangePasswordRequest{
+		CurrentPassword: req.GetCurrentPassword(),
+		NewPassword:     req.GetNewPassword(),
+	}
+	if err := validateRPC(ctx, in); err != nil {
+		return nil, err
+	}
+
+	resp, err := h.userService.ChangePassword(ctx, userID, in)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "change password")
+	}
+
+	return toSessionResponse(resp), nil
+}
+
+// ── Conversion Helpers ────────────────────────────────────
+
+func toUserResponse(u *model.User) *pb.UserResponse {
+	return &pb.UserResponse{
+		Id:        u.ID.String(),
+		Email:     u.Email,
+		Name:      u.Name,
+		Role:      string(u.Role),
+		Active:    u.Active,
+		CreatedAt: timestamppb.New(u.CreatedAt),
+		UpdatedAt: timestamppb.New(u.UpdatedAt),
+	}
+}
+
+func toSessionResponse(r *model.LoginResponse) *pb.SessionResponse {
+	return &pb.SessionResponse{
+		Token:            r.Token,
+		ExpiresAt:        timestamppb.New(r.ExpiresAt),
+		RefreshToken:     r.RefreshToken,
+		RefreshExpiresAt: timestamppb.New(r.RefreshExpiresAt),
+		User:             toUserResponse(&r.User),
+	}
+}
+
+// validateRPC checks a request DTO against its `validate` tags. Invalid
+// fields are reported as InvalidArgument with a BadRequest detail, using the
+// same field names and messages as the REST API.
+func validateRPC(ctx context.Context, v interface{}) error {
+	if err := validation.Struct(v); err != nil {
+		return toGRPCError(ctx, err, "validate request")
+	}
+	return nil
+}
+
+// toGRPCError reports err as a status carrying the same error code as the
+// REST API. Errors the service layer does not recognize are logged as op
+// failures and reported as Internal so that details never leak to clients.
+func toGRPCError(ctx context.Context, err error, op string) error {
+	appErr := service.AsError(err)
+	if appErr.HTTPStatus >= http.StatusInternalServerError {
+		log.Error().Err(err).Str("trace_id", middleware.TraceIDFromContext(ctx)).Msg(op + " failed")
+	}
+	return grpcProblem(ctx, appErr)
+}
+
+// grpcProblem converts an application error to a status. Invalid fields
+// become a BadRequest detail, RetryAfter a RetryInfo, and other details
+// ErrorInfo metadata.
+func grpcProblem(ctx context.Context, e *service.Error) error {
+	var details []protoadapt.MessageV1
+
+	if len(e.Fields) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, f := range e.Fields {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Description: f.Message,
+				Reason:      strings.ToUpper(f.Rule),
+			})
+		}
+		details = append(details, br)
+	}
+
+	if e.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryAfter)})
+	}
+
+	var metadata map[string]string
+	if len(e.Details) > 0 {
+		metadata = make(map[string]string, len(e.Details))
+		for k, v := range e.Details {
+			if list, ok := v.([]string); ok {
+				metadata[k] = strings.Join(list, "; ")
+			} else {
+				metadata[k] = fmt.Sprint(v)
+			}
+		}
+	}
+
+	return middleware.GRPCError(ctx, e.GRPCCode, e.Code, e.Message, metadata, details...)
+}
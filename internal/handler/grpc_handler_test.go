@@ -0,0 +1,716 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	pb "Go-Microservice-Template/api/user"
+	"Go-Microservice-Template/internal/middleware"
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/repository"
+	"Go-Microservice-Template/internal/service"
+	"Go-Microservice-Template/internal/validation"
+
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeUserService answers every call with user, or with err if set, and
+// records the last call.
+type fakeUserService struct {
+	service.UserService
+
+	user *model.User
+	err  error
+
+	called string
+	gotID  uuid.UUID
+	gotReq interface{}
+}
+
+func (f *fakeUserService) record(method string, id uuid.UUID, req interface{}) {
+	f.called, f.gotID, f.gotReq = method, id, req
+}
+
+func (f *fakeUserService) result() (*model.User, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.user, nil
+}
+
+func (f *fakeUserService) Register(_ context.Context, req model.CreateUserRequest) (*model.User, error) {
+	f.record("Register", uuid.Nil, req)
+	return f.result()
+}
+
+func (f *fakeUserService) GetByID(_ context.Context, id uuid.UUID) (*model.User, error) {
+	f.record("GetByID", id, nil)
+	return f.result()
+}
+
+func (f *fakeUserService) Update(_ context.Context, id uuid.UUID, req model.UpdateUserRequest) (*model.User, error) {
+	f.record("Update", id, req)
+	return f.result()
+}
+
+func (f *fakeUserService) Delete(_ context.Context, id uuid.UUID) error {
+	f.record("Delete", id, nil)
+	return f.err
+}
+
+func (f *fakeUserService) List(_ context.Context, params model.ListParams) (*model.ListResponse[model.User], error) {
+	f.record("List", uuid.Nil, params)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &model.ListResponse[model.User]{Items: []model.User{*f.user}, Total: 1, Page: params.Page, PageSize: params.PageSize, TotalPages: 1}, nil
+}
+
+func (f *fakeUserService) Login(_ context.Context, _ model.LoginRequest, clientIP string) (*model.LoginResponse, error) {
+	f.record("Login", uuid.Nil, clientIP)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &model.LoginResponse{Token: "access", RefreshToken: "refresh", User: *f.user}, nil
+}
+
+func (f *fakeUserService) ForgotPassword(_ context.Context, email string) error {
+	f.record("ForgotPassword", uuid.Nil, email)
+	return f.err
+}
+
+func (f *fakeUserService) ResetPassword(_ context.Context, token, password string) error {
+	f.record("ResetPassword", uuid.Nil, token)
+	return f.err
+}
+
+func (f *fakeUserService) ChangePassword(_ context.Context, id uuid.UUID, req model.ChangePasswordRequest) (*model.LoginResponse, error) {
+	f.record("ChangePassword", id, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &model.LoginResponse{Token: "access", RefreshToken: "refresh", User: *f.user}, nil
+}
+
+// errorInfo returns the ErrorInfo detail of st, or nil.
+func errorInfo(st *status.Status) *errdetails.ErrorInfo {
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+func TestGRPCHandlerUserRPCs(t *testing.T) {
+	id := uuid.New()
+	now := time.Now().UTC()
+	user := &model.User{ID: id, Email: "user@example.com", Name: "User", Role: model.RoleUser, Active: true, CreatedAt: now, UpdatedAt: now}
+	// An unrecognized error whose text must not reach clients
+	dbErr := errors.New("dial tcp 10.0.0.5:5432: connect: connection refused")
+	caller := context.WithValue(context.Background(), middleware.UserIDKey, id.String())
+	name := "Renamed"
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		svcErr error
+		call   func(ctx context.Context, h *GRPCHandler) (interface{}, error)
+		// wantCall is the service method expected to be called, "" for none
+		wantCall   string
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{
+			name: "create user",
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.CreateUser(ctx, &pb.CreateUserRequest{Email: "user@example.com", Name: "User", Password: "correct-horse-battery"})
+			},
+			wantCall: "Register",
+			wantCode: codes.OK,
+		},
+		{
+			name: "create user with an invalid email",
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.CreateUser(ctx, &pb.CreateUserRequest{Email: "not-an-email", Name: "User", Password: "correct-horse-battery"})
+			},
+			wantCode:   codes.InvalidArgument,
+			wantReason: "VALIDATION_FAILED",
+		},
+		{
+			name:   "create user with a taken email",
+			svcErr: fmt.Errorf("%w: %w", service.ErrEmailTaken, repository.ErrDuplicate),
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.CreateUser(ctx, &pb.CreateUserRequest{Email: "user@example.com", Name: "User", Password: "correct-horse-battery"})
+			},
+			wantCall:   "Register",
+			wantCode:   codes.AlreadyExists,
+			wantReason: "EMAIL_TAKEN",
+		},
+		{
+			name: "get user",
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.GetUser(ctx, &pb.GetUserRequest{Id: id.String()})
+			},
+			wantCall: "GetByID",
+			wantCode: codes.OK,
+		},
+		{
+			name: "get user with a malformed ID",
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.GetUser(ctx, &pb.GetUserRequest{Id: "42"})
+			},
+			wantCode:   codes.InvalidArgument,
+			wantReason: "BAD_REQUEST",
+		},
+		{
+			name:   "get a missing user",
+			svcErr: repository.ErrNotFound,
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.GetUser(ctx, &pb.GetUserRequest{Id: id.String()})
+			},
+			wantCall:   "GetByID",
+			wantCode:   codes.NotFound,
+			wantReason: "NOT_FOUND",
+		},
+		{
+			name:   "get user on a database failure",
+			svcErr: dbErr,
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.GetUser(ctx, &pb.GetUserRequest{Id: id.String()})
+			},
+			wantCall:   "GetByID",
+			wantCode:   codes.Internal,
+			wantReason: "INTERNAL",
+		},
+		{
+			name: "update user",
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.UpdateUser(ctx, &pb.UpdateUserRequest{Id: id.String(), Name: &name})
+			},
+			wantCall: "Update",
+			wantCode: codes.OK,
+		},
+		{
+			name:   "update user to a taken email",
+			svcErr: repository.ErrDuplicate,
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				email := "taken@example.com"
+				return h.UpdateUser(ctx, &pb.UpdateUserRequest{Id: id.String(), Email: &email})
+			},
+			wantCall:   "Update",
+			wantCode:   codes.AlreadyExists,
+			wantReason: "ALREADY_EXISTS",
+		},
+		{
+			name: "delete user",
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.DeleteUser(ctx, &pb.DeleteUserRequest{Id: id.String()})
+			},
+			wantCall: "Delete",
+			wantCode: codes.OK,
+		},
+		{
+			name:   "delete a missing user",
+			svcErr: repository.ErrNotFound,
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.DeleteUser(ctx, &pb.DeleteUserRequest{Id: id.String()})
+			},
+			wantCall:   "Delete",
+			wantCode:   codes.NotFound,
+			wantReason: "NOT_FOUND",
+		},
+		{
+			name: "list users",
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.ListUsers(ctx, &pb.ListUsersRequest{Page: 2, PageSize: 10})
+			},
+			wantCall: "List",
+			wantCode: codes.OK,
+		},
+		{
+			name:   "list users on a database failure",
+			svcErr: fmt.Errorf("list users: %w", dbErr),
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.ListUsers(ctx, &pb.ListUsersRequest{})
+			},
+			wantCall:   "List",
+			wantCode:   codes.Internal,
+			wantReason: "INTERNAL",
+		},
+		{
+			name: "forgot password",
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.ForgotPassword(ctx, &pb.ForgotPasswordRequest{Email: "user@example.com"})
+			},
+			wantCall: "ForgotPassword",
+			wantCode: codes.OK,
+		},
+		{
+			name:   "reset password with an expired token",
+			svcErr: service.ErrInvalidResetToken,
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.ResetPassword(ctx, &pb.ResetPasswordRequest{Token: "expired", Password: "new-correct-horse"})
+			},
+			wantCall:   "ResetPassword",
+			wantCode:   codes.InvalidArgument,
+			wantReason: "INVALID_RESET_TOKEN",
+		},
+		{
+			name: "get me",
+			ctx:  caller,
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.GetMe(ctx, &emptypb.Empty{})
+			},
+			wantCall: "GetByID",
+			wantCode: codes.OK,
+		},
+		{
+			name: "get me without a caller",
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.GetMe(ctx, &emptypb.Empty{})
+			},
+			wantCode:   codes.Unauthenticated,
+			wantReason: "UNAUTHENTICATED",
+		},
+		{
+			name: "update me",
+			ctx:  caller,
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.UpdateMe(ctx, &pb.UpdateMeRequest{Name: &name})
+			},
+			wantCall: "Update",
+			wantCode: codes.OK,
+		},
+		{
+			name:   "change password with the wrong current password",
+			ctx:    caller,
+			svcErr: service.ErrIncorrectPassword,
+			call: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "new-correct-horse"})
+			},
+			wantCall:   "ChangePassword",
+			wantCode:   codes.PermissionDenied,
+			wantReason: "INCORRECT_PASSWORD",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			svc := &fakeUserService{user: user, err: tt.svcErr}
+
+			resp, err := tt.call(ctx, NewGRPCHandler(svc))
+
+			if svc.called != tt.wantCall {
+				t.Errorf("service called %q, want %q", svc.called, tt.wantCall)
+			}
+			if tt.wantCall != "" && svc.gotID != uuid.Nil && svc.gotID != id {
+				t.Errorf("service called for %s, want %s", svc.gotID, id)
+			}
+			st := status.Convert(err)
+			if st.Code() != tt.wantCode {
+				t.Fatalf("code = %s, want %s: %v", st.Code(), tt.wantCode, err)
+			}
+			if tt.wantCode == codes.OK {
+				if resp == nil {
+					t.Error("no response")
+				}
+				return
+			}
+
+			info := errorInfo(st)
+			if info == nil || info.Reason != tt.wantReason || info.Domain != middleware.ErrorDomain {
+				t.Errorf("ErrorInfo = %v, want reason %s in %s", info, tt.wantReason, middleware.ErrorDomain)
+			}
+			if tt.wantCode == codes.Internal {
+				if st.Message() != service.ErrInternal.Message {
+					t.Errorf("message = %q, want %q", st.Message(), service.ErrInternal.Message)
+				}
+				if strings.Contains(fmt.Sprint(st.Proto()), "10.0.0.5") {
+					t.Errorf("status leaks the underlying error: %v", st.Proto())
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCHandlerConvertsResponses(t *testing.T) {
+	id := uuid.New()
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := &model.User{ID: id, Email: "user@example.com", Name: "User", Role: model.RoleAdmin, Active: true, CreatedAt: created, UpdatedAt: created.Add(time.Hour)}
+	svc := &fakeUserService{user: user}
+	h := NewGRPCHandler(svc)
+
+	got, err := h.GetUser(context.Background(), &pb.GetUserRequest{Id: id.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetId() != id.String() || got.GetEmail() != user.Email || got.GetName() != user.Name ||
+		got.GetRole() != "admin" || !got.GetActive() ||
+		!got.GetCreatedAt().AsTime().Equal(user.CreatedAt) || !got.GetUpdatedAt().AsTime().Equal(user.UpdatedAt) {
+		t.Errorf("GetUser = %v, want %+v", got, user)
+	}
+
+	// Out-of-range page sizes keep the default
+	list, err := h.ListUsers(context.Background(), &pb.ListUsersRequest{Page: 3, PageSize: 500, Search: "user", SortBy: "email", SortDir: "asc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := svc.gotReq.(model.ListParams)
+	want := model.DefaultListParams()
+	want.Page, want.Search, want.SortBy, want.SortDir = 3, "user", "email", "asc"
+	if params != want {
+		t.Errorf("list params = %+v, want %+v", params, want)
+	}
+	if len(list.GetUsers()) != 1 || list.GetTotal() != 1 || list.GetPage() != 3 || list.GetPageSize() != int32(want.PageSize) {
+		t.Errorf("ListUsers = %v", list)
+	}
+}
+
+func TestValidationErrorsMatchAcrossTransports(t *testing.T) {
+	const email, name = "not-an-email", "x"
+	svc := &fakeUserService{}
+
+	// REST reports the fields as a problem extension
+	body := fmt.Sprintf(`{"email":%q,"name":%q}`, email, name)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	NewHTTPHandler(svc, nil, nil, nil).Register(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("REST status = %d, want %d; body: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+	var problem struct {
+		Code   string                  `json:"code"`
+		Fields []validation.FieldError `json:"fields"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v; body: %s", err, rec.Body)
+	}
+
+	// gRPC reports them as a BadRequest detail
+	_, err := NewGRPCHandler(svc).CreateUser(context.Background(), &pb.CreateUserRequest{Email: email, Name: name})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("gRPC code = %s, want %s", st.Code(), codes.InvalidArgument)
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			violations = br.GetFieldViolations()
+		}
+	}
+
+	want := []validation.FieldError{
+		{Field: "email", Rule: "email", Message: "must be a valid email address"},
+		{Field: "name", Rule: "min", Param: "2", Message: "must be at least 2 characters"},
+		{Field: "password", Rule: "required", Message: "is required"},
+	}
+	if !reflect.DeepEqual(problem.Fields, want) {
+		t.Errorf("REST fields = %+v, want %+v", problem.Fields, want)
+	}
+	if len(violations) != len(want) {
+		t.Fatalf("gRPC field violations = %v, want %d", violations, len(want))
+	}
+	for i, v := range violations {
+		if v.GetField() != want[i].Field || v.GetDescription() != want[i].Message || v.GetReason() != strings.ToUpper(want[i].Rule) {
+			t.Errorf("gRPC violation %d = %v, want %+v", i, v, want[i])
+		}
+	}
+	if info := errorInfo(st); problem.Code != "validation_failed" || info == nil || info.Reason != strings.ToUpper(problem.Code) {
+		t.Errorf("REST code %q and gRPC ErrorInfo %v differ", problem.Code, info)
+	}
+	if svc.called != "" {
+		t.Errorf("service %s called with an invalid request", svc.called)
+	}
+}
+
+func TestGRPCProblem(t *testing.T) {
+	ctx := context.WithValue(context.Background(), middleware.TraceIDKey, "trace-1")
+
+	tests := []struct {
+		name         string
+		err          *service.Error
+		wantCode     codes.Code
+		wantMetadata map[string]string
+		wantFields   []*errdetails.BadRequest_FieldViolation
+		wantRetry    time.Duration
+	}{
+		{
+			name:     "lockout",
+			err:      service.AsError(&service.LockedError{Until: time.Now().Add(time.Minute)}),
+			wantCode: codes.ResourceExhausted,
+			// Less the time the test has taken so far
+			wantRetry: time.Minute,
+		},
+		{
+			name:         "password policy",
+			err:          service.AsError(&service.PasswordPolicyError{Violations: []string{"too short", "too common"}}),
+			wantCode:     codes.InvalidArgument,
+			wantMetadata: map[string]string{"violations": "too short; too common"},
+		},
+		{
+			name:         "scalar detail",
+			err:          service.ErrForbidden.WithDetail("required", 2),
+			wantCode:     codes.PermissionDenied,
+			wantMetadata: map[string]string{"required": "2"},
+		},
+		{
+			name: "validation",
+			err: service.AsError(&validation.Error{Fields: []validation.FieldError{
+				{Field: "email", Rule: "email", Message: "must be a valid email address"},
+				{Field: "name", Rule: "min", Param: "2", Message: "must be at least 2 characters"},
+			}}),
+			wantCode: codes.InvalidArgument,
+			wantFields: []*errdetails.BadRequest_FieldViolation{
+				{Field: "email", Description: "must be a valid email address", Reason: "EMAIL"},
+				{Field: "name", Description: "must be at least 2 characters", Reason: "MIN"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := status.Convert(grpcProblem(ctx, tt.err))
+
+			if st.Code() != tt.wantCode || st.Message() != tt.err.Message {
+				t.Errorf("status = %s %q, want %s %q", st.Code(), st.Message(), tt.wantCode, tt.err.Message)
+			}
+
+			var (
+				info      *errdetails.ErrorInfo
+				fields    []*errdetails.BadRequest_FieldViolation
+				retry     *errdetails.RetryInfo
+				requestID string
+			)
+			for _, d := range st.Details() {
+				switch d := d.(type) {
+				case *errdetails.ErrorInfo:
+					info = d
+				case *errdetails.BadRequest:
+					fields = d.GetFieldViolations()
+				case *errdetails.RetryInfo:
+					retry = d
+				case *errdetails.RequestInfo:
+					requestID = d.GetRequestId()
+				}
+			}
+
+			if info == nil || info.GetReason() != strings.ToUpper(tt.err.Code) || info.GetDomain() != middleware.ErrorDomain {
+				t.Errorf("ErrorInfo = %v, want reason %s", info, strings.ToUpper(tt.err.Code))
+			}
+			if len(info.GetMetadata()) != len(tt.wantMetadata) {
+				t.Errorf("metadata = %v, want %v", info.GetMetadata(), tt.wantMetadata)
+			}
+			for k, v := range tt.wantMetadata {
+				if info.GetMetadata()[k] != v {
+					t.Errorf("metadata %s = %q, want %q", k, info.GetMetadata()[k], v)
+				}
+			}
+			if len(fields) != len(tt.wantFields) {
+				t.Fatalf("field violations = %v, want %v", fields, tt.wantFields)
+			}
+			for i, f := range fields {
+				w := tt.wantFields[i]
+				if f.GetField() != w.GetField() || f.GetDescription() != w.GetDescription() || f.GetReason() != w.GetReason() {
+					t.Errorf("field violation %d = %v, want %v", i, f, w)
+				}
+			}
+			if tt.wantRetry == 0 && retry != nil {
+				t.Errorf("unexpected RetryInfo %v", retry)
+			}
+			if tt.wantRetry > 0 {
+				if d := retry.GetRetryDelay().AsDuration(); d <= tt.wantRetry-time.Second || d > tt.wantRetry {
+					t.Errorf("retry delay = %s, want about %s", d, tt.wantRetry)
+				}
+			}
+			if requestID != "trace-1" {
+				t.Errorf("RequestInfo id = %q, want the trace ID", requestID)
+			}
+		})
+	}
+}
+
+func TestSelfServiceMatchesAcrossTransports(t *testing.T) {
+	id := uuid.New()
+	user := &model.User{ID: id, Email: "user@example.com", Name: "User", Role: model.RoleUser, Active: true}
+	name := "Renamed"
+
+	tests := []struct {
+		name          string
+		anonymous     bool
+		svcErr        error
+		method, path  string
+		body          string
+		rpc           func(ctx context.Context, h *GRPCHandler) (interface{}, error)
+		wantCall      string
+		wantStatus    int
+		wantCode      string
+		wantGRPCCode  codes.Code
+		wantViolation string
+	}{
+		{
+			name: "get me", method: http.MethodGet, path: "/api/v1/me",
+			rpc: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.GetMe(ctx, &emptypb.Empty{})
+			},
+			wantCall: "GetByID", wantStatus: http.StatusOK, wantGRPCCode: codes.OK,
+		},
+		{
+			name: "get me without a caller", anonymous: true, method: http.MethodGet, path: "/api/v1/me",
+			rpc: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.GetMe(ctx, &emptypb.Empty{})
+			},
+			wantStatus: http.StatusUnauthorized, wantCode: "unauthenticated", wantGRPCCode: codes.Unauthenticated,
+		},
+		{
+			name: "update me", method: http.MethodPut, path: "/api/v1/me", body: `{"name":"Renamed"}`,
+			rpc: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.UpdateMe(ctx, &pb.UpdateMeRequest{Name: &name})
+			},
+			wantCall: "Update", wantStatus: http.StatusOK, wantGRPCCode: codes.OK,
+		},
+		{
+			name: "update me to a taken email", svcErr: service.ErrEmailTaken,
+			method: http.MethodPut, path: "/api/v1/me", body: `{"email":"taken@example.com"}`,
+			rpc: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				email := "taken@example.com"
+				return h.UpdateMe(ctx, &pb.UpdateMeRequest{Email: &email})
+			},
+			wantCall: "Update", wantStatus: http.StatusConflict, wantCode: "email_taken", wantGRPCCode: codes.AlreadyExists,
+		},
+		{
+			name:   "change password",
+			method: http.MethodPost, path: "/api/v1/me/password", body: `{"current_password":"correct-horse-battery","new_password":"new-horse-battery"}`,
+			rpc: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentPassword: "correct-horse-battery", NewPassword: "new-horse-battery"})
+			},
+			wantCall: "ChangePassword", wantStatus: http.StatusOK, wantGRPCCode: codes.OK,
+		},
+		{
+			name: "change password with the wrong current password", svcErr: service.ErrIncorrectPassword,
+			method: http.MethodPost, path: "/api/v1/me/password", body: `{"current_password":"wrong","new_password":"new-horse-battery"}`,
+			rpc: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "new-horse-battery"})
+			},
+			wantCall: "ChangePassword", wantStatus: http.StatusForbidden, wantCode: "incorrect_password", wantGRPCCode: codes.PermissionDenied,
+		},
+		{
+			name: "change password to one the policy rejects", svcErr: &service.PasswordPolicyError{Violations: []string{"must be at least 8 characters"}},
+			method: http.MethodPost, path: "/api/v1/me/password", body: `{"current_password":"correct-horse-battery","new_password":"short"}`,
+			rpc: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentPassword: "correct-horse-battery", NewPassword: "short"})
+			},
+			wantCall: "ChangePassword", wantStatus: http.StatusBadRequest, wantCode: "weak_password", wantGRPCCode: codes.InvalidArgument,
+		},
+		{
+			name:   "change password without a new one",
+			method: http.MethodPost, path: "/api/v1/me/password", body: `{"current_password":"correct-horse-battery"}`,
+			rpc: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentPassword: "correct-horse-battery"})
+			},
+			wantStatus: http.StatusBadRequest, wantCode: "validation_failed", wantGRPCCode: codes.InvalidArgument,
+			wantViolation: "new_password",
+		},
+		{
+			name: "change password without a caller", anonymous: true,
+			method: http.MethodPost, path: "/api/v1/me/password", body: `{"current_password":"correct-horse-battery","new_password":"new-horse-battery"}`,
+			rpc: func(ctx context.Context, h *GRPCHandler) (interface{}, error) {
+				return h.ChangePassword(ctx, &pb.ChangePasswordRequest{CurrentPassword: "correct-horse-battery", NewPassword: "new-horse-battery"})
+			},
+			wantStatus: http.StatusUnauthorized, wantCode: "unauthenticated", wantGRPCCode: codes.Unauthenticated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if !tt.anonymous {
+				ctx = context.WithValue(ctx, middleware.UserIDKey, id.String())
+			}
+
+			// REST
+			httpSvc := &fakeUserService{user: user, err: tt.svcErr}
+			h := NewHTTPHandler(httpSvc, nil, nil, nil)
+			route := map[string]http.HandlerFunc{
+				http.MethodGet:  h.GetMe,
+				http.MethodPut:  h.UpdateMe,
+				http.MethodPost: h.ChangePassword,
+			}[tt.method]
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)).WithContext(ctx)
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			route(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("REST status = %d, want %d; body: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v; body: %s", err, rec.Body)
+			}
+
+			// gRPC
+			grpcSvc := &fakeUserService{user: user, err: tt.svcErr}
+			resp, err := tt.rpc(ctx, NewGRPCHandler(grpcSvc))
+			st := status.Convert(err)
+			if st.Code() != tt.wantGRPCCode {
+				t.Errorf("gRPC code = %s, want %s", st.Code(), tt.wantGRPCCode)
+			}
+
+			// Both act on the caller's own account
+			for transport, svc := range map[string]*fakeUserService{"REST": httpSvc, "gRPC": grpcSvc} {
+				if svc.called != tt.wantCall {
+					t.Errorf("%s called %q, want %q", transport, svc.called, tt.wantCall)
+				}
+				if tt.wantCall != "" && svc.gotID != id {
+					t.Errorf("%s acted on %s, want the caller %s", transport, svc.gotID, id)
+				}
+			}
+			if !reflect.DeepEqual(httpSvc.gotReq, grpcSvc.gotReq) {
+				t.Errorf("service requests differ: REST %+v, gRPC %+v", httpSvc.gotReq, grpcSvc.gotReq)
+			}
+
+			if tt.wantCode == "" {
+				// The session returned is the one the service issued
+				if session, ok := resp.(*pb.SessionResponse); ok {
+					if body["token"] != session.GetToken() || body["refresh_token"] != session.GetRefreshToken() || session.GetToken() != "access" {
+						t.Errorf("sessions differ: REST %v, gRPC %v", body, session)
+					}
+				}
+				return
+			}
+			info := errorInfo(st)
+			if body["code"] != tt.wantCode || info == nil || info.GetReason() != strings.ToUpper(tt.wantCode) {
+				t.Errorf("REST code %v and gRPC ErrorInfo %v, want %s", body["code"], info, tt.wantCode)
+			}
+			if tt.wantViolation != "" {
+				fields, _ := body["fields"].([]interface{})
+				var violations []*errdetails.BadRequest_FieldViolation
+				for _, d := range st.Details() {
+					if br, ok := d.(*errdetails.BadRequest); ok {
+						violations = br.GetFieldViolations()
+					}
+				}
+				if len(fields) != 1 || fields[0].(map[string]interface{})["field"] != tt.wantViolation ||
+					len(violations) != 1 || violations[0].GetField() != tt.wantViolation {
+					t.Errorf("REST fields %v and gRPC violations %v, want %s", fields, violations, tt.wantViolation)
+				}
+			}
+		})
+	}
+}
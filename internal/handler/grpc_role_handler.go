@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"context"
+
+	pb "Go-Microservice-Template/api/user"
+	"Go-Microservice-Template/internal/middleware"
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/service"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCRoleHandler handles gRPC requests for role and permission management.
+type GRPCRoleHandler struct {
+	pb.UnimplementedRoleServiceServer
+	roleService service.RoleService
+}
+
+// NewGRPCRoleHandler creates a new gRPC role handler.
+func NewGRPCRoleHandler(rs service.RoleService) *GRPCRoleHandler {
+	return &GRPCRoleHandler{roleService: rs}
+}
+
+// Register registers the RoleService with the server.
+func (h *GRPCRoleHandler) Register(server *grpc.Server) {
+	pb.RegisterRoleServiceServer(server, h)
+}
+
+// MethodRules returns the authorization rules for every RPC served by h.
+func (h *GRPCRoleHandler) MethodRules() map[string]middleware.GRPCMethodRule {
+	return map[string]middleware.GRPCMethodRule{
+		pb.RoleService_ListRoles_FullMethodName:          {Permission: model.PermRolesRead},
+		pb.RoleService_GetRole_FullMethodName:            {Permission: model.PermRolesRead},
+		pb.RoleService_CreateRole_FullMethodName:         {Permission: model.PermRolesWrite},
+		pb.RoleService_SetRolePermissions_FullMethodName: {Permission: model.PermRolesWrite},
+		pb.RoleService_DeleteRole_FullMethodName:         {Permission: model.PermRolesWrite},
+		pb.RoleService_ListPermissions_FullMethodName:    {Permission: model.PermRolesRead},
+		pb.RoleService_CreatePermission_FullMethodName:   {Permission: model.PermRolesWrite},
+		pb.RoleService_ListUserRoles_FullMethodName:      {Permission: model.PermRolesRead, AllowSelf: true},
+		pb.RoleService_AssignRole_FullMethodName:         {Permission: model.PermRolesWrite},
+		pb.RoleService_UnassignRole_FullMethodName:       {Permission: model.PermRolesWrite},
+	}
+}
+
+// ── RoleService RPCs ──────────────────────────────────────
+
+// ListRoles returns every role with its permissions.
+func (h *GRPCRoleHandler) ListRoles(ctx context.Context, _ *emptypb.Empty) (*pb.ListRolesResponse, error) {
+	roles, err := h.roleService.ListRoles(ctx)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "list roles")
+	}
+
+	resp := &pb.ListRolesResponse{Roles: make([]*pb.RoleResponse, 0, len(roles))}
+	for i := range roles {
+		resp.Roles = append(resp.Roles, toRoleResponse(&roles[i]))
+	}
+
+	return resp, nil
+}
+
+// GetRole retrieves a role by name.
+func (h *GRPCRoleHandler) GetRole(ctx context.Context, req *pb.GetRoleRequest) (*pb.RoleResponse, error) {
+	role, err := h.roleService.GetRole(ctx, model.Role(req.GetName()))
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "get role")
+	}
+
+	return toRoleResponse(role), nil
+}
+
+// CreateRole creates a role with an initial set of permissions.
+func (h *GRPCRoleHandler) CreateRole(ctx context.Context, req *pb.CreateRoleRequest) (*pb.RoleResponse, error) {
+	in := model.CreateRoleRequest{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Permissions: req.GetPermissions(),
+	}
+	if err := validateRPC(ctx, in); err != nil {
+		return nil, err
+	}
+
+	role, err := h.roleService.CreateRole(ctx, in)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "create role")
+	}
+
+	return toRoleResponse(role), nil
+}
+
+// SetRolePermissions replaces the permissions granted by a role.
+func (h *GRPCRoleHandler) SetRolePermissions(ctx context.Context, req *pb.SetRolePermissionsRequest) (*pb.RoleResponse, error) {
+	role, err := h.roleService.SetRolePermissions(ctx, model.Role(req.GetName()), model.SetRolePermissionsRequest{
+		Permissions: req.GetPermissions(),
+	})
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "set role permissions")
+	}
+
+	return toRoleResponse(role), nil
+}
+
+// DeleteRole deletes a custom role.
+func (h *GRPCRoleHandler) DeleteRole(ctx context.Context, req *pb.DeleteRoleRequest) (*emptypb.Empty, error) {
+	if err := h.roleService.DeleteRole(ctx, model.Role(req.GetName())); err != nil {
+		return nil, toGRPCError(ctx, err, "delete role")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ListPermissions returns every known permission.
+func (h *GRPCRoleHandler) ListPermissions(ctx context.Context, _ *emptypb.Empty) (*pb.ListPermissionsResponse, error) {
+	perms, err := h.roleService.ListPermissions(ctx)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "list permissions")
+	}
+
+	resp := &pb.ListPermissionsResponse{Permissions: make([]*pb.PermissionResponse, 0, len(perms))}
+	for i := range perms {
+		resp.Permissions = append(resp.Permissions, toPermissionResponse(&perms[i]))
+	}
+
+	return resp, nil
+}
+
+// CreatePermission registers a new permission.
+func (h *GRPCRoleHandler) CreatePermission(ctx context.Context, req *pb.CreatePermissionRequest) (*pb.PermissionResponse, error) {
+	in := model.CreatePermissionRequest{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+	}
+	if err := validateRPC(ctx, in); err != nil {
+		return nil, err
+	}
+
+	perm, err := h.roleService.CreatePermission(ctx, in)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "create permission")
+	}
+
+	return toPermissionResponse(perm), nil
+}
+
+// ListUserRoles returns the roles assigned to a user.
+func (h *GRPCRoleHandler) ListUserRoles(ctx context.Context, req *pb.ListUserRolesRequest) (*pb.ListUserRolesResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, grpcProblem(ctx, errInvalidUserID)
+	}
+
+	roles, err := h.roleService.ListUserRoles(ctx, id)
+	if err != nil {
+		return nil, toGRPCError(ctx, err, "list user roles")
+	}
+
+	resp := &pb.ListUserRolesResponse{Roles: make([]string, len(roles))}
+	for i, r := range roles {
+		resp.Roles[i] = string(r)
+	}
+
+	return resp, nil
+}
+
+// AssignRole grants a role to a user.
+func (h *GRPCRoleHandler) AssignRole(ctx context.Context, req *pb.AssignRoleRequest) (*emptypb.Empty, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, grpcProblem(ctx, errInvalidUserID)
+	}
+
+	in := model.AssignRoleRequest{Role: req.GetRole()}
+	if err := validateRPC(ctx, in); err != nil {
+		return nil, err
+	}
+
+	if err := h.roleService.AssignRole(ctx, id, model.Role(in.Role)); err != nil {
+		return nil, toGRPCError(ctx, err, "assign role")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// UnassignRole removes a role from a user.
+func (h *GRPCRoleHandler) UnassignRole(ctx context.Context, req *pb.UnassignRoleRequest) (*emptypb.Empty, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, grpcProblem(ctx, errInvalidUserID)
+	}
+
+	if err := h.roleService.UnassignRole(ctx, id, model.Role(req.GetRole())); err != nil {
+		return nil, toGRPCError(ctx, err, "unassign role")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ── Conversion Helpers ────────────────────────────────────
+
+func toRoleResponse(r *model.RoleDefinition) *pb.RoleResponse {
+	perms := make([]string, len(r.Permissions))
+	for i, p := range r.Permissions {
+		perms[i] = string(p)
+	}
+
+	return &pb.RoleResponse{
+		Id:          r.ID.String(),
+		Name:        string(r.Name),
+		Description: r.Description,
+		Permissions: perms,
+		CreatedAt:   timestamppb.New(r.CreatedAt),
+	}
+}
+
+func toPermissionResponse(p *model.PermissionDefinition) *pb.PermissionResponse {
+	return &pb.PermissionResponse{
+		Id:          p.ID.String(),
+		Name:        string(p.Name),
+		Description: p.Description,
+		CreatedAt:   timestamppb.New(p.CreatedAt),
+	}
+}
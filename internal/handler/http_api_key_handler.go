@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+
+	"Go-Microservice-Template/internal/middleware"
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// errKeyCreatesKey is reported when a caller authenticated with an API key
+// tries to create another one.
+var errKeyCreatesKey = service.ErrForbidden.WithDetail("reason", "api keys cannot create api keys")
+
+// ── API Key Endpoints ─────────────────────────────────────
+
+// ListAPIKeys returns a user's API keys. Key material is never included.
+func (h *HTTPHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondProblem(w, r, errInvalidUserID)
+		return
+	}
+
+	keys, err := h.apiKeyService.List(r.Context(), userID)
+	if err != nil {
+		respondError(w, r, err, "list api keys")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": keys})
+}
+
+// CreateAPIKey issues an API key. The key itself is only returned here.
+func (h *HTTPHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	// A key could otherwise mint keys with scopes it was never given
+	if r.Context().Value(middleware.APIKeyIDKey) != nil {
+		respondProblem(w, r, errKeyCreatesKey)
+		return
+	}
+
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondProblem(w, r, errInvalidUserID)
+		return
+	}
+
+	var req model.CreateAPIKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	resp, err := h.apiKeyService.Create(r.Context(), userID, req)
+	if err != nil {
+		respondError(w, r, err, "create api key")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, resp)
+}
+
+// RevokeAPIKey revokes one of a user's API keys.
+func (h *HTTPHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondProblem(w, r, errInvalidUserID)
+		return
+	}
+
+	keyID, err := uuid.Parse(chi.URLParam(r, "keyID"))
+	if err != nil {
+		respondProblem(w, r, errInvalidKeyID)
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(r.Context(), userID, keyID); err != nil {
+		respondError(w, r, err, "revoke api key")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "api key revoked"})
+}
@@ -1,13 +1,19 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
+	"Go-Microservice-Template/internal/auth"
+	"Go-Microservice-Template/internal/middleware"
 	"Go-Microservice-Template/internal/model"
-	"Go-Microservice-Template/internal/repository"
 	"Go-Microservice-Template/internal/service"
+	"Go-Microservice-Template/internal/validation"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -16,12 +22,15 @@ import (
 
 // HTTPHandler handles REST API requests.
 type HTTPHandler struct {
-	userService service.UserService
+	userService   service.UserService
+	roleService   service.RoleService
+	apiKeyService service.APIKeyService
+	issuer        *auth.Issuer
 }
 
 // NewHTTPHandler creates a new HTTP handler.
-func NewHTTPHandler(us service.UserService) *HTTPHandler {
-	return &HTTPHandler{userService: us}
+func NewHTTPHandler(us service.UserService, rs service.RoleService, ks service.APIKeyService, issuer *auth.Issuer) *HTTPHandler {
+	return &HTTPHandler{userService: us, roleService: rs, apiKeyService: ks, issuer: issuer}
 }
 
 // ── Health & System Endpoints ─────────────────────────────
@@ -50,51 +59,224 @@ func (h *HTTPHandler) Metrics(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("# Metrics endpoint - integrate with promhttp.Handler()\n"))
 }
 
+// JWKS publishes the public keys used to verify access tokens, so that other
+// services can validate them without holding any secret.
+func (h *HTTPHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondJSON(w, http.StatusOK, h.issuer.JWKS())
+}
+
 // ── Auth Endpoints ────────────────────────────────────────
 
 // Register creates a new user account.
 func (h *HTTPHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req model.CreateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	if req.Email == "" || req.Name == "" || req.Password == "" {
-		respondError(w, http.StatusBadRequest, "email, name, and password are required")
+	user, err := h.userService.Register(r.Context(), req)
+	if err != nil {
+		respondError(w, r, err, "register user")
 		return
 	}
 
-	if len(req.Password) < 8 {
-		respondError(w, http.StatusBadRequest, "password must be at least 8 characters")
+	respondJSON(w, http.StatusCreated, user)
+}
+
+// Login authenticates a user and returns a JWT.
+func (h *HTTPHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req model.LoginRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	user, err := h.userService.Register(r.Context(), req)
+	resp, err := h.userService.Login(r.Context(), req, clientIP(r))
 	if err != nil {
-		if err == repository.ErrDuplicate {
-			respondError(w, http.StatusConflict, "email already registered")
+		var challenge *service.MFARequiredError
+		if errors.As(err, &challenge) {
+			respondJSON(w, http.StatusOK, challenge.Challenge)
 			return
 		}
-		log.Error().Err(err).Msg("register user failed")
-		respondError(w, http.StatusInternalServerError, "internal server error")
+		respondError(w, r, err, "login")
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, user)
+	respondJSON(w, http.StatusOK, resp)
 }
 
-// Login authenticates a user and returns a JWT.
-func (h *HTTPHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var req model.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid request body")
+// Refresh rotates a refresh token and returns a new token pair.
+func (h *HTTPHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req model.RefreshRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	resp, err := h.userService.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		respondError(w, r, err, "refresh token")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// Logout revokes the caller's access token and optional refresh token.
+func (h *HTTPHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req model.LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondProblem(w, r, errInvalidBody)
+		return
+	}
+
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondProblem(w, r, service.ErrUnauthenticated)
+		return
+	}
+
+	jti, _ := r.Context().Value(middleware.TokenIDKey).(string)
+	expiresAt, _ := r.Context().Value(middleware.ExpiresAtKey).(time.Time)
+
+	if err := h.userService.Logout(r.Context(), userID, jti, expiresAt, req.RefreshToken); err != nil {
+		respondError(w, r, err, "logout")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "logged out"})
+}
+
+// ForgotPassword sends a password reset token if the account exists. The
+// response is the same either way so it cannot be used to probe for emails.
+func (h *HTTPHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req model.ForgotPasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.userService.ForgotPassword(r.Context(), req.Email); err != nil {
+		respondError(w, r, err, "forgot password")
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, map[string]string{
+		"message": "if the account exists, a reset token has been sent",
+	})
+}
+
+// ResetPassword sets a new password using a reset token.
+func (h *HTTPHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req model.ResetPasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.userService.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		respondError(w, r, err, "reset password")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "password reset"})
+}
+
+// VerifyEmail redeems an email verification token. GET takes the token from
+// the query string so the emailed link works directly; POST takes a JSON body.
+func (h *HTTPHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req model.VerifyEmailRequest
+	if r.Method == http.MethodGet {
+		req.Token = r.URL.Query().Get("token")
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondProblem(w, r, errInvalidBody)
+		return
+	}
+
+	if !validateRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.userService.VerifyEmail(r.Context(), req.Token); err != nil {
+		respondError(w, r, err, "verify email")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "email verified"})
+}
+
+// ResendVerification sends a new verification token to an unverified account.
+func (h *HTTPHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req model.ResendVerificationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.userService.ResendVerification(r.Context(), req.Email); err != nil {
+		respondError(w, r, err, "resend verification")
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, map[string]string{
+		"message": "if the account exists and is unverified, a verification token has been sent",
+	})
+}
+
+// ── Self-Service Endpoints ────────────────────────────────
+
+// GetMe returns the authenticated caller's own account.
+func (h *HTTPHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondProblem(w, r, service.ErrUnauthenticated)
+		return
+	}
+
+	user, err := h.userService.GetByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, r, err, "get me")
 		return
 	}
 
-	resp, err := h.userService.Login(r.Context(), req, "dev-secret-change-in-production", 24)
+	respondJSON(w, http.StatusOK, user)
+}
+
+// UpdateMe updates the caller's own name or email.
+func (h *HTTPHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondProblem(w, r, service.ErrUnauthenticated)
+		return
+	}
+
+	var req model.UpdateUserRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, err := h.userService.Update(r.Context(), userID, req)
+	if err != nil {
+		respondError(w, r, err, "update me")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, user)
+}
+
+// ChangePassword changes the caller's password and returns a fresh token
+// pair; every other session is signed out.
+func (h *HTTPHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondProblem(w, r, service.ErrUnauthenticated)
+		return
+	}
+
+	var req model.ChangePasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	resp, err := h.userService.ChangePassword(r.Context(), userID, req)
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "invalid credentials")
+		respondError(w, r, err, "change password")
 		return
 	}
 
@@ -103,22 +285,16 @@ func (h *HTTPHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 // ── User CRUD Endpoints ───────────────────────────────────
 
-// CreateUser creates a new user (admin only).
+// CreateUser creates a new user (admin only, enforced by the router).
 func (h *HTTPHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var req model.CreateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	user, err := h.userService.Register(r.Context(), req)
 	if err != nil {
-		if err == repository.ErrDuplicate {
-			respondError(w, http.StatusConflict, "email already exists")
-			return
-		}
-		log.Error().Err(err).Msg("create user failed")
-		respondError(w, http.StatusInternalServerError, "internal server error")
+		respondError(w, r, err, "create user")
 		return
 	}
 
@@ -129,18 +305,13 @@ func (h *HTTPHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 func (h *HTTPHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "invalid user ID")
+		respondProblem(w, r, errInvalidUserID)
 		return
 	}
 
 	user, err := h.userService.GetByID(r.Context(), id)
 	if err != nil {
-		if err == repository.ErrNotFound {
-			respondError(w, http.StatusNotFound, "user not found")
-			return
-		}
-		log.Error().Err(err).Msg("get user failed")
-		respondError(w, http.StatusInternalServerError, "internal server error")
+		respondError(w, r, err, "get user")
 		return
 	}
 
@@ -151,28 +322,22 @@ func (h *HTTPHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 func (h *HTTPHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "invalid user ID")
+		respondProblem(w, r, errInvalidUserID)
 		return
 	}
 
 	var req model.UpdateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid request body")
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Email != nil && middleware.IsAPIKey(r.Context()) {
+		respondProblem(w, r, errKeyChangesEmail)
 		return
 	}
 
 	user, err := h.userService.Update(r.Context(), id, req)
 	if err != nil {
-		if err == repository.ErrNotFound {
-			respondError(w, http.StatusNotFound, "user not found")
-			return
-		}
-		if err == repository.ErrDuplicate {
-			respondError(w, http.StatusConflict, "email already exists")
-			return
-		}
-		log.Error().Err(err).Msg("update user failed")
-		respondError(w, http.StatusInternalServerError, "internal server error")
+		respondError(w, r, err, "update user")
 		return
 	}
 
@@ -183,23 +348,50 @@ func (h *HTTPHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 func (h *HTTPHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "invalid user ID")
+		respondProblem(w, r, errInvalidUserID)
 		return
 	}
 
 	if err := h.userService.Delete(r.Context(), id); err != nil {
-		if err == repository.ErrNotFound {
-			respondError(w, http.StatusNotFound, "user not found")
-			return
-		}
-		log.Error().Err(err).Msg("delete user failed")
-		respondError(w, http.StatusInternalServerError, "internal server error")
+		respondError(w, r, err, "delete user")
 		return
 	}
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "user deleted"})
 }
 
+// RevokeSessions invalidates every token issued to a user (admin action).
+func (h *HTTPHandler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondProblem(w, r, errInvalidUserID)
+		return
+	}
+
+	if err := h.userService.RevokeSessions(r.Context(), id); err != nil {
+		respondError(w, r, err, "revoke sessions")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "sessions revoked"})
+}
+
+// UnlockUser lifts a login lockout on a user's account (admin action).
+func (h *HTTPHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondProblem(w, r, errInvalidUserID)
+		return
+	}
+
+	if err := h.userService.UnlockAccount(r.Context(), id); err != nil {
+		respondError(w, r, err, "unlock user")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "account unlocked"})
+}
+
 // ListUsers returns a paginated list of users.
 func (h *HTTPHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	params := model.DefaultListParams()
@@ -226,20 +418,66 @@ func (h *HTTPHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.userService.List(r.Context(), params)
 	if err != nil {
-		log.Error().Err(err).Msg("list users failed")
-		respondError(w, http.StatusInternalServerError, "internal server error")
+		respondError(w, r, err, "list users")
 		return
 	}
 
 	respondJSON(w, http.StatusOK, result)
 }
 
-// ── Response Helpers ──────────────────────────────────────
+// ── Context Helpers ───────────────────────────────────────
+
+// userIDFromContext returns the authenticated caller set by JWTAuthMiddleware.
+func userIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(middleware.SubjectFromContext(ctx))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
 
-type errorResponse struct {
-	Error string `json:"error"`
+// clientIP returns the caller's address, behind any trusted proxies, so that
+// all connections from one client share a login failure counter.
+func clientIP(r *http.Request) string {
+	return middleware.ClientIPFromRequest(r)
 }
 
+// ── Request Helpers ───────────────────────────────────────
+
+// Errors for malformed requests detected by the handlers themselves.
+var (
+	errInvalidBody   = service.BadRequest("invalid request body")
+	errInvalidUserID = service.BadRequest("invalid user ID")
+	errInvalidKeyID  = service.BadRequest("invalid key ID")
+)
+
+// errKeyChangesEmail is reported when a caller authenticated with an API key
+// tries to change an account's email, which would let it take the account
+// over through a password reset.
+var errKeyChangesEmail = service.ErrForbidden.WithDetail("reason", "api keys cannot change email addresses")
+
+// decodeJSON decodes the request body into v and validates it against its
+// `validate` tags. On failure it writes a 400 response and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		respondProblem(w, r, errInvalidBody)
+		return false
+	}
+	return validateRequest(w, r, v)
+}
+
+// validateRequest checks v against its `validate` tags. On failure it writes
+// a 400 response listing every invalid field and returns false.
+func validateRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := validation.Struct(v); err != nil {
+		respondError(w, r, err, "validate request")
+		return false
+	}
+	return true
+}
+
+// ── Response Helpers ──────────────────────────────────────
+
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -248,6 +486,33 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	}
 }
 
-func respondError(w http.ResponseWriter, status int, message string) {
-	respondJSON(w, status, errorResponse{Error: message})
+// respondError reports err as a problem document. Errors the service layer
+// does not recognize are logged as op failures and reported as internal.
+func respondError(w http.ResponseWriter, r *http.Request, err error, op string) {
+	appErr := service.AsError(err)
+	if appErr.HTTPStatus >= http.StatusInternalServerError {
+		log.Error().Err(err).Str("trace_id", middleware.TraceIDFromContext(r.Context())).Msg(op + " failed")
+	}
+	respondProblem(w, r, appErr)
+}
+
+// respondProblem writes an application error as an RFC 7807 problem. Fields
+// and details become extension members.
+func respondProblem(w http.ResponseWriter, r *http.Request, e *service.Error) {
+	if e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(e.RetryAfter.Seconds())+1))
+	}
+
+	p := model.Problem{Status: e.HTTPStatus, Detail: e.Message, Code: e.Code}
+	if len(e.Fields) > 0 || len(e.Details) > 0 {
+		p.Extensions = make(map[string]interface{}, len(e.Details)+1)
+		for k, v := range e.Details {
+			p.Extensions[k] = v
+		}
+		if len(e.Fields) > 0 {
+			p.Extensions["fields"] = e.Fields
+		}
+	}
+
+	middleware.WriteProblem(w, r, p)
 }
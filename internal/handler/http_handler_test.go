@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Go-Microservice-Template/internal/auth"
+	"Go-Microservice-Template/internal/middleware"
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/notify"
+	"Go-Microservice-Template/internal/repository"
+	"Go-Microservice-Template/internal/service"
+	"Go-Microservice-Template/internal/validation"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// uniqueEmails stands in for the users table. Like the unique index on
+// lower(email), it fails a conflicting insert with the error the PostgreSQL
+// repository returns for SQLSTATE 23505, and like the VARCHAR(255) column it
+// fails an overlong address.
+type uniqueEmails struct {
+	repository.UserRepository
+
+	mu     sync.Mutex
+	emails map[string]bool
+	// beforeCreate, if set, runs before every Create outside the lock.
+	beforeCreate func()
+}
+
+func (u *uniqueEmails) Create(_ context.Context, user *model.User) error {
+	if u.beforeCreate != nil {
+		u.beforeCreate()
+	}
+
+	if len(user.Email) > 255 {
+		return fmt.Errorf("create user: %w", &pgconn.PgError{
+			Severity: "ERROR",
+			Code:     "22001",
+			Message:  "value too long for type character varying(255)",
+		})
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	key := strings.ToLower(user.Email)
+	if u.emails[key] {
+		return fmt.Errorf("%w: %w", repository.ErrDuplicate, &pgconn.PgError{
+			Severity:       "ERROR",
+			Code:           "23505",
+			Message:        `duplicate key value violates unique constraint "users_email_lower_key"`,
+			Detail:         fmt.Sprintf("Key (lower(email::text))=(%s) already exists.", key),
+			TableName:      "users",
+			ConstraintName: "users_email_lower_key",
+		})
+	}
+	u.emails[key] = true
+	user.ID = uuid.New()
+	return nil
+}
+
+// acceptedTokens stores verification tokens nowhere.
+type acceptedTokens struct {
+	repository.ActionTokenRepository
+}
+
+func (acceptedTokens) Create(context.Context, *model.ActionToken) error { return nil }
+
+// newRegistrationService builds the real user service over users, with only
+// the dependencies registration needs.
+func newRegistrationService(t *testing.T, users repository.UserRepository) service.UserService {
+	t.Helper()
+
+	keys, err := auth.NewKeySet(nil, "", "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher, err := auth.NewPasswordHasher(auth.PasswordHashConfig{Algorithm: "bcrypt", BcryptCost: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfaKeys, err := auth.NewMFAKeys("test-mfa-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return service.NewUserService(
+		users,
+		repository.NewUserCache(nil, time.Minute),
+		nil, nil, nil,
+		acceptedTokens{},
+		nil, nil, nil, nil,
+		auth.NewIssuer(keys, time.Hour, 24*time.Hour),
+		hasher,
+		mfaKeys,
+		service.NewLoginGuard(repository.NewLoginAttemptStore(nil, 100), service.LockoutPolicy{MaxAttempts: 5, IPMaxAttempts: 5, LockoutDuration: time.Minute}),
+		notify.NewLogNotifier(),
+		service.AccountOptions{
+			EmailVerificationTTL: time.Hour,
+			VerifyURL:            "http://localhost/verify",
+			PasswordPolicy:       service.PasswordPolicy{MinLength: 8, MaxBytes: 72},
+		},
+	)
+}
+
+func TestRegisterConcurrentDuplicate(t *testing.T) {
+	const racers = 2
+
+	// Hold every insert until all racers have passed the checks before it,
+	// so that only the unique index can decide
+	var arrived sync.WaitGroup
+	arrived.Add(racers)
+	users := &uniqueEmails{emails: make(map[string]bool), beforeCreate: func() {
+		arrived.Done()
+		arrived.Wait()
+	}}
+	h := NewHTTPHandler(newRegistrationService(t, users), nil, nil, nil)
+
+	recs := make([]*httptest.ResponseRecorder, racers)
+	var wg sync.WaitGroup
+	for i := range recs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Differently cased spellings of the same address collide too
+			email := "Racer@Example.com"
+			if i%2 == 1 {
+				email = "racer@example.COM"
+			}
+			body := fmt.Sprintf(`{"email":%q,"name":"Racer","password":"correct-horse-battery"}`, email)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			recs[i] = httptest.NewRecorder()
+			h.Register(recs[i], req)
+		}(i)
+	}
+	wg.Wait()
+
+	var created, conflicts []*httptest.ResponseRecorder
+	for _, rec := range recs {
+		switch rec.Code {
+		case http.StatusCreated:
+			created = append(created, rec)
+		case http.StatusConflict:
+			conflicts = append(conflicts, rec)
+		default:
+			t.Errorf("status = %d, want %d or %d; body: %s", rec.Code, http.StatusCreated, http.StatusConflict, rec.Body)
+		}
+	}
+	if len(created) != 1 || len(conflicts) != racers-1 {
+		t.Fatalf("%d created and %d conflicts, want 1 and %d", len(created), len(conflicts), racers-1)
+	}
+
+	rec := conflicts[0]
+	if ct := rec.Header().Get("Content-Type"); ct != model.ProblemContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, model.ProblemContentType)
+	}
+	var problem map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v; body: %s", err, rec.Body)
+	}
+	want := map[string]interface{}{
+		"type":     "about:blank",
+		"title":    "Conflict",
+		"status":   float64(http.StatusConflict),
+		"detail":   "email already registered",
+		"instance": "/api/v1/auth/register",
+		"code":     "email_taken",
+	}
+	for k, v := range want {
+		if problem[k] != v {
+			t.Errorf("problem %s = %v, want %v", k, problem[k], v)
+		}
+	}
+	// The database error stays in the logs
+	for _, leak := range []string{"23505", "users_email_lower_key", "already exists", "racer@example.com"} {
+		if strings.Contains(rec.Body.String(), leak) {
+			t.Errorf("problem leaks %q: %s", leak, rec.Body)
+		}
+	}
+}
+
+func TestRegisterEmailTooLongOnceNormalized(t *testing.T) {
+	users := &uniqueEmails{emails: make(map[string]bool)}
+	h := NewHTTPHandler(newRegistrationService(t, users), nil, nil, nil)
+
+	// 144 characters, so within the request's max=255, but 276 in punycode
+	email := strings.Repeat("a", 64) + "@" + strings.Repeat("日本語のドメイン名例です一二三四五.", 4) + "example"
+	body := fmt.Sprintf(`{"email":%q,"name":"Long","password":"correct-horse-battery"}`, email)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.Register(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+	var problem map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v; body: %s", err, rec.Body)
+	}
+	if problem["code"] != "email_too_long" {
+		t.Errorf("code = %v, want email_too_long", problem["code"])
+	}
+	if len(users.emails) != 0 {
+		t.Errorf("stored %v", users.emails)
+	}
+}
+
+func TestLoginCountsFailuresPerClientBehindAProxy(t *testing.T) {
+	svc := &fakeUserService{err: service.ErrInvalidCredentials}
+	proxy := middleware.NewClientIPResolver([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	h := proxy.Handler(http.HandlerFunc(NewHTTPHandler(svc, nil, nil, nil).Login))
+
+	for _, client := range []string{"198.51.100.1", "198.51.100.2"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"email":"user@example.com","password":"wrong"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", client)
+		req.RemoteAddr = "10.0.0.2:443"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+		if svc.gotReq != client {
+			t.Errorf("failure counted for %v, want the client %s", svc.gotReq, client)
+		}
+	}
+}
+
+func TestRespondError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantRetry  string
+		want       map[string]interface{}
+	}{
+		{
+			name:       "lockout",
+			err:        &service.LockedError{Until: time.Now().Add(90 * time.Second)},
+			wantStatus: http.StatusTooManyRequests,
+			wantRetry:  "90",
+			want:       map[string]interface{}{"code": "account_locked", "detail": "too many failed login attempts"},
+		},
+		{
+			name:       "password policy",
+			err:        &service.PasswordPolicyError{Violations: []string{"too short", "too common"}},
+			wantStatus: http.StatusBadRequest,
+			want: map[string]interface{}{
+				"code":       "weak_password",
+				"violations": []interface{}{"too short", "too common"},
+			},
+		},
+		{
+			name:       "validation",
+			err:        &validation.Error{Fields: []validation.FieldError{{Field: "email", Rule: "required", Message: "is required"}}},
+			wantStatus: http.StatusBadRequest,
+			want: map[string]interface{}{
+				"code":   "validation_failed",
+				"fields": []interface{}{map[string]interface{}{"field": "email", "rule": "required", "message": "is required"}},
+			},
+		},
+		{
+			name:       "unknown",
+			err:        fmt.Errorf("query: %w", context.DeadlineExceeded),
+			wantStatus: http.StatusInternalServerError,
+			want:       map[string]interface{}{"code": "internal", "detail": "internal server error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.TraceIDKey, "trace-1"))
+			rec := httptest.NewRecorder()
+
+			respondError(rec, req, tt.err, "test")
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != model.ProblemContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, model.ProblemContentType)
+			}
+			// Rounded up, so clients never retry too early
+			if got := rec.Header().Get("Retry-After"); got != tt.wantRetry {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetry)
+			}
+			var problem map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("decode problem: %v; body: %s", err, rec.Body)
+			}
+			if problem["status"] != float64(tt.wantStatus) || problem["trace_id"] != "trace-1" || problem["instance"] != "/api/v1/auth/login" {
+				t.Errorf("problem = %v, want status %d, trace_id and instance", problem, tt.wantStatus)
+			}
+			for k, v := range tt.want {
+				if !reflect.DeepEqual(problem[k], v) {
+					t.Errorf("problem %s = %v, want %v", k, problem[k], v)
+				}
+			}
+		})
+	}
+}
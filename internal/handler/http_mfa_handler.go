@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/service"
+)
+
+// ── Two-Factor Authentication Endpoints ───────────────────
+
+// VerifyMFA completes a two-step login with a TOTP or recovery code.
+func (h *HTTPHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req model.MFAVerifyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	resp, err := h.userService.VerifyMFA(r.Context(), req.MFAToken, req.Code, clientIP(r))
+	if err != nil {
+		respondError(w, r, err, "verify mfa")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// EnrollMFA starts TOTP enrollment for the caller and returns the secret and
+// otpauth URI to load into an authenticator app.
+func (h *HTTPHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondProblem(w, r, service.ErrUnauthenticated)
+		return
+	}
+
+	resp, err := h.userService.EnrollMFA(r.Context(), userID)
+	if err != nil {
+		respondError(w, r, err, "enroll mfa")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// EnableMFA confirms enrollment with a code and returns recovery codes.
+func (h *HTTPHandler) EnableMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondProblem(w, r, service.ErrUnauthenticated)
+		return
+	}
+
+	var req model.MFACodeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	resp, err := h.userService.EnableMFA(r.Context(), userID, req.Code)
+	if err != nil {
+		respondError(w, r, err, "enable mfa")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// DisableMFA turns off the caller's second factor after checking a code.
+func (h *HTTPHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		respondProblem(w, r, service.ErrUnauthenticated)
+		return
+	}
+
+	var req model.MFACodeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.userService.DisableMFA(r.Context(), userID, req.Code); err != nil {
+		respondError(w, r, err, "disable mfa")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "mfa disabled"})
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"Go-Microservice-Template/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// oidcFlowCookie holds the state, nonce and PKCE verifier of a started
+// external login until the provider redirects back.
+const oidcFlowCookie = "oidc_flow"
+
+// Errors for callbacks that cannot belong to a login we started.
+var (
+	errInvalidLoginState = service.BadRequest("invalid or expired login state")
+	errMissingCode       = service.BadRequest("code is required")
+)
+
+// oidcFlow is the cookie payload for a started external login.
+type oidcFlow struct {
+	Provider string `json:"p"`
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+}
+
+// ── External Login Endpoints ──────────────────────────────
+
+// OIDCLogin redirects the browser to the identity provider.
+func (h *HTTPHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider := chi.URLParam(r, "provider")
+
+	req, err := h.userService.BeginOIDCLogin(r.Context(), provider)
+	if err != nil {
+		respondError(w, r, err, "begin oidc login")
+		return
+	}
+
+	payload, err := json.Marshal(oidcFlow{
+		Provider: req.Provider,
+		State:    req.State,
+		Nonce:    req.Nonce,
+		Verifier: req.Verifier,
+	})
+	if err != nil {
+		respondError(w, r, err, "encode oidc flow")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(payload),
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode, // Must survive the provider's top-level redirect
+	})
+
+	http.Redirect(w, r, req.URL, http.StatusFound)
+}
+
+// OIDCCallback completes an external login and returns the same response as
+// a password login.
+func (h *HTTPHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider := chi.URLParam(r, "provider")
+
+	// The flow is single-use whatever the outcome
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    "",
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		respondProblem(w, r, service.ErrExternalLogin.WithDetail("provider_error", e))
+		return
+	}
+
+	flow, ok := readOIDCFlow(r)
+	state := r.URL.Query().Get("state")
+	if !ok || flow.Provider != provider || subtle.ConstantTimeCompare([]byte(flow.State), []byte(state)) != 1 {
+		respondProblem(w, r, errInvalidLoginState)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		respondProblem(w, r, errMissingCode)
+		return
+	}
+
+	resp, err := h.userService.CompleteOIDCLogin(r.Context(), provider, code, flow.Verifier, flow.Nonce)
+	if err != nil {
+		var challenge *service.MFARequiredError
+		if errors.As(err, &challenge) {
+			respondJSON(w, http.StatusOK, challenge.Challenge)
+			return
+		}
+		respondError(w, r, err, "complete oidc login")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func readOIDCFlow(r *http.Request) (oidcFlow, bool) {
+	var flow oidcFlow
+
+	c, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		return flow, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return flow, false
+	}
+
+	if err := json.Unmarshal(payload, &flow); err != nil || flow.State == "" {
+		return flow, false
+	}
+
+	return flow, true
+}
+
+// isHTTPS reports whether the client reached us over TLS, directly or
+// through a proxy that terminates it.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestOIDCCallbackRejectsForeignState(t *testing.T) {
+	flowCookie := func(flow oidcFlow) *http.Cookie {
+		payload, err := json.Marshal(flow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &http.Cookie{Name: oidcFlowCookie, Value: base64.RawURLEncoding.EncodeToString(payload)}
+	}
+	started := oidcFlow{Provider: "fake", State: "state-we-issued", Nonce: "n", Verifier: "v"}
+
+	tests := []struct {
+		name   string
+		query  string
+		cookie *http.Cookie
+	}{
+		{"state mismatch", "?state=state-of-another-login&code=c", flowCookie(started)},
+		{"no flow cookie", "?state=state-we-issued&code=c", nil},
+		{"other provider", "?state=state-we-issued&code=c", flowCookie(oidcFlow{Provider: "other", State: "state-we-issued"})},
+		{"garbled cookie", "?state=state-we-issued&code=c", &http.Cookie{Name: oidcFlowCookie, Value: "%%%"}},
+	}
+
+	// The service is never reached for a callback we did not start
+	r := chi.NewRouter()
+	r.Get("/api/v1/auth/oidc/{provider}/callback", NewHTTPHandler(nil, nil, nil, nil).OIDCCallback)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/fake/callback"+tt.query, nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			rec := httptest.NewRecorder()
+
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d; body: %s", rec.Code, http.StatusBadRequest, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"net/http"
+
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ── Role & Permission Endpoints ───────────────────────────
+
+// ListRoles returns every role with its permissions.
+func (h *HTTPHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.roleService.ListRoles(r.Context())
+	if err != nil {
+		respondError(w, r, err, "list roles")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": roles})
+}
+
+// GetRole retrieves a role by name.
+func (h *HTTPHandler) GetRole(w http.ResponseWriter, r *http.Request) {
+	role, err := h.roleService.GetRole(r.Context(), model.Role(chi.URLParam(r, "name")))
+	if err != nil {
+		respondError(w, r, err, "get role")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, role)
+}
+
+// CreateRole creates a role with an initial set of permissions.
+func (h *HTTPHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateRoleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	role, err := h.roleService.CreateRole(r.Context(), req)
+	if err != nil {
+		respondError(w, r, err, "create role")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, role)
+}
+
+// SetRolePermissions replaces the permissions granted by a role.
+func (h *HTTPHandler) SetRolePermissions(w http.ResponseWriter, r *http.Request) {
+	var req model.SetRolePermissionsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	role, err := h.roleService.SetRolePermissions(r.Context(), model.Role(chi.URLParam(r, "name")), req)
+	if err != nil {
+		respondError(w, r, err, "set role permissions")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, role)
+}
+
+// DeleteRole deletes a custom role.
+func (h *HTTPHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	if err := h.roleService.DeleteRole(r.Context(), model.Role(chi.URLParam(r, "name"))); err != nil {
+		respondError(w, r, err, "delete role")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "role deleted"})
+}
+
+// ListPermissions returns every known permission.
+func (h *HTTPHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	perms, err := h.roleService.ListPermissions(r.Context())
+	if err != nil {
+		respondError(w, r, err, "list permissions")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": perms})
+}
+
+// CreatePermission registers a new permission.
+func (h *HTTPHandler) CreatePermission(w http.ResponseWriter, r *http.Request) {
+	var req model.CreatePermissionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	perm, err := h.roleService.CreatePermission(r.Context(), req)
+	if err != nil {
+		respondError(w, r, err, "create permission")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, perm)
+}
+
+// ListUserRoles returns the roles assigned to a user.
+func (h *HTTPHandler) ListUserRoles(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondProblem(w, r, errInvalidUserID)
+		return
+	}
+
+	roles, err := h.roleService.ListUserRoles(r.Context(), id)
+	if err != nil {
+		respondError(w, r, err, "list user roles")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"items": roles})
+}
+
+// AssignRole grants a role to a user.
+func (h *HTTPHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondProblem(w, r, errInvalidUserID)
+		return
+	}
+
+	var req model.AssignRoleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.roleService.AssignRole(r.Context(), id, model.Role(req.Role)); err != nil {
+		respondError(w, r, err, "assign role")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "role assigned"})
+}
+
+// UnassignRole removes a role from a user.
+func (h *HTTPHandler) UnassignRole(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondProblem(w, r, errInvalidUserID)
+		return
+	}
+
+	if err := h.roleService.UnassignRole(r.Context(), id, model.Role(chi.URLParam(r, "role"))); err != nil {
+		respondError(w, r, err, "unassign role")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "role unassigned"})
+}
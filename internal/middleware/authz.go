@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"Go-Microservice-Template/internal/model"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// ── Authorization Middleware ──────────────────────────────
+//
+// These must run after JWTAuthMiddleware (or GRPCAuthInterceptor), which
+// populates UserIDKey and RoleKey.
+
+// RoleFromContext returns the caller's role, or "" if unauthenticated.
+func RoleFromContext(ctx context.Context) model.Role {
+	role, _ := ctx.Value(RoleKey).(string)
+	return model.Role(role)
+}
+
+// SubjectFromContext returns the caller's user ID as a string, or "".
+func SubjectFromContext(ctx context.Context) string {
+	sub, _ := ctx.Value(UserIDKey).(string)
+	return sub
+}
+
+// PermissionsFromContext returns the permissions embedded in the caller's token.
+func PermissionsFromContext(ctx context.Context) []model.Permission {
+	perms, _ := ctx.Value(PermissionsKey).([]model.Permission)
+	return perms
+}
+
+// IsAPIKey reports whether the caller in ctx authenticated with an API key.
+// Such callers act as the key's owner but only with the key's scopes.
+func IsAPIKey(ctx context.Context) bool {
+	id, _ := ctx.Value(APIKeyIDKey).(string)
+	return id != ""
+}
+
+// HasPermission reports whether the caller in ctx has been granted p.
+func HasPermission(ctx context.Context, p model.Permission) bool {
+	for _, granted := range PermissionsFromContext(ctx) {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRole only lets callers holding one of roles through.
+func RequireRole(roles ...model.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := RoleFromContext(r.Context())
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			writeProblem(w, r, http.StatusForbidden, "forbidden", "forbidden")
+		})
+	}
+}
+
+// RequirePermission only lets callers whose token grants p through.
+func RequirePermission(p model.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasPermission(r.Context(), p) {
+				writeProblem(w, r, http.StatusForbidden, "forbidden", "forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSelfOrPermission lets callers act on their own account, identified
+// by targetID, and otherwise requires p. API keys always need p among their
+// scopes.
+func RequireSelfOrPermission(p model.Permission, targetID func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sub := SubjectFromContext(r.Context())
+			if sub != "" && sub == targetID(r) && !IsAPIKey(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !HasPermission(r.Context(), p) {
+				writeProblem(w, r, http.StatusForbidden, "forbidden", "forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAPIKeyPermission requires p of API-key callers on routes that need
+// no permission otherwise, such as those acting on the caller's own account.
+func RequireAPIKeyPermission(p model.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsAPIKey(r.Context()) && !HasPermission(r.Context(), p) {
+				writeProblem(w, r, http.StatusForbidden, "forbidden", "forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RejectAPIKeys keeps API-key callers out of routes that must only be used
+// interactively: managing credentials, MFA and the account's email.
+func RejectAPIKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsAPIKey(r.Context()) {
+			writeProblem(w, r, http.StatusForbidden, "forbidden", "not available to api keys")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ── gRPC Authorization ────────────────────────────────────
+
+// GRPCMethodRule describes who may call a gRPC method.
+type GRPCMethodRule struct {
+	// Permission required to call the method. Empty allows any
+	// authenticated caller.
+	Permission model.Permission
+	// AllowSelf lets callers through without Permission when the request's
+	// GetId() matches their own user ID. It does not apply to API keys.
+	AllowSelf bool
+	// APIKeyPermission is required of API-key callers in addition to
+	// Permission, for methods acting on the caller's own account.
+	APIKeyPermission model.Permission
+	// DenyAPIKeys rejects API-key callers, like RejectAPIKeys.
+	DenyAPIKeys bool
+	// Public methods skip authentication entirely, like the /auth routes.
+	Public bool
+}
+
+// GRPCAuthorizationInterceptor enforces rules keyed by full method name.
+// Methods without a rule are denied.
+func GRPCAuthorizationInterceptor(rules map[string]GRPCMethodRule) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		rule, ok := rules[info.FullMethod]
+		if !ok {
+			return nil, GRPCError(ctx, codes.PermissionDenied, "forbidden", "forbidden", nil)
+		}
+
+		if rule.Public {
+			return handler(ctx, req)
+		}
+
+		if IsAPIKey(ctx) {
+			if rule.DenyAPIKeys {
+				return nil, GRPCError(ctx, codes.PermissionDenied, "forbidden", "not available to api keys", nil)
+			}
+			if rule.APIKeyPermission != "" && !HasPermission(ctx, rule.APIKeyPermission) {
+				return nil, GRPCError(ctx, codes.PermissionDenied, "forbidden", "forbidden", nil)
+			}
+		} else if rule.AllowSelf {
+			if target, ok := req.(interface{ GetId() string }); ok {
+				if sub := SubjectFromContext(ctx); sub != "" && sub == target.GetId() {
+					return handler(ctx, req)
+				}
+			}
+		}
+
+		if rule.Permission != "" && !HasPermission(ctx, rule.Permission) {
+			return nil, GRPCError(ctx, codes.PermissionDenied, "forbidden", "forbidden", nil)
+		}
+
+		return handler(ctx, req)
+	}
+}
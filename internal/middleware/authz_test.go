@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"Go-Microservice-Template/internal/model"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	self  = "11111111-1111-1111-1111-111111111111"
+	other = "22222222-2222-2222-2222-222222222222"
+)
+
+// caller is the identity the authentication middleware would have put in
+// the request context.
+type caller struct {
+	sub    string
+	role   model.Role
+	perms  []model.Permission
+	apiKey bool
+}
+
+func (c caller) context(ctx context.Context) context.Context {
+	if c.sub == "" {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, UserIDKey, c.sub)
+	ctx = context.WithValue(ctx, RoleKey, string(c.role))
+	ctx = context.WithValue(ctx, PermissionsKey, c.perms)
+	if c.apiKey {
+		ctx = context.WithValue(ctx, APIKeyIDKey, "33333333-3333-3333-3333-333333333333")
+	}
+	return ctx
+}
+
+var (
+	anonymous  = caller{}
+	user       = caller{sub: self, role: model.RoleUser}
+	admin      = caller{sub: self, role: model.RoleAdmin, perms: []model.Permission{model.PermUsersRead, model.PermUsersWrite}}
+	readKey    = caller{sub: self, role: model.RoleUser, perms: []model.Permission{model.PermUsersRead}, apiKey: true}
+	writeKey   = caller{sub: self, role: model.RoleUser, perms: []model.Permission{model.PermUsersWrite}, apiKey: true}
+	targetSelf = func(*http.Request) string { return self }
+	targetElse = func(*http.Request) string { return other }
+)
+
+func TestHTTPAuthorization(t *testing.T) {
+	tests := []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		caller     caller
+		want       int
+	}{
+		{"role held", RequireRole(model.RoleAdmin), admin, http.StatusOK},
+		{"role missing", RequireRole(model.RoleAdmin), user, http.StatusForbidden},
+		{"role anonymous", RequireRole(model.RoleUser, model.RoleAdmin), anonymous, http.StatusForbidden},
+
+		{"permission granted", RequirePermission(model.PermUsersWrite), admin, http.StatusOK},
+		{"permission missing", RequirePermission(model.PermUsersWrite), user, http.StatusForbidden},
+		{"permission in key scopes", RequirePermission(model.PermUsersRead), readKey, http.StatusOK},
+		{"permission outside key scopes", RequirePermission(model.PermUsersWrite), readKey, http.StatusForbidden},
+
+		{"self", RequireSelfOrPermission(model.PermUsersWrite, targetSelf), user, http.StatusOK},
+		{"other without permission", RequireSelfOrPermission(model.PermUsersWrite, targetElse), user, http.StatusForbidden},
+		{"other with permission", RequireSelfOrPermission(model.PermUsersWrite, targetElse), admin, http.StatusOK},
+		{"anonymous is never self", RequireSelfOrPermission(model.PermUsersWrite, func(*http.Request) string { return "" }), anonymous, http.StatusForbidden},
+		{"key on self without scope", RequireSelfOrPermission(model.PermUsersWrite, targetSelf), readKey, http.StatusForbidden},
+		{"key on self with scope", RequireSelfOrPermission(model.PermUsersWrite, targetSelf), writeKey, http.StatusOK},
+
+		{"key permission ignores sessions", RequireAPIKeyPermission(model.PermUsersRead), user, http.StatusOK},
+		{"key permission in scopes", RequireAPIKeyPermission(model.PermUsersRead), readKey, http.StatusOK},
+		{"key permission outside scopes", RequireAPIKeyPermission(model.PermUsersRead), writeKey, http.StatusForbidden},
+
+		{"reject keys lets sessions through", RejectAPIKeys, user, http.StatusOK},
+		{"reject keys", RejectAPIKeys, writeKey, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req = req.WithContext(tt.caller.context(req.Context()))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+// idRequest is a gRPC request addressing the account with Id.
+type idRequest struct{ Id string }
+
+func (r idRequest) GetId() string { return r.Id }
+
+func TestGRPCAuthorizationInterceptor(t *testing.T) {
+	rules := map[string]GRPCMethodRule{
+		"/test/Public":       {Public: true},
+		"/test/Any":          {},
+		"/test/Write":        {Permission: model.PermUsersWrite},
+		"/test/UpdateUser":   {Permission: model.PermUsersWrite, AllowSelf: true},
+		"/test/GetMe":        {APIKeyPermission: model.PermUsersRead},
+		"/test/ChangeSecret": {DenyAPIKeys: true},
+	}
+	interceptor := GRPCAuthorizationInterceptor(rules)
+
+	tests := []struct {
+		name   string
+		method string
+		caller caller
+		req    interface{}
+		want   codes.Code
+	}{
+		{"method without rule", "/test/Unknown", admin, nil, codes.PermissionDenied},
+		{"public", "/test/Public", anonymous, nil, codes.OK},
+		{"any authenticated caller", "/test/Any", user, nil, codes.OK},
+
+		{"permission granted", "/test/Write", admin, nil, codes.OK},
+		{"permission missing", "/test/Write", user, nil, codes.PermissionDenied},
+
+		{"self", "/test/UpdateUser", user, idRequest{Id: self}, codes.OK},
+		{"other without permission", "/test/UpdateUser", user, idRequest{Id: other}, codes.PermissionDenied},
+		{"other with permission", "/test/UpdateUser", admin, idRequest{Id: other}, codes.OK},
+		{"self without an id", "/test/UpdateUser", user, struct{}{}, codes.PermissionDenied},
+		{"key on self without scope", "/test/UpdateUser", readKey, idRequest{Id: self}, codes.PermissionDenied},
+		{"key on self with scope", "/test/UpdateUser", writeKey, idRequest{Id: self}, codes.OK},
+
+		{"key permission ignores sessions", "/test/GetMe", user, nil, codes.OK},
+		{"key permission in scopes", "/test/GetMe", readKey, nil, codes.OK},
+		{"key permission outside scopes", "/test/GetMe", writeKey, nil, codes.PermissionDenied},
+
+		{"deny keys lets sessions through", "/test/ChangeSecret", user, nil, codes.OK},
+		{"deny keys", "/test/ChangeSecret", writeKey, nil, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := interceptor(tt.caller.context(context.Background()), tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method},
+				func(context.Context, interface{}) (interface{}, error) { return "ok", nil })
+
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
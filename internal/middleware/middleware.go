@@ -2,15 +2,26 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
+	"Go-Microservice-Template/internal/auth"
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/go-chi/cors"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -19,10 +30,170 @@ import (
 type contextKey string
 
 const (
-	UserIDKey contextKey = "user_id"
-	RoleKey   contextKey = "role"
+	UserIDKey      contextKey = "user_id"
+	RoleKey        contextKey = "role"
+	TokenIDKey     contextKey = "token_id"
+	ExpiresAtKey   contextKey = "expires_at"
+	PermissionsKey contextKey = "permissions"
+	APIKeyIDKey    contextKey = "api_key_id"
+	TraceIDKey     contextKey = "trace_id"
+	ClientIPKey    contextKey = "client_ip"
 )
 
+// ── Trace Middleware ──────────────────────────────────────
+
+// TraceMiddleware assigns every request a trace ID and echoes it in the
+// X-Request-ID response header. The caller's ID is reused when it sent a W3C
+// traceparent or an X-Request-ID header. Error responses and logs carry the
+// ID, so a client report can be matched to the server side.
+func TraceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := traceID(r.Header.Get("traceparent"), r.Header.Get("X-Request-ID"))
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), TraceIDKey, id)))
+	})
+}
+
+// TraceIDFromContext returns the trace ID set by TraceMiddleware or
+// GRPCTraceInterceptor, or "" if there is none.
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(TraceIDKey).(string)
+	return id
+}
+
+// traceID returns the trace ID of a traceparent value, else requestID if it
+// is safe to log, else a new random ID.
+func traceID(traceparent, requestID string) string {
+	// traceparent is version-traceid-parentid-flags
+	if parts := strings.Split(traceparent, "-"); len(parts) == 4 && isTraceID(parts[1]) {
+		return parts[1]
+	}
+	if isRequestID(requestID) {
+		return requestID
+	}
+
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return uuid.NewString()
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// isTraceID reports whether s is a valid, non-zero W3C trace ID.
+func isTraceID(s string) bool {
+	if len(s) != 32 || s == strings.Repeat("0", 32) {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isRequestID accepts short IDs made of characters that cannot forge log
+// lines or headers.
+func isRequestID(s string) bool {
+	if s == "" || len(s) > 128 {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ── Client IP Middleware ──────────────────────────────────
+
+// ClientIPResolver finds the address of the client behind trusted reverse
+// proxies. X-Forwarded-For is only read from connections made by a trusted
+// proxy, as anyone else can set it. Its trusted proxies can be changed while
+// serving.
+type ClientIPResolver struct {
+	mu      sync.RWMutex
+	trusted []netip.Prefix
+}
+
+// NewClientIPResolver creates a resolver trusting the proxies in trusted.
+func NewClientIPResolver(trusted []netip.Prefix) *ClientIPResolver {
+	return &ClientIPResolver{trusted: trusted}
+}
+
+// SetTrustedProxies replaces the trusted proxies.
+func (c *ClientIPResolver) SetTrustedProxies(trusted []netip.Prefix) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trusted = trusted
+}
+
+// Handler is the middleware storing the client address in the request
+// context, for ClientIPFromRequest.
+func (c *ClientIPResolver) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := c.resolve(remoteHost(r), r.Header.Values("X-Forwarded-For"))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ClientIPKey, ip)))
+	})
+}
+
+// resolve walks X-Forwarded-For from the nearest hop back while the hop it
+// came through is trusted, and returns the first address that is not.
+func (c *ClientIPResolver) resolve(remote string, forwardedFor []string) string {
+	client, err := netip.ParseAddr(remote)
+	if err != nil {
+		return remote
+	}
+	client = client.Unmap()
+
+	var hops []string
+	for _, v := range forwardedFor {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := len(hops) - 1; i >= 0 && c.isTrusted(client); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Whoever wrote a malformed hop is not to be believed further
+			break
+		}
+		client = hop.Unmap()
+	}
+	return client.String()
+}
+
+// isTrusted reports whether addr is a trusted proxy. Callers must hold mu.
+func (c *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	for _, p := range c.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIPFromRequest returns the client address found by ClientIPResolver,
+// or the host of r.RemoteAddr if the resolver did not run.
+func ClientIPFromRequest(r *http.Request) string {
+	if ip, ok := r.Context().Value(ClientIPKey).(string); ok {
+		return ip
+	}
+	return remoteHost(r)
+}
+
+// remoteHost returns the address of the connection without the port, which
+// changes with every connection.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // ── Logging Middleware ────────────────────────────────────
 
 // LoggingMiddleware logs each HTTP request with duration and status.
@@ -39,6 +210,7 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 			Int("status", ww.statusCode).
 			Dur("duration", time.Since(start)).
 			Str("remote", r.RemoteAddr).
+			Str("trace_id", TraceIDFromContext(r.Context())).
 			Msg("request")
 	})
 }
@@ -63,9 +235,10 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 				log.Error().
 					Interface("panic", err).
 					Str("path", r.URL.Path).
+					Str("trace_id", TraceIDFromContext(r.Context())).
 					Msg("panic recovered")
 
-				http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+				writeProblem(w, r, http.StatusInternalServerError, "internal", "internal server error")
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -74,105 +247,287 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 
 // ── JWT Auth Middleware ───────────────────────────────────
 
+// TokenRevocationChecker reports whether an otherwise valid token was revoked.
+type TokenRevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error)
+}
+
+// APIKeyAuthenticator resolves a raw API key to the identity it acts as. It
+// returns nil without an error for keys that must be rejected.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, rawKey string) (*model.APIKeyPrincipal, error)
+}
+
+// Authentication failures reported by authenticate.
+var (
+	errMissingCredentials = errors.New("authorization header required")
+	errInvalidFormat      = errors.New("invalid authorization format")
+	errInvalidToken       = errors.New("invalid or expired token")
+	errInvalidClaims      = errors.New("invalid token claims")
+	errTokenRevoked       = errors.New("token has been revoked")
+	errInvalidAPIKey      = errors.New("invalid or revoked api key")
+)
+
+// isAuthError reports whether err is the caller's fault rather than a
+// failure of a backing store.
+func isAuthError(err error) bool {
+	for _, target := range []error{
+		errMissingCredentials, errInvalidFormat, errInvalidToken,
+		errInvalidClaims, errTokenRevoked, errInvalidAPIKey,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // JWTAuthMiddleware validates JWT tokens and injects user info into context.
-func JWTAuthMiddleware(secret string) func(http.Handler) http.Handler {
+// Tokens reported as revoked by revocations are rejected.
+//
+// When apiKeys is non-nil, API keys are accepted as well, either in an
+// "X-API-Key" header or as "Authorization: ApiKey <key>". They populate the
+// same context values as a token.
+func JWTAuthMiddleware(issuer *auth.Issuer, revocations TokenRevocationChecker, apiKeys APIKeyAuthenticator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, `{"error":"authorization header required"}`, http.StatusUnauthorized)
-				return
+			scheme, credential, err := parseCredentials(r.Header.Get("Authorization"), r.Header.Get("X-API-Key"))
+			if err == nil {
+				var ctx context.Context
+				ctx, err = authenticateCredential(r.Context(), scheme, credential, issuer, revocations, apiKeys)
+				if err == nil {
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
 			}
 
-			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				http.Error(w, `{"error":"invalid authorization format"}`, http.StatusUnauthorized)
+			if isAuthError(err) {
+				writeProblem(w, r, http.StatusUnauthorized, "unauthenticated", err.Error())
 				return
 			}
+			log.Error().Err(err).Str("trace_id", TraceIDFromContext(r.Context())).Msg("credential check failed")
+			writeProblem(w, r, http.StatusServiceUnavailable, "service_unavailable", "service unavailable")
+		})
+	}
+}
 
-			token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return []byte(secret), nil
-			})
+// parseCredentials picks the credential from an Authorization header value
+// and an API key header value, returning its lowercased scheme.
+func parseCredentials(authorization, apiKey string) (string, string, error) {
+	if apiKey != "" {
+		return "apikey", apiKey, nil
+	}
+	if authorization == "" {
+		return "", "", errMissingCredentials
+	}
 
-			if err != nil || !token.Valid {
-				http.Error(w, `{"error":"invalid or expired token"}`, http.StatusUnauthorized)
-				return
-			}
+	parts := strings.SplitN(authorization, " ", 2)
+	if len(parts) != 2 {
+		return "", "", errInvalidFormat
+	}
 
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok {
-				http.Error(w, `{"error":"invalid token claims"}`, http.StatusUnauthorized)
-				return
-			}
+	scheme := strings.ToLower(parts[0])
+	if scheme != "bearer" && scheme != "apikey" {
+		return "", "", errInvalidFormat
+	}
+
+	return scheme, parts[1], nil
+}
 
-			// Inject user info into context
-			ctx := context.WithValue(r.Context(), UserIDKey, claims["sub"])
-			ctx = context.WithValue(ctx, RoleKey, claims["role"])
+// authenticateCredential dispatches on the credential scheme.
+func authenticateCredential(
+	ctx context.Context,
+	scheme, credential string,
+	issuer *auth.Issuer,
+	revocations TokenRevocationChecker,
+	apiKeys APIKeyAuthenticator,
+) (context.Context, error) {
+	if scheme == "bearer" {
+		return authenticate(ctx, credential, issuer, revocations)
+	}
+	if apiKeys == nil {
+		return nil, errInvalidFormat
+	}
+	return authenticateAPIKey(ctx, credential, apiKeys)
+}
 
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
+// authenticateAPIKey verifies an API key and returns ctx enriched with the
+// identity of its owner, limited to the key's scopes.
+func authenticateAPIKey(ctx context.Context, rawKey string, apiKeys APIKeyAuthenticator) (context.Context, error) {
+	principal, err := apiKeys.AuthenticateAPIKey(ctx, rawKey)
+	if err != nil {
+		return nil, err
 	}
+	if principal == nil {
+		return nil, errInvalidAPIKey
+	}
+
+	ctx = context.WithValue(ctx, UserIDKey, principal.UserID.String())
+	ctx = context.WithValue(ctx, RoleKey, string(principal.Role))
+	ctx = context.WithValue(ctx, APIKeyIDKey, principal.KeyID.String())
+	ctx = context.WithValue(ctx, PermissionsKey, principal.Permissions)
+
+	return ctx, nil
+}
+
+// authenticate verifies a bearer token and returns ctx enriched with the
+// caller's identity. It is shared by the HTTP middleware and gRPC interceptor.
+func authenticate(ctx context.Context, tokenStr string, issuer *auth.Issuer, revocations TokenRevocationChecker) (context.Context, error) {
+	claims, err := issuer.Verify(tokenStr)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, errInvalidClaims
+	}
+
+	issuedAt := claims.IssueTime()
+	var expiresAt time.Time
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	perms := make([]model.Permission, len(claims.Permissions))
+	for i, p := range claims.Permissions {
+		perms[i] = model.Permission(p)
+	}
+
+	revoked, err := revocations.IsTokenRevoked(ctx, claims.ID, userID, issuedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errTokenRevoked
+	}
+
+	// Inject user info into context
+	ctx = context.WithValue(ctx, UserIDKey, claims.Subject)
+	ctx = context.WithValue(ctx, RoleKey, claims.Role)
+	ctx = context.WithValue(ctx, TokenIDKey, claims.ID)
+	ctx = context.WithValue(ctx, ExpiresAtKey, expiresAt)
+	ctx = context.WithValue(ctx, PermissionsKey, perms)
+
+	return ctx, nil
 }
 
 // ── Rate Limiting Middleware ──────────────────────────────
 
 // RateLimitMiddleware implements a simple token bucket rate limiter per IP.
 func RateLimitMiddleware(maxRequests int, window time.Duration) func(http.Handler) http.Handler {
-	type client struct {
-		count   int
-		resetAt time.Time
-	}
+	return NewRateLimiter(maxRequests, window).Handler
+}
+
+// RateLimiter limits the requests per client IP in fixed windows. Its limit
+// can be changed while serving.
+type RateLimiter struct {
+	mu          sync.Mutex
+	clients     map[string]*rateLimitClient
+	maxRequests int
+	window      time.Duration
+}
 
-	var (
-		mu      sync.Mutex
-		clients = make(map[string]*client)
-	)
+type rateLimitClient struct {
+	count   int
+	resetAt time.Time
+}
+
+// NewRateLimiter creates a limiter allowing maxRequests per window.
+func NewRateLimiter(maxRequests int, window time.Duration) *RateLimiter {
+	l := &RateLimiter{
+		clients:     make(map[string]*rateLimitClient),
+		maxRequests: maxRequests,
+		window:      window,
+	}
 
 	// Cleanup expired entries every minute
 	go func() {
 		for {
 			time.Sleep(time.Minute)
-			mu.Lock()
+			l.mu.Lock()
 			now := time.Now()
-			for ip, c := range clients {
+			for ip, c := range l.clients {
 				if now.After(c.resetAt) {
-					delete(clients, ip)
+					delete(l.clients, ip)
 				}
 			}
-			mu.Unlock()
+			l.mu.Unlock()
 		}
 	}()
 
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := r.RemoteAddr
+	return l
+}
 
-			mu.Lock()
-			c, exists := clients[ip]
-			now := time.Now()
+// SetLimit changes the limit. Windows already started keep their end but
+// are counted against the new maximum.
+func (l *RateLimiter) SetLimit(maxRequests int, window time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxRequests = maxRequests
+	l.window = window
+}
 
-			if !exists || now.After(c.resetAt) {
-				clients[ip] = &client{count: 1, resetAt: now.Add(window)}
-				mu.Unlock()
-				next.ServeHTTP(w, r)
-				return
-			}
+// Handler is the middleware enforcing the limit.
+func (l *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ClientIPFromRequest(r)
 
-			c.count++
-			if c.count > maxRequests {
-				mu.Unlock()
-				w.Header().Set("Retry-After", fmt.Sprintf("%d", int(time.Until(c.resetAt).Seconds())))
-				http.Error(w, `{"error":"rate limit exceeded"}`, http.StatusTooManyRequests)
-				return
-			}
-			mu.Unlock()
+		l.mu.Lock()
+		c, exists := l.clients[ip]
+		now := time.Now()
 
+		if !exists || now.After(c.resetAt) {
+			l.clients[ip] = &rateLimitClient{count: 1, resetAt: now.Add(l.window)}
+			l.mu.Unlock()
 			next.ServeHTTP(w, r)
-		})
-	}
+			return
+		}
+
+		c.count++
+		if c.count > l.maxRequests {
+			l.mu.Unlock()
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(time.Until(c.resetAt).Seconds())))
+			writeProblem(w, r, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded")
+			return
+		}
+		l.mu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ── CORS Middleware ───────────────────────────────────────
+
+// CORS applies a CORS policy whose allowed origins can be changed while
+// serving.
+type CORS struct {
+	mu      sync.RWMutex
+	options cors.Options
+	cors    *cors.Cors
+}
+
+// NewCORS creates the middleware from options.
+func NewCORS(options cors.Options) *CORS {
+	return &CORS{options: options, cors: cors.New(options)}
+}
+
+// SetAllowedOrigins replaces the allowed origins, keeping the other options.
+func (c *CORS) SetAllowedOrigins(origins []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.options.AllowedOrigins = origins
+	c.cors = cors.New(c.options)
+}
+
+// Handler is the middleware applying the current policy.
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.RLock()
+		policy := c.cors
+		c.mu.RUnlock()
+		policy.Handler(next).ServeHTTP(w, r)
+	})
 }
 
 // ── Metrics Middleware ────────────────────────────────────
@@ -188,6 +543,27 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 
 // ── gRPC Interceptors ────────────────────────────────────
 
+// GRPCTraceInterceptor is the gRPC counterpart of TraceMiddleware. It reads
+// "traceparent" or "x-request-id" metadata and returns the ID in the
+// "x-request-id" response header.
+func GRPCTraceInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		id := traceID(firstValue(md, "traceparent"), firstValue(md, "x-request-id"))
+
+		if err := grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id)); err != nil {
+			log.Warn().Err(err).Msg("failed to set trace header")
+		}
+
+		return handler(context.WithValue(ctx, TraceIDKey, id), req)
+	}
+}
+
 // GRPCLoggingInterceptor logs gRPC requests with duration.
 func GRPCLoggingInterceptor() grpc.UnaryServerInterceptor {
 	return func(
@@ -207,8 +583,54 @@ func GRPCLoggingInterceptor() grpc.UnaryServerInterceptor {
 			Str("method", info.FullMethod).
 			Str("code", st.Code().String()).
 			Dur("duration", duration).
+			Str("trace_id", TraceIDFromContext(ctx)).
 			Msg("gRPC request")
 
 		return resp, err
 	}
 }
+
+// GRPCAuthInterceptor validates the bearer token in the "authorization"
+// metadata and injects user info into context, like JWTAuthMiddleware. API
+// keys are accepted in "x-api-key" or as an "ApiKey" authorization value.
+// Methods whose rule is marked Public are passed through unauthenticated.
+func GRPCAuthInterceptor(
+	issuer *auth.Issuer,
+	revocations TokenRevocationChecker,
+	apiKeys APIKeyAuthenticator,
+	rules map[string]GRPCMethodRule,
+) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if rules[info.FullMethod].Public {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		scheme, credential, err := parseCredentials(firstValue(md, "authorization"), firstValue(md, "x-api-key"))
+		if err == nil {
+			var authCtx context.Context
+			authCtx, err = authenticateCredential(ctx, scheme, credential, issuer, revocations, apiKeys)
+			if err == nil {
+				return handler(authCtx, req)
+			}
+		}
+
+		if isAuthError(err) {
+			return nil, GRPCError(ctx, codes.Unauthenticated, "unauthenticated", err.Error(), nil)
+		}
+		log.Error().Err(err).Str("trace_id", TraceIDFromContext(ctx)).Msg("credential check failed")
+		return nil, GRPCError(ctx, codes.Unavailable, "service_unavailable", "service unavailable", nil)
+	}
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
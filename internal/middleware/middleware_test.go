@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{"direct client", "203.0.113.7:51234", nil, "203.0.113.7"},
+		{"direct client forging the header", "203.0.113.7:51234", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"through a trusted proxy", "10.0.0.2:443", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"through a chain of trusted proxies", "10.0.0.2:443", []string{"198.51.100.1, 10.1.1.1"}, "198.51.100.1"},
+		{"chain split over several headers", "10.0.0.2:443", []string{"198.51.100.1", "10.1.1.1"}, "198.51.100.1"},
+		{"forged hops before the client", "10.0.0.2:443", []string{"192.0.2.66, 198.51.100.1"}, "198.51.100.1"},
+		{"untrusted hop in the chain", "10.0.0.2:443", []string{"198.51.100.1, 203.0.113.9, 10.1.1.1"}, "203.0.113.9"},
+		{"malformed hop", "10.0.0.2:443", []string{"198.51.100.1, not-an-ip"}, "10.0.0.2"},
+		{"every hop trusted", "10.0.0.2:443", []string{"10.9.9.9"}, "10.9.9.9"},
+		{"trusted proxy without the header", "10.0.0.2:443", nil, "10.0.0.2"},
+		{"IPv6 proxy", "[2001:db8::1]:443", []string{"2001:db8:ffff::1, 198.51.100.1"}, "198.51.100.1"},
+		{"IPv4-mapped proxy", "[::ffff:10.0.0.2]:443", []string{"::ffff:198.51.100.1"}, "198.51.100.1"},
+		{"no port", "203.0.113.7", nil, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := NewClientIPResolver(trusted).Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = ClientIPFromRequest(r)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPResolverFollowsReloads(t *testing.T) {
+	resolver := NewClientIPResolver(nil)
+	if got := resolver.resolve("10.0.0.2", []string{"198.51.100.1"}); got != "10.0.0.2" {
+		t.Errorf("untrusted proxy: client IP = %q", got)
+	}
+
+	resolver.SetTrustedProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")})
+	if got := resolver.resolve("10.0.0.2", []string{"198.51.100.1"}); got != "198.51.100.1" {
+		t.Errorf("trusted proxy: client IP = %q", got)
+	}
+}
+
+func TestRateLimiterCountsClientsBehindAProxyApart(t *testing.T) {
+	resolver := NewClientIPResolver([]netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")})
+	h := resolver.Handler(NewRateLimiter(1, time.Minute).Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	request := func(client string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.2:443"
+		req.Header.Set("X-Forwarded-For", client)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := request("198.51.100.1"); code != http.StatusNoContent {
+		t.Fatalf("first client: status %d", code)
+	}
+	if code := request("198.51.100.2"); code != http.StatusNoContent {
+		t.Errorf("second client behind the same proxy: status %d, want %d", code, http.StatusNoContent)
+	}
+	if code := request("198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Errorf("first client again: status %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
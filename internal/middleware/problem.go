@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// ── Error Responses ───────────────────────────────────────
+
+// ErrorDomain identifies this service in google.rpc.ErrorInfo details.
+const ErrorDomain = "go-microservice-template"
+
+// WriteProblem writes p as an application/problem+json response. Type,
+// Title, Instance and TraceID are filled in from the request when empty.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p model.Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.TraceID == "" {
+		p.TraceID = TraceIDFromContext(r.Context())
+	}
+
+	w.Header().Set("Content-Type", model.ProblemContentType)
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Error().Err(err).Msg("failed to encode problem")
+	}
+}
+
+// writeProblem reports a failure detected by middleware itself.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	WriteProblem(w, r, model.Problem{Status: status, Code: code, Detail: detail})
+}
+
+// GRPCError builds a status carrying the same information as a problem
+// document: an ErrorInfo whose reason is the upper-cased error code, any
+// extra details, and a RequestInfo with the trace ID.
+func GRPCError(ctx context.Context, c codes.Code, code, message string, metadata map[string]string, details ...protoadapt.MessageV1) error {
+	all := make([]protoadapt.MessageV1, 0, len(details)+2)
+	all = append(all, &errdetails.ErrorInfo{
+		Reason:   strings.ToUpper(code),
+		Domain:   ErrorDomain,
+		Metadata: metadata,
+	})
+	all = append(all, details...)
+	if id := TraceIDFromContext(ctx); id != "" {
+		all = append(all, &errdetails.RequestInfo{RequestId: id})
+	}
+
+	st, err := status.New(c, message).WithDetails(all...)
+	if err != nil {
+		return status.Error(c, message)
+	}
+	return st.Err()
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"Go-Microservice-Template/internal/model"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWriteProblem(t *testing.T) {
+	tests := []struct {
+		name    string
+		problem model.Problem
+		traceID string
+		want    map[string]interface{}
+	}{
+		{
+			name:    "defaults from the request",
+			problem: model.Problem{Status: http.StatusNotFound, Code: "not_found", Detail: "resource not found"},
+			traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			want: map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Not Found",
+				"status":   float64(http.StatusNotFound),
+				"detail":   "resource not found",
+				"instance": "/api/v1/users/42",
+				"code":     "not_found",
+				"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+			},
+		},
+		{
+			name: "explicit members",
+			problem: model.Problem{
+				Type: "https://example.com/probs/locked", Title: "Locked", Status: http.StatusTooManyRequests,
+				Instance: "/login", Code: "account_locked", TraceID: "explicit",
+			},
+			traceID: "from-context",
+			want: map[string]interface{}{
+				"type":     "https://example.com/probs/locked",
+				"title":    "Locked",
+				"status":   float64(http.StatusTooManyRequests),
+				"instance": "/login",
+				"code":     "account_locked",
+				"trace_id": "explicit",
+			},
+		},
+		{
+			name: "extensions flattened, standard members win",
+			problem: model.Problem{Status: http.StatusBadRequest, Code: "weak_password", Extensions: map[string]interface{}{
+				"violations": []string{"too short"},
+				"code":       "overridden",
+			}},
+			want: map[string]interface{}{
+				"type":       "about:blank",
+				"title":      "Bad Request",
+				"status":     float64(http.StatusBadRequest),
+				"instance":   "/api/v1/users/42",
+				"code":       "weak_password",
+				"violations": []interface{}{"too short"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/users/42?x=1", nil)
+			if tt.traceID != "" {
+				req = req.WithContext(context.WithValue(req.Context(), TraceIDKey, tt.traceID))
+			}
+			rec := httptest.NewRecorder()
+
+			WriteProblem(rec, req, tt.problem)
+
+			if rec.Code != tt.problem.Status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.problem.Status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != model.ProblemContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, model.ProblemContentType)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode: %v; body: %s", err, rec.Body)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problem = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGRPCError(t *testing.T) {
+	ctx := context.WithValue(context.Background(), TraceIDKey, "4bf92f3577b34da6a3ce929d0e0e4736")
+	retry := &errdetails.RetryInfo{}
+
+	err := GRPCError(ctx, codes.ResourceExhausted, "account_locked", "too many failed login attempts",
+		map[string]string{"email": "locked"}, retry)
+
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted || st.Message() != "too many failed login attempts" {
+		t.Fatalf("status = %s %q", st.Code(), st.Message())
+	}
+	details := st.Details()
+	if len(details) != 3 {
+		t.Fatalf("details = %v, want ErrorInfo, RetryInfo and RequestInfo", details)
+	}
+	info, ok := details[0].(*errdetails.ErrorInfo)
+	if !ok || info.GetReason() != "ACCOUNT_LOCKED" || info.GetDomain() != ErrorDomain ||
+		!reflect.DeepEqual(info.GetMetadata(), map[string]string{"email": "locked"}) {
+		t.Errorf("ErrorInfo = %v", details[0])
+	}
+	if _, ok := details[1].(*errdetails.RetryInfo); !ok {
+		t.Errorf("details[1] = %v, want the RetryInfo passed in", details[1])
+	}
+	if req, ok := details[2].(*errdetails.RequestInfo); !ok || req.GetRequestId() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("RequestInfo = %v, want the trace ID", details[2])
+	}
+
+	// Without a trace ID there is no RequestInfo
+	st = status.Convert(GRPCError(context.Background(), codes.NotFound, "not_found", "resource not found", nil))
+	if len(st.Details()) != 1 {
+		t.Errorf("details = %v, want only ErrorInfo", st.Details())
+	}
+}
@@ -0,0 +1,365 @@
+// Package migrate applies versioned SQL migrations and records them in a
+// schema_migrations table, together with a checksum of each file so that
+// edits to an already applied migration are detected.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Errors returned by the migrator.
+var (
+	ErrChecksumMismatch = errors.New("applied migration has been modified")
+	ErrNoDownMigration  = errors.New("migration has no down file")
+	ErrUnknownMigration = errors.New("applied migration is not known to this build")
+)
+
+// lockID keys the advisory lock held while migrating, so that replicas
+// starting together apply each migration once.
+const lockID int64 = 0x6d6967726174 // "migrat"
+
+// fileName matches NNN_description.sql and NNN_description.down.sql.
+var fileName = regexp.MustCompile(`^(\d+)_([^.]+)(\.down)?\.sql$`)
+
+// replacesDirective is a line of an up file naming the checksum of an
+// earlier version of it, for edits that are safe to leave unapplied:
+//
+//	-- migrate:replaces <hex SHA-256>
+var replacesDirective = regexp.MustCompile(`(?m)^--\s*migrate:replaces\s+([0-9a-f]{64})\s*$`)
+
+// Migration is one schema version.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string // Empty if the migration cannot be rolled back
+	Checksum string // Hex SHA-256 of Up
+	// Replaces lists checksums of earlier versions of Up that databases may
+	// have applied instead, declared with migrate:replaces directives.
+	Replaces []string
+}
+
+// accepts reports whether a database that applied a migration with
+// checksum is up to date with mig.
+func (mig Migration) accepts(checksum string) bool {
+	if checksum == mig.Checksum {
+		return true
+	}
+	for _, c := range mig.Replaces {
+		if checksum == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Status reports whether a migration has been applied.
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	// Modified is set when the applied file differs from the embedded one.
+	Modified bool
+	// Unknown is set for applied versions missing from this build.
+	Unknown bool
+}
+
+// Load reads the migrations in the root of fsys, ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		match := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: bad version: %w", e.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", e.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files with different names: %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] != "" {
+			m.Down = string(body)
+			continue
+		}
+		if m.Up != "" {
+			return nil, fmt.Errorf("migration %d is defined twice", version)
+		}
+		sum := sha256.Sum256(body)
+		m.Up = string(body)
+		m.Checksum = hex.EncodeToString(sum[:])
+		for _, match := range replacesDirective.FindAllStringSubmatch(m.Up, -1) {
+			m.Replaces = append(m.Replaces, match[1])
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has a down file but no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+// New creates a migrator for the migrations in fsys.
+func New(pool *pgxpool.Pool, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+// applied describes a row of schema_migrations.
+type applied struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up applies every pending migration in order, each in its own transaction.
+// It refuses to run if an applied migration has been modified, unless the
+// migration declares the applied version as one it replaces; the recorded
+// checksum is then brought up to date.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		state, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if a, ok := state[mig.Version]; ok && !mig.accepts(a.checksum) {
+				return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, mig.Version, mig.Name)
+			}
+		}
+
+		for _, mig := range m.migrations {
+			a, ok := state[mig.Version]
+			if !ok || a.checksum == mig.Checksum {
+				continue
+			}
+			_, err := conn.Exec(ctx, `UPDATE schema_migrations SET checksum = $2 WHERE version = $1`, mig.Version, mig.Checksum)
+			if err != nil {
+				return fmt.Errorf("update checksum of %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			log.Info().Int64("version", mig.Version).Str("name", mig.Name).Msg("recorded the replacement of an applied migration")
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := state[mig.Version]; ok {
+				continue
+			}
+
+			start := time.Now()
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, mig.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx,
+					`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+					mig.Version, mig.Name, mig.Checksum,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("apply %d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			log.Info().
+				Int64("version", mig.Version).
+				Str("name", mig.Name).
+				Dur("duration", time.Since(start)).
+				Msg("applied migration")
+			done = append(done, mig)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		state, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int64, 0, len(state))
+		for v := range state {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+
+		for _, v := range versions {
+			mig, ok := m.find(v)
+			if !ok {
+				return fmt.Errorf("%w: %d_%s", ErrUnknownMigration, v, state[v].name)
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrNoDownMigration, mig.Version, mig.Name)
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, mig.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("roll back %d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			log.Info().Int64("version", mig.Version).Str("name", mig.Name).Msg("rolled back migration")
+			done = append(done, mig)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Status lists every known or applied migration, ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		state, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			s := Status{Version: mig.Version, Name: mig.Name}
+			if a, ok := state[mig.Version]; ok {
+				appliedAt := a.appliedAt
+				s.AppliedAt = &appliedAt
+				s.Modified = !mig.accepts(a.checksum)
+				delete(state, mig.Version)
+			}
+			statuses = append(statuses, s)
+		}
+
+		for v, a := range state {
+			appliedAt := a.appliedAt
+			statuses = append(statuses, Status{Version: v, Name: a.name, AppliedAt: &appliedAt, Unknown: true})
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+		return nil
+	})
+
+	return statuses, err
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+// withLock runs fn on a single connection holding the migration advisory
+// lock, after making sure schema_migrations exists.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context: ctx may be what made fn fail
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			log.Error().Err(err).Msg("failed to release migration lock")
+		}
+	}()
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			name       TEXT        NOT NULL,
+			checksum   CHAR(64)    NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func loadApplied(ctx context.Context, conn *pgxpool.Conn) (map[int64]applied, error) {
+	rows, err := conn.Query(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	state := make(map[int64]applied)
+	for rows.Next() {
+		var v int64
+		var a applied
+		if err := rows.Scan(&v, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		state[v] = a
+	}
+	return state, rows.Err()
+}
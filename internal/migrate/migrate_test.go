@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"Go-Microservice-Template/migrations"
+)
+
+func checksum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLoad(t *testing.T) {
+	old := checksum("CREATE TABLE t (id int);\n")
+	up := "-- migrate:replaces " + old + "\nCREATE TABLE IF NOT EXISTS t (id int);\n"
+	fsys := fstest.MapFS{
+		"002_add_index.sql":      {Data: []byte("CREATE INDEX i ON t (id);\n")},
+		"001_create_t.sql":       {Data: []byte(up)},
+		"001_create_t.down.sql":  {Data: []byte("DROP TABLE t;\n")},
+		"migrations.go":          {Data: []byte("package migrations\n")},
+		"002_add_index.down.sql": {Data: []byte("DROP INDEX i;\n")},
+	}
+
+	got, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "create_t", Up: up, Down: "DROP TABLE t;\n", Checksum: checksum(up), Replaces: []string{old}},
+		{Version: 2, Name: "add_index", Up: "CREATE INDEX i ON t (id);\n", Down: "DROP INDEX i;\n", Checksum: checksum("CREATE INDEX i ON t (id);\n")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadRejectsInvalidSets(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"down without up", fstest.MapFS{"001_a.down.sql": {}}, "down file but no up file"},
+		{"duplicate version", fstest.MapFS{"001_a.sql": {}, "001_b.sql": {}}, "different names"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	mig := Migration{Checksum: "current", Replaces: []string{"earlier"}}
+	for checksum, want := range map[string]bool{"current": true, "earlier": true, "edited": false} {
+		if got := mig.accepts(checksum); got != want {
+			t.Errorf("accepts(%q) = %t, want %t", checksum, got, want)
+		}
+	}
+}
+
+// The version of 001 that created its trigger unguarded was applied by
+// deployments that predate the runner and must stay accepted.
+func TestEmbeddedMigrationsAcceptPreviousUsersTable(t *testing.T) {
+	const unguarded = "67c692ddca3b27617434427950bec731a5bca56212783b936b3092f3e247cd2e"
+
+	all, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 || all[0].Version != 1 {
+		t.Fatal("001 is not the first migration")
+	}
+	if !all[0].accepts(unguarded) {
+		t.Error("001 no longer accepts the checksum of its unguarded version")
+	}
+	if !strings.Contains(all[0].Up, "DROP TRIGGER IF EXISTS update_users_updated_at") {
+		t.Error("001 creates its trigger unguarded")
+	}
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey is a long-lived credential owned by a user. Only the hash of the key
+// is stored; Prefix identifies it in listings and logs.
+type APIKey struct {
+	ID         uuid.UUID    `json:"id" db:"id"`
+	UserID     uuid.UUID    `json:"user_id" db:"user_id"`
+	Name       string       `json:"name" db:"name"`
+	Prefix     string       `json:"prefix" db:"prefix"`
+	KeyHash    string       `json:"-" db:"key_hash"` // Never serialized to JSON
+	Scopes     []Permission `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time   `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+}
+
+// Usable reports whether the key is neither revoked nor expired at now.
+func (k *APIKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// CreateAPIKeyRequest is the DTO for issuing an API key.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateAPIKeyResponse returns a new key. Key is shown only this once.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// APIKeyPrincipal is the identity a valid API key authenticates as.
+type APIKeyPrincipal struct {
+	KeyID       uuid.UUID
+	UserID      uuid.UUID
+	Role        Role
+	Permissions []Permission
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LinkedIdentity maps an external provider's subject ID to a local user.
+type LinkedIdentity struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	Provider    string    `json:"provider" db:"provider"`
+	Subject     string    `json:"subject" db:"subject"`
+	Email       string    `json:"email" db:"email"` // As asserted by the provider when linked
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at" db:"last_login_at"`
+}
+
+// OIDCAuthRequest is a started external login. State, Nonce and Verifier
+// must be kept by the client until the provider redirects back.
+type OIDCAuthRequest struct {
+	Provider string
+	URL      string
+	State    string
+	Nonce    string
+	Verifier string
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MFASettings is a user's TOTP enrollment. The second factor is only
+// enforced once EnabledAt is set.
+type MFASettings struct {
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	Secret       string     `json:"-" db:"secret"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty" db:"enabled_at"`
+	LastUsedStep int64      `json:"-" db:"last_used_step"` // Last accepted TOTP time step
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Enabled reports whether the second factor is required at login.
+func (m *MFASettings) Enabled() bool {
+	return m != nil && m.EnabledAt != nil
+}
+
+// MFAChallenge is returned by the password step of a login when the account
+// has two-factor authentication enabled.
+type MFAChallenge struct {
+	Status    string    `json:"status"` // Always "mfa_required"
+	MFAToken  string    `json:"mfa_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// MFAEnrollResponse carries a new TOTP secret for the authenticator app.
+type MFAEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFACodeRequest is the DTO for confirming enrollment or disabling MFA with
+// a TOTP or recovery code.
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFARecoveryCodesResponse lists freshly generated recovery codes. They are
+// shown once and only stored as hashes.
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAVerifyRequest is the DTO for the second step of a login.
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Permission names an action a caller may perform, in "resource:action" form.
+type Permission string
+
+// Built-in permissions checked by the handlers. They are seeded by the
+// migrations and granted to roles through the role_permissions table.
+const (
+	PermUsersRead      Permission = "users:read"
+	PermUsersWrite     Permission = "users:write"
+	PermUsersDelete    Permission = "users:delete"
+	PermSessionsRevoke Permission = "sessions:revoke"
+	PermRolesRead      Permission = "roles:read"
+	PermRolesWrite     Permission = "roles:write"
+	// PermMeRead lets API keys read the account of their owner, the one
+	// permission the built-in user role grants.
+	PermMeRead Permission = "me:read"
+)
+
+// RoleDefinition is a named set of permissions stored in the roles table.
+type RoleDefinition struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	Name        Role         `json:"name" db:"name"`
+	Description string       `json:"description" db:"description"`
+	Permissions []Permission `json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+}
+
+// IsBuiltIn reports whether the role is required by the application and
+// therefore cannot be deleted.
+func (r RoleDefinition) IsBuiltIn() bool {
+	return r.Name == RoleUser || r.Name == RoleAdmin
+}
+
+// PermissionDefinition is a permission stored in the permissions table.
+type PermissionDefinition struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Name        Permission `json:"name" db:"name"`
+	Description string     `json:"description" db:"description"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CreateRoleRequest is the DTO for role creation.
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required,min=2,max=20"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions"`
+}
+
+// SetRolePermissionsRequest is the DTO for replacing a role's permissions.
+type SetRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+// CreatePermissionRequest is the DTO for permission creation.
+type CreatePermissionRequest struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+// AssignRoleRequest is the DTO for granting a role to a user.
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import "encoding/json"
+
+// ProblemContentType is the media type of error responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document. Code is the stable error
+// code clients should branch on; Title and Detail are for people.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	TraceID  string `json:"trace_id,omitempty"`
+	// Extensions are serialized as additional top-level members, such as
+	// "fields" for validation errors.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON flattens Extensions into the document. Standard members win
+// over extensions of the same name.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem // Drops this method to avoid recursion
+	base, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return base, err
+	}
+
+	members := make(map[string]interface{}, len(p.Extensions)+8)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+
+	var standard map[string]json.RawMessage
+	if err := json.Unmarshal(base, &standard); err != nil {
+		return nil, err
+	}
+	for k, v := range standard {
+		members[k] = v
+	}
+
+	return json.Marshal(members)
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is a server-side record of an issued refresh token.
+// Tokens issued from the same login share a FamilyID so that reuse of a
+// rotated token can revoke the whole chain.
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	FamilyID   uuid.UUID  `json:"family_id" db:"family_id"`
+	TokenHash  string     `json:"-" db:"token_hash"` // SHA-256 of the raw token, never serialized
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	ReplacedBy *uuid.UUID `json:"replaced_by,omitempty" db:"replaced_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// RefreshRequest is the DTO for exchanging a refresh token.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// LogoutRequest is the DTO for ending a session. RefreshToken is optional;
+// when present its whole token family is revoked as well.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// ActionTokenPurpose names what a single-use action token may be used for.
+type ActionTokenPurpose string
+
+const (
+	PurposePasswordReset     ActionTokenPurpose = "password_reset"
+	PurposeEmailVerification ActionTokenPurpose = "email_verification"
+)
+
+// ActionToken is a single-use, expiring token sent to a user out of band,
+// for example in a password reset or verification email. Only its hash is stored.
+type ActionToken struct {
+	ID        uuid.UUID          `json:"id" db:"id"`
+	UserID    uuid.UUID          `json:"user_id" db:"user_id"`
+	Purpose   ActionTokenPurpose `json:"purpose" db:"purpose"`
+	TokenHash string             `json:"-" db:"token_hash"`
+	ExpiresAt time.Time          `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time         `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time          `json:"created_at" db:"created_at"`
+}
+
+// ForgotPasswordRequest is the DTO for requesting a password reset.
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest is the DTO for setting a new password with a reset token.
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// VerifyEmailRequest is the DTO for redeeming an email verification token.
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ResendVerificationRequest is the DTO for requesting a new verification token.
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
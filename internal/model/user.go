@@ -8,14 +8,15 @@ import (
 
 // User represents the core domain entity.
 type User struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Email     string    `json:"email" db:"email"`
-	Name      string    `json:"name" db:"name"`
-	Password  string    `json:"-" db:"password_hash"` // Never serialized to JSON
-	Role      Role      `json:"role" db:"role"`
-	Active    bool      `json:"active" db:"active"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID              uuid.UUID  `json:"id" db:"id"`
+	Email           string     `json:"email" db:"email"`
+	Name            string     `json:"name" db:"name"`
+	Password        string     `json:"-" db:"password_hash"` // Never serialized to JSON
+	Role            Role       `json:"role" db:"role"`
+	Active          bool       `json:"active" db:"active"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"` // Nil until verified
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 // Role defines user authorization levels.
@@ -28,28 +29,36 @@ const (
 
 // CreateUserRequest is the DTO for user creation.
 type CreateUserRequest struct {
-	Email    string `json:"email" validate:"required,email"`
+	Email    string `json:"email" validate:"required,email,max=255"`
 	Name     string `json:"name" validate:"required,min=2,max=100"`
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password" validate:"required"` // Strength is checked by the password policy
 }
 
 // UpdateUserRequest is the DTO for user updates.
 type UpdateUserRequest struct {
-	Email *string `json:"email,omitempty" validate:"omitempty,email"`
+	Email *string `json:"email,omitempty" validate:"omitempty,email,max=255"`
 	Name  *string `json:"name,omitempty" validate:"omitempty,min=2,max=100"`
 }
 
+// ChangePasswordRequest is the DTO for changing the caller's own password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
 // LoginRequest is the DTO for authentication.
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
 }
 
-// LoginResponse contains the JWT token.
+// LoginResponse contains the JWT access token and its refresh token.
 type LoginResponse struct {
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
-	User      User      `json:"user"`
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	User             User      `json:"user"`
 }
 
 // ListParams holds pagination and filtering parameters.
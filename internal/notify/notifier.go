@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Message is a notification addressed to a single user.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users out of band. Production deployments
+// plug in an email or SMS provider; the implementations here are meant for
+// local development.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the notifier selected by driver: "log" or "file". path is only
+// used by the file driver.
+func New(driver, path string) (Notifier, error) {
+	switch driver {
+	case "", "log":
+		return NewLogNotifier(), nil
+	case "file":
+		if path == "" {
+			return nil, fmt.Errorf("file notifier requires a path")
+		}
+		return NewFileNotifier(path), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", driver)
+	}
+}
+
+// ── Log Notifier ──────────────────────────────────────────
+
+type logNotifier struct{}
+
+// NewLogNotifier creates a notifier that writes messages to the application
+// log. Message bodies may contain secrets such as reset tokens, so it must
+// not be used in production.
+func NewLogNotifier() Notifier {
+	return logNotifier{}
+}
+
+func (logNotifier) Send(_ context.Context, msg Message) error {
+	log.Info().
+		Str("to", msg.To).
+		Str("subject", msg.Subject).
+		Str("body", msg.Body).
+		Msg("notification")
+	return nil
+}
+
+// ── File Notifier ─────────────────────────────────────────
+
+type fileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileNotifier creates a notifier that appends messages to the file at
+// path, like a local mailbox.
+func NewFileNotifier(path string) Notifier {
+	return &fileNotifier{path: path}
+}
+
+func (n *fileNotifier) Send(_ context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open notification file: %w", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().UTC().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	if err != nil {
+		return fmt.Errorf("write notification: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ActionTokenRepository defines the interface for single-use action tokens.
+type ActionTokenRepository interface {
+	// Create stores token and invalidates any unused token the user holds
+	// for the same purpose, so only the latest one sent can be redeemed.
+	Create(ctx context.Context, token *model.ActionToken) error
+	// Get returns the redeemable token with the given hash without using it
+	// up. It returns ErrNotFound like Consume.
+	Get(ctx context.Context, purpose model.ActionTokenPurpose, hash string) (*model.ActionToken, error)
+	// Consume marks the token with the given hash as used and returns it.
+	// It returns ErrNotFound if the token does not exist, has expired or
+	// was already used.
+	Consume(ctx context.Context, purpose model.ActionTokenPurpose, hash string) (*model.ActionToken, error)
+}
+
+// postgresActionTokenRepo implements ActionTokenRepository using PostgreSQL.
+type postgresActionTokenRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewActionTokenRepository creates a new PostgreSQL-backed action token repository.
+func NewActionTokenRepository(pool *pgxpool.Pool) ActionTokenRepository {
+	return &postgresActionTokenRepo{pool: pool}
+}
+
+func (r *postgresActionTokenRepo) Create(ctx context.Context, token *model.ActionToken) error {
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now().UTC()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin create action token: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	invalidate := `
+		UPDATE action_tokens SET used_at = $3
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`
+	if _, err := tx.Exec(ctx, invalidate, token.UserID, token.Purpose, token.CreatedAt); err != nil {
+		return fmt.Errorf("invalidate action tokens: %w", err)
+	}
+
+	insert := `
+		INSERT INTO action_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err = tx.Exec(ctx, insert,
+		token.ID, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt, token.CreatedAt,
+	)
+	if err != nil {
+		if isDuplicateError(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("insert action token: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit create action token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresActionTokenRepo) Get(ctx context.Context, purpose model.ActionTokenPurpose, hash string) (*model.ActionToken, error) {
+	query := `
+		SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at
+		FROM action_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+	`
+
+	var t model.ActionToken
+	err := r.pool.QueryRow(ctx, query, hash, purpose).Scan(
+		&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get action token: %w", err)
+	}
+
+	return &t, nil
+}
+
+func (r *postgresActionTokenRepo) Consume(ctx context.Context, purpose model.ActionTokenPurpose, hash string) (*model.ActionToken, error) {
+	// A single conditional UPDATE keeps concurrent redemptions from both
+	// succeeding: only one of them can flip used_at.
+	query := `
+		UPDATE action_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at
+	`
+
+	var t model.ActionToken
+	err := r.pool.QueryRow(ctx, query, hash, purpose).Scan(
+		&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("consume action token: %w", err)
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// APIKeyRepository defines the interface for API key data access.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error)
+	ListForUser(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error)
+	// Revoke revokes one of userID's keys. It returns ErrNotFound if the
+	// user has no such unrevoked key.
+	Revoke(ctx context.Context, userID, id uuid.UUID) error
+	// RevokeAllForUser revokes every unrevoked key of userID.
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) error
+	// TouchLastUsed records a use of the key. Writes are throttled to one
+	// per minute so that busy keys do not cost a write per request.
+	TouchLastUsed(ctx context.Context, id uuid.UUID) error
+}
+
+// postgresAPIKeyRepo implements APIKeyRepository using PostgreSQL.
+type postgresAPIKeyRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewAPIKeyRepository creates a new PostgreSQL-backed API key repository.
+func NewAPIKeyRepository(pool *pgxpool.Pool) APIKeyRepository {
+	return &postgresAPIKeyRepo{pool: pool}
+}
+
+const selectAPIKeys = `
+	SELECT id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at
+	FROM api_keys
+`
+
+func scanAPIKey(row pgx.Row) (*model.APIKey, error) {
+	var (
+		k      model.APIKey
+		scopes []string
+	)
+	err := row.Scan(
+		&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &scopes,
+		&k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	k.Scopes = make([]model.Permission, len(scopes))
+	for i, s := range scopes {
+		k.Scopes[i] = model.Permission(s)
+	}
+
+	return &k, nil
+}
+
+func (r *postgresAPIKeyRepo) Create(ctx context.Context, key *model.APIKey) error {
+	key.ID = uuid.New()
+	key.CreatedAt = time.Now().UTC()
+
+	scopes := make([]string, len(key.Scopes))
+	for i, s := range key.Scopes {
+		scopes[i] = string(s)
+	}
+
+	query := `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.pool.Exec(ctx, query,
+		key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, scopes, key.ExpiresAt, key.CreatedAt,
+	)
+	if err != nil {
+		if isDuplicateError(err) {
+			return ErrDuplicate
+		}
+		if isForeignKeyError(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("insert api key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresAPIKeyRepo) GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	key, err := scanAPIKey(r.pool.QueryRow(ctx, selectAPIKeys+` WHERE key_hash = $1`, keyHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (r *postgresAPIKeyRepo) ListForUser(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error) {
+	rows, err := r.pool.Query(ctx, selectAPIKeys+` WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *postgresAPIKeyRepo) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	query := `UPDATE api_keys SET revoked_at = $3 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.pool.Exec(ctx, query, id, userID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *postgresAPIKeyRepo) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE api_keys SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL`
+
+	if _, err := r.pool.Exec(ctx, query, userID, time.Now().UTC()); err != nil {
+		return fmt.Errorf("revoke user api keys: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresAPIKeyRepo) TouchLastUsed(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE api_keys SET last_used_at = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2 - INTERVAL '1 minute')
+	`
+
+	if _, err := r.pool.Exec(ctx, query, id, time.Now().UTC()); err != nil {
+		return fmt.Errorf("touch api key: %w", err)
+	}
+
+	return nil
+}
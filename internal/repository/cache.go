@@ -4,7 +4,9 @@ import (
 	"Go-Microservice-Template/internal/model"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,20 +19,33 @@ type UserCache interface {
 	Get(ctx context.Context, id uuid.UUID) (*model.User, error)
 	Set(ctx context.Context, user *model.User) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	// SetTTL changes the lifetime of entries written from now on.
+	SetTTL(ttl time.Duration)
 }
 
 type redisUserCache struct {
 	client *redis.Client
-	ttl    time.Duration
+
+	mu  sync.RWMutex
+	ttl time.Duration
 }
 
-// NewRedisClient creates a Redis client with connection verification.
-func NewRedisClient(ctx context.Context, url string) (*redis.Client, error) {
+// NewRedisClient creates a Redis client with connection verification. If
+// password is not nil it replaces the password in url for every new
+// connection.
+func NewRedisClient(ctx context.Context, url string, password PasswordFunc) (*redis.Client, error) {
 	opts, err := redis.ParseURL(url)
 	if err != nil {
 		return nil, fmt.Errorf("parse redis url: %w", err)
 	}
 
+	if password != nil {
+		username := opts.Username
+		opts.CredentialsProvider = func() (string, string) {
+			return username, password()
+		}
+	}
+
 	opts.PoolSize = 10
 	opts.MinIdleConns = 3
 	opts.ReadTimeout = 3 * time.Second
@@ -61,7 +76,7 @@ func (c *redisUserCache) Get(ctx context.Context, id uuid.UUID) (*model.User, er
 
 	data, err := c.client.Get(ctx, c.key(id)).Bytes()
 	if err != nil {
-		if err == redis.Nil {
+		if errors.Is(err, redis.Nil) {
 			return nil, nil // Cache miss, not an error
 		}
 		return nil, fmt.Errorf("cache get: %w", err)
@@ -88,7 +103,11 @@ func (c *redisUserCache) Set(ctx context.Context, user *model.User) error {
 		return fmt.Errorf("marshal user: %w", err)
 	}
 
-	if err := c.client.Set(ctx, c.key(user.ID), data, c.ttl).Err(); err != nil {
+	c.mu.RLock()
+	ttl := c.ttl
+	c.mu.RUnlock()
+
+	if err := c.client.Set(ctx, c.key(user.ID), data, ttl).Err(); err != nil {
 		// Cache write failure is non-fatal — log and continue
 		log.Warn().Err(err).Str("key", c.key(user.ID)).Msg("failed to write cache")
 		return nil
@@ -108,3 +127,9 @@ func (c *redisUserCache) Delete(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+func (c *redisUserCache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
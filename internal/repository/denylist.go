@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// TokenDenylist records revoked access tokens, either individually by jti or
+// for a whole user by a "tokens issued before" cut-off.
+type TokenDenylist interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error
+	// UserTokensRevokedBefore returns the zero time if no cut-off is set.
+	UserTokensRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error)
+}
+
+// tokenDenylist keeps revocations in Redis for fast lookups and mirrors them
+// to PostgreSQL, the source of truth. Redis writes are best effort, so a
+// missing key is never taken as an answer: lookups fall back to PostgreSQL
+// and cache what they found, with "not revoked" kept for notRevokedTTL only.
+type tokenDenylist struct {
+	client *redis.Client
+	pool   *pgxpool.Pool
+}
+
+const (
+	// notRevoked is cached for lookups that found no revocation.
+	notRevoked = "0"
+	// notRevokedTTL bounds how long a revocation whose Redis write failed
+	// can go unnoticed once a negative answer has been cached.
+	notRevokedTTL = 30 * time.Second
+)
+
+// NewTokenDenylist creates a denylist backed by Redis with a PostgreSQL fallback.
+// client may be nil, in which case only PostgreSQL is used.
+func NewTokenDenylist(client *redis.Client, pool *pgxpool.Pool) TokenDenylist {
+	return &tokenDenylist{client: client, pool: pool}
+}
+
+func (d *tokenDenylist) jtiKey(jti string) string {
+	return fmt.Sprintf("revoked:jti:%s", jti)
+}
+
+// userKey holds the user's cut-off in Unix microseconds. It replaced
+// revoked:user:<id>, which held seconds.
+func (d *tokenDenylist) userKey(id uuid.UUID) string {
+	return fmt.Sprintf("revoked:before:%s", id.String())
+}
+
+func (d *tokenDenylist) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`
+	if _, err := d.pool.Exec(ctx, query, jti, expiresAt.UTC()); err != nil {
+		return fmt.Errorf("insert revoked token: %w", err)
+	}
+
+	if d.client != nil {
+		ttl := time.Until(expiresAt)
+		if ttl <= 0 {
+			return nil
+		}
+		if err := d.client.Set(ctx, d.jtiKey(jti), 1, ttl).Err(); err != nil {
+			log.Warn().Err(err).Str("key", d.jtiKey(jti)).Msg("failed to write denylist entry")
+		}
+	}
+
+	return nil
+}
+
+func (d *tokenDenylist) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	missed := false
+	if d.client != nil {
+		v, err := d.client.Get(ctx, d.jtiKey(jti)).Result()
+		switch {
+		case err == nil:
+			return v != notRevoked, nil
+		case errors.Is(err, redis.Nil):
+			missed = true
+		default:
+			log.Warn().Err(err).Msg("denylist lookup failed, falling back to database")
+		}
+	}
+
+	query := `SELECT expires_at FROM revoked_tokens WHERE jti = $1 AND expires_at > NOW()`
+
+	var expiresAt time.Time
+	err := d.pool.QueryRow(ctx, query, jti).Scan(&expiresAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("check revoked token: %w", err)
+	}
+	revoked := err == nil
+
+	if missed {
+		if ttl := time.Until(expiresAt); revoked && ttl > 0 {
+			d.backfill(ctx, d.jtiKey(jti), 1, ttl)
+		} else if !revoked {
+			d.backfill(ctx, d.jtiKey(jti), notRevoked, notRevokedTTL)
+		}
+	}
+
+	return revoked, nil
+}
+
+func (d *tokenDenylist) RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	// both stores keep microseconds, and must agree on the cut-off
+	before = before.Truncate(time.Microsecond)
+	query := `
+		INSERT INTO user_token_revocations (user_id, revoked_before)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET revoked_before = EXCLUDED.revoked_before
+	`
+	if _, err := d.pool.Exec(ctx, query, userID, before.UTC()); err != nil {
+		return fmt.Errorf("upsert user token revocation: %w", err)
+	}
+
+	if d.client != nil {
+		if err := d.client.Set(ctx, d.userKey(userID), before.UnixMicro(), 0).Err(); err != nil {
+			log.Warn().Err(err).Str("key", d.userKey(userID)).Msg("failed to write denylist entry")
+		}
+	}
+
+	return nil
+}
+
+func (d *tokenDenylist) UserTokensRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	missed := false
+	if d.client != nil {
+		v, err := d.client.Get(ctx, d.userKey(userID)).Result()
+		switch {
+		case err == nil:
+			if v == notRevoked {
+				return time.Time{}, nil
+			}
+			if us, convErr := strconv.ParseInt(v, 10, 64); convErr == nil {
+				return time.UnixMicro(us), nil
+			}
+			log.Warn().Str("key", d.userKey(userID)).Msg("corrupted denylist entry, using database")
+		case errors.Is(err, redis.Nil):
+			missed = true
+		default:
+			log.Warn().Err(err).Msg("denylist lookup failed, falling back to database")
+		}
+	}
+
+	query := `SELECT revoked_before FROM user_token_revocations WHERE user_id = $1`
+
+	var before time.Time
+	err := d.pool.QueryRow(ctx, query, userID).Scan(&before)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("get user token revocation: %w", err)
+	}
+
+	if missed {
+		if before.IsZero() {
+			d.backfill(ctx, d.userKey(userID), notRevoked, notRevokedTTL)
+		} else {
+			d.backfill(ctx, d.userKey(userID), before.UnixMicro(), 0)
+		}
+	}
+
+	return before, nil
+}
+
+// backfill caches a database answer unless the key was written meanwhile,
+// so that it never overwrites a revocation that raced the lookup.
+func (d *tokenDenylist) backfill(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	if err := d.client.SetNX(ctx, key, value, ttl).Err(); err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("failed to write denylist entry")
+	}
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Go-Microservice-Template/internal/model"
+)
+
+func TestDenylistFallsBackToDatabaseOnRedisMiss(t *testing.T) {
+	ctx := context.Background()
+	pool := migratedPool(t)
+	mr, client := newTestRedis(t)
+	denylist := NewTokenDenylist(client, pool)
+
+	user := &model.User{Email: "user@example.com", Name: "User", Password: "hash", Role: model.RoleUser, Active: true}
+	if err := NewUserRepository(pool).Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	cutoff := time.Now().Truncate(time.Microsecond)
+	if err := denylist.RevokeToken(ctx, "revoked", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := denylist.RevokeUserTokens(ctx, user.ID, cutoff); err != nil {
+		t.Fatal(err)
+	}
+
+	// lose every Redis entry, as after a restart or an eviction
+	mr.FlushAll()
+
+	tests := []struct {
+		jti     string
+		want    bool
+		wantTTL time.Duration
+	}{
+		{"revoked", true, time.Hour},
+		{"unknown", false, notRevokedTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.jti, func(t *testing.T) {
+			revoked, err := denylist.IsTokenRevoked(ctx, tt.jti)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tt.want {
+				t.Errorf("revoked = %v, want %v", revoked, tt.want)
+			}
+			if ttl := mr.TTL("revoked:jti:" + tt.jti); ttl <= 0 || ttl > tt.wantTTL {
+				t.Errorf("cached for %s, want at most %s", ttl, tt.wantTTL)
+			}
+		})
+	}
+
+	before, err := denylist.UserTokensRevokedBefore(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !before.Equal(cutoff) {
+		t.Errorf("cut-off = %s, want %s", before, cutoff)
+	}
+	if !mr.Exists("revoked:before:" + user.ID.String()) {
+		t.Error("cut-off not cached after the miss")
+	}
+
+	// a revocation overrides the cached negative answer
+	if err := denylist.RevokeToken(ctx, "unknown", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := denylist.IsTokenRevoked(ctx, "unknown"); err != nil || !revoked {
+		t.Errorf("IsTokenRevoked after revoking = %v, %v, want true", revoked, err)
+	}
+}
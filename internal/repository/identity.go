@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IdentityRepository defines the interface for linked external identities.
+type IdentityRepository interface {
+	// Get returns the identity for a provider subject and records the login.
+	Get(ctx context.Context, provider, subject string) (*model.LinkedIdentity, error)
+	Create(ctx context.Context, identity *model.LinkedIdentity) error
+}
+
+// postgresIdentityRepo implements IdentityRepository using PostgreSQL.
+type postgresIdentityRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewIdentityRepository creates a new PostgreSQL-backed identity repository.
+func NewIdentityRepository(pool *pgxpool.Pool) IdentityRepository {
+	return &postgresIdentityRepo{pool: pool}
+}
+
+func (r *postgresIdentityRepo) Get(ctx context.Context, provider, subject string) (*model.LinkedIdentity, error) {
+	query := `
+		UPDATE linked_identities SET last_login_at = $3
+		WHERE provider = $1 AND subject = $2
+		RETURNING id, user_id, provider, subject, email, created_at, last_login_at
+	`
+
+	var i model.LinkedIdentity
+	err := r.pool.QueryRow(ctx, query, provider, subject, time.Now().UTC()).Scan(
+		&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &i.LastLoginAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get linked identity: %w", err)
+	}
+
+	return &i, nil
+}
+
+func (r *postgresIdentityRepo) Create(ctx context.Context, identity *model.LinkedIdentity) error {
+	identity.ID = uuid.New()
+	identity.CreatedAt = time.Now().UTC()
+	identity.LastLoginAt = identity.CreatedAt
+
+	query := `
+		INSERT INTO linked_identities (id, user_id, provider, subject, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.pool.Exec(ctx, query,
+		identity.ID, identity.UserID, identity.Provider, identity.Subject,
+		identity.Email, identity.CreatedAt, identity.LastLoginAt,
+	)
+	if err != nil {
+		if isDuplicateError(err) {
+			return ErrDuplicate
+		}
+		if isForeignKeyError(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("insert linked identity: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// LoginAttemptStore tracks failed logins and temporary locks per key, where a
+// key identifies an email address or client IP.
+type LoginAttemptStore interface {
+	// RegisterFailure increments the failure counter for key and returns the
+	// new count. Counters expire window after the first failure.
+	RegisterFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	Lock(ctx context.Context, key string, d time.Duration) error
+	// LockedUntil returns the zero time if key is not locked.
+	LockedUntil(ctx context.Context, key string) (time.Time, error)
+	// Reset clears both the failure counter and any lock for key.
+	Reset(ctx context.Context, key string) error
+}
+
+// loginAttemptStore keeps state in Redis and falls back to process memory
+// when Redis is missing or failing. The memory fallback is per replica, so
+// limits are looser during an outage but never disabled.
+type loginAttemptStore struct {
+	client *redis.Client
+	memory *memoryAttemptStore
+}
+
+// NewLoginAttemptStore creates a Redis-backed attempt store with a memory
+// fallback holding up to memoryEntries keys. client may be nil.
+func NewLoginAttemptStore(client *redis.Client, memoryEntries int) LoginAttemptStore {
+	return &loginAttemptStore{client: client, memory: newMemoryAttemptStore(memoryEntries)}
+}
+
+// registerFailureScript increments a failure counter and opens its window
+// in one step, so that no counter is ever left without an expiry. A counter
+// found without one, e.g. written by an older version, gets one as well.
+var registerFailureScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+func (s *loginAttemptStore) failKey(key string) string {
+	return fmt.Sprintf("login:fail:%s", key)
+}
+
+func (s *loginAttemptStore) lockKey(key string) string {
+	return fmt.Sprintf("login:lock:%s", key)
+}
+
+func (s *loginAttemptStore) RegisterFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	if s.client != nil {
+		n, err := registerFailureScript.Run(ctx, s.client, []string{s.failKey(key)}, window.Milliseconds()).Int64()
+		if err == nil {
+			return int(n), nil
+		}
+		log.Warn().Err(err).Msg("login attempt store unavailable, using memory")
+	}
+	return s.memory.RegisterFailure(ctx, key, window)
+}
+
+func (s *loginAttemptStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	if s.client != nil {
+		until := time.Now().Add(d)
+		err := s.client.Set(ctx, s.lockKey(key), until.UnixMilli(), d).Err()
+		if err == nil {
+			return nil
+		}
+		log.Warn().Err(err).Msg("login attempt store unavailable, using memory")
+	}
+	return s.memory.Lock(ctx, key, d)
+}
+
+func (s *loginAttemptStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	if s.client != nil {
+		ms, err := s.client.Get(ctx, s.lockKey(key)).Int64()
+		switch {
+		case err == nil:
+			return time.UnixMilli(ms), nil
+		case errors.Is(err, redis.Nil):
+			// Locks taken during an outage only exist in memory
+			return s.memory.LockedUntil(ctx, key)
+		default:
+			log.Warn().Err(err).Msg("login attempt store unavailable, using memory")
+		}
+	}
+	return s.memory.LockedUntil(ctx, key)
+}
+
+func (s *loginAttemptStore) Reset(ctx context.Context, key string) error {
+	if s.client != nil {
+		if err := s.client.Del(ctx, s.failKey(key), s.lockKey(key)).Err(); err != nil {
+			log.Warn().Err(err).Msg("login attempt store unavailable, using memory")
+		}
+	}
+	return s.memory.Reset(ctx, key)
+}
+
+// ── Memory Fallback ───────────────────────────────────────
+
+type attemptEntry struct {
+	key         string
+	failures    int
+	resetAt     time.Time
+	lockedUntil time.Time
+	// locked is whether the entry is kept in the locked list.
+	locked bool
+}
+
+// memoryAttemptStore is a size-bounded store of attempt entries. Once full,
+// it evicts the least recently used unlocked entry, so that a flood of
+// failures for junk keys cannot push out the locks it is meant to enforce.
+// Locks are only evicted, oldest first, when nothing else is left.
+type memoryAttemptStore struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*list.Element // of *attemptEntry
+	// unlocked is ordered most recently used first, locked most recently
+	// locked first.
+	unlocked *list.List
+	locked   *list.List
+}
+
+func newMemoryAttemptStore(max int) *memoryAttemptStore {
+	return &memoryAttemptStore{max: max, entries: make(map[string]*list.Element), unlocked: list.New(), locked: list.New()}
+}
+
+// entry returns the live entry for key and marks it used, dropping it if
+// fully expired. Callers must hold mu.
+func (m *memoryAttemptStore) entry(key string, now time.Time) *attemptEntry {
+	el, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*attemptEntry)
+	if now.After(e.resetAt) && now.After(e.lockedUntil) {
+		m.remove(el)
+		return nil
+	}
+	if !e.locked {
+		m.unlocked.MoveToFront(el)
+	}
+	return e
+}
+
+// add stores a new entry for key, evicting another one if the store is
+// full. Callers must hold mu.
+func (m *memoryAttemptStore) add(key string, now time.Time) *attemptEntry {
+	if len(m.entries) >= m.max {
+		m.evict(now)
+	}
+	e := &attemptEntry{key: key}
+	m.entries[key] = m.unlocked.PushFront(e)
+	return e
+}
+
+// evict removes the oldest lock if it has expired, else the least recently
+// used unlocked entry, else the oldest lock.
+// Callers must hold mu.
+func (m *memoryAttemptStore) evict(now time.Time) {
+	if el := m.locked.Back(); el != nil && !now.Before(el.Value.(*attemptEntry).lockedUntil) {
+		m.remove(el)
+		return
+	}
+	if el := m.unlocked.Back(); el != nil {
+		m.remove(el)
+		return
+	}
+	if el := m.locked.Back(); el != nil {
+		m.remove(el)
+	}
+}
+
+// lock moves e to the front of the locked list. Callers must hold mu.
+func (m *memoryAttemptStore) lock(e *attemptEntry) {
+	el := m.entries[e.key]
+	if e.locked {
+		m.locked.MoveToFront(el)
+		return
+	}
+	m.unlocked.Remove(el)
+	e.locked = true
+	m.entries[e.key] = m.locked.PushFront(e)
+}
+
+func (m *memoryAttemptStore) remove(el *list.Element) {
+	e := el.Value.(*attemptEntry)
+	if e.locked {
+		m.locked.Remove(el)
+	} else {
+		m.unlocked.Remove(el)
+	}
+	delete(m.entries, e.key)
+}
+
+func (m *memoryAttemptStore) RegisterFailure(_ context.Context, key string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	e := m.entry(key, now)
+	if e == nil {
+		e = m.add(key, now)
+	}
+	if now.After(e.resetAt) {
+		e.failures = 0
+		e.resetAt = now.Add(window)
+	}
+	e.failures++
+
+	return e.failures, nil
+}
+
+func (m *memoryAttemptStore) Lock(_ context.Context, key string, d time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	e := m.entry(key, now)
+	if e == nil {
+		e = m.add(key, now)
+	}
+	e.lockedUntil = now.Add(d)
+	m.lock(e)
+
+	return nil
+}
+
+func (m *memoryAttemptStore) LockedUntil(_ context.Context, key string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if e := m.entry(key, now); e != nil && now.Before(e.lockedUntil) {
+		return e.lockedUntil, nil
+	}
+
+	return time.Time{}, nil
+}
+
+func (m *memoryAttemptStore) Reset(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		m.remove(el)
+	}
+
+	return nil
+}
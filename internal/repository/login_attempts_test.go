@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	// Fail fast once the server is closed
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { _ = client.Close() })
+	return mr, client
+}
+
+func TestRegisterFailureOpensWindowAtomically(t *testing.T) {
+	const window = time.Minute
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// setup prepares the counter of key "k" before the failure
+		setup     func(mr *miniredis.Miniredis)
+		wantCount int
+		wantTTL   time.Duration
+	}{
+		{"first failure", func(*miniredis.Miniredis) {}, 1, window},
+		{"counter in its window", func(mr *miniredis.Miniredis) {
+			mr.Set("login:fail:k", "2")
+			mr.SetTTL("login:fail:k", 30*time.Second)
+		}, 3, 30 * time.Second},
+		{"counter without expiry", func(mr *miniredis.Miniredis) {
+			mr.Set("login:fail:k", "4")
+		}, 5, window},
+		{"window passed", func(mr *miniredis.Miniredis) {
+			mr.Set("login:fail:k", "4")
+			mr.SetTTL("login:fail:k", time.Second)
+			mr.FastForward(2 * time.Second)
+		}, 1, window},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr, client := newTestRedis(t)
+			store := NewLoginAttemptStore(client, 100)
+			tt.setup(mr)
+
+			n, err := store.RegisterFailure(ctx, "k", window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.wantCount {
+				t.Errorf("count = %d, want %d", n, tt.wantCount)
+			}
+			if ttl := mr.TTL("login:fail:k"); ttl != tt.wantTTL {
+				t.Errorf("TTL = %s, want %s", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestLoginAttemptStoreFallsBackToMemory(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newTestRedis(t)
+	store := NewLoginAttemptStore(client, 100)
+
+	if _, err := store.RegisterFailure(ctx, "k", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	mr.Close()
+
+	for want := 1; want <= 2; want++ {
+		n, err := store.RegisterFailure(ctx, "k", time.Minute)
+		if err != nil {
+			t.Fatalf("RegisterFailure during an outage: %v", err)
+		}
+		if n != want {
+			t.Errorf("count = %d, want %d counted in memory", n, want)
+		}
+	}
+	if err := store.Lock(ctx, "k", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if until, _ := store.LockedUntil(ctx, "k"); until.IsZero() {
+		t.Error("lock taken during the outage is not reported")
+	}
+}
+
+func TestMemoryAttemptStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryAttemptStore(3)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := m.RegisterFailure(ctx, key, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Using "a" leaves "b" as the least recently used
+	if err := m.Lock(ctx, "a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.RegisterFailure(ctx, "d", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		kept bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"c", true},
+		{"d", true},
+	}
+	for _, tt := range tests {
+		if _, ok := m.entries[tt.key]; ok != tt.kept {
+			t.Errorf("%s kept = %t, want %t", tt.key, ok, tt.kept)
+		}
+	}
+	if until, _ := m.LockedUntil(ctx, "a"); until.IsZero() {
+		t.Error("lock of a recently used key was evicted")
+	}
+
+	for i := 0; i < 100; i++ {
+		if _, err := m.RegisterFailure(ctx, fmt.Sprintf("flood-%d", i), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := m.unlocked.Len() + m.locked.Len(); len(m.entries) != 3 || n != 3 {
+		t.Errorf("%d entries and %d in lists, want the bound of 3", len(m.entries), n)
+	}
+	if until, _ := m.LockedUntil(ctx, "a"); until.IsZero() {
+		t.Error("lock evicted by a flood of failures")
+	}
+}
+
+func TestMemoryAttemptStoreEvictsLocksLast(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// locks are taken in order, expired for no time at all
+		locks   []string
+		expired string
+		want    map[string]bool
+	}{
+		{
+			name:  "unlocked entries go first",
+			locks: []string{"a"},
+			want:  map[string]bool{"a": true, "b": false, "c": true, "new": true},
+		},
+		{
+			name:  "then the oldest lock",
+			locks: []string{"c", "a", "b"},
+			want:  map[string]bool{"a": true, "b": true, "c": false, "new": true},
+		},
+		{
+			name:    "expired locks before unlocked entries",
+			locks:   []string{"a"},
+			expired: "a",
+			want:    map[string]bool{"a": false, "b": true, "c": true, "new": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMemoryAttemptStore(3)
+			for _, key := range []string{"a", "b", "c"} {
+				if _, err := m.RegisterFailure(ctx, key, time.Minute); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, key := range tt.locks {
+				d := time.Minute
+				if key == tt.expired {
+					d = 0
+				}
+				if err := m.Lock(ctx, key, d); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := m.RegisterFailure(ctx, "new", time.Minute); err != nil {
+				t.Fatal(err)
+			}
+
+			for key, want := range tt.want {
+				if _, ok := m.entries[key]; ok != want {
+					t.Errorf("%s kept = %t, want %t", key, ok, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MFARepository defines the interface for TOTP enrollments and recovery codes.
+type MFARepository interface {
+	Get(ctx context.Context, userID uuid.UUID) (*model.MFASettings, error)
+	// SaveSecret starts or restarts enrollment. It returns ErrDuplicate if
+	// MFA is already enabled for the user.
+	SaveSecret(ctx context.Context, userID uuid.UUID, secret string) error
+	// ReplaceSecret stores secret in place of old, unless the secret was
+	// changed meanwhile, e.g. to encrypt one stored in plain text.
+	ReplaceSecret(ctx context.Context, userID uuid.UUID, old, secret string) error
+	// Enable turns on MFA and replaces the user's recovery codes.
+	Enable(ctx context.Context, userID uuid.UUID, recoveryCodeHashes []string) error
+	Disable(ctx context.Context, userID uuid.UUID) error
+	// UseStep records step as the last accepted TOTP time step. It returns
+	// ErrTokenRevoked if a code for step or a later one was already used.
+	UseStep(ctx context.Context, userID uuid.UUID, step int64) error
+	// UseRecoveryCode marks an unused recovery code as used. It returns
+	// ErrNotFound if no such unused code exists.
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error
+}
+
+// postgresMFARepo implements MFARepository using PostgreSQL.
+type postgresMFARepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewMFARepository creates a new PostgreSQL-backed MFA repository.
+func NewMFARepository(pool *pgxpool.Pool) MFARepository {
+	return &postgresMFARepo{pool: pool}
+}
+
+func (r *postgresMFARepo) Get(ctx context.Context, userID uuid.UUID) (*model.MFASettings, error) {
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+
+	var m model.MFASettings
+	err := r.pool.QueryRow(ctx, query, userID).Scan(
+		&m.UserID, &m.Secret, &m.EnabledAt, &m.LastUsedStep, &m.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get mfa settings: %w", err)
+	}
+
+	return &m, nil
+}
+
+func (r *postgresMFARepo) SaveSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	// Never overwrite the secret of an enabled enrollment
+	query := `
+		INSERT INTO user_mfa (user_id, secret, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = EXCLUDED.created_at
+		WHERE user_mfa.enabled_at IS NULL
+	`
+
+	result, err := r.pool.Exec(ctx, query, userID, secret, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("save mfa secret: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrDuplicate
+	}
+
+	return nil
+}
+
+func (r *postgresMFARepo) ReplaceSecret(ctx context.Context, userID uuid.UUID, old, secret string) error {
+	query := `UPDATE user_mfa SET secret = $3 WHERE user_id = $1 AND secret = $2`
+
+	if _, err := r.pool.Exec(ctx, query, userID, old, secret); err != nil {
+		return fmt.Errorf("replace mfa secret: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresMFARepo) Enable(ctx context.Context, userID uuid.UUID, recoveryCodeHashes []string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin enable mfa: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	result, err := tx.Exec(ctx, `UPDATE user_mfa SET enabled_at = $2 WHERE user_id = $1`, userID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("enable mfa: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	for _, hash := range recoveryCodeHashes {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO mfa_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)`,
+			uuid.New(), userID, hash,
+		)
+		if err != nil {
+			return fmt.Errorf("insert recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit enable mfa: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresMFARepo) Disable(ctx context.Context, userID uuid.UUID) error {
+	// Recovery codes belong to the enrollment and go with it
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin disable mfa: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	result, err := tx.Exec(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("disable mfa: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit disable mfa: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresMFARepo) UseStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	// The comparison makes concurrent use of the same code race on the row
+	// lock: only one of them can advance last_used_step.
+	query := `UPDATE user_mfa SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`
+
+	result, err := r.pool.Exec(ctx, query, userID, step)
+	if err != nil {
+		return fmt.Errorf("use mfa step: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+func (r *postgresMFARepo) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	query := `
+		UPDATE mfa_recovery_codes SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	result, err := r.pool.Exec(ctx, query, userID, codeHash, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("use recovery code: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func TestCaseInsensitiveEmailMigration(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	if err := migrateTo(t, pool, 9); err != nil {
+		t.Fatal(err)
+	}
+
+	// Before 010, addresses differing only by case were distinct accounts
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	insert := func(email string) uuid.UUID {
+		t.Helper()
+		var id uuid.UUID
+		created = created.Add(time.Hour)
+		err := pool.QueryRow(ctx,
+			`INSERT INTO users (email, name, password_hash, created_at) VALUES ($1, 'User', 'hash', $2) RETURNING id`,
+			email, created).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	first := insert("Bob@Example.com")
+	second := insert(" bob@example.COM")
+	carol := insert("  Carol@Example.COM ")
+	insert("dave@example.com")
+
+	err := migrateTo(t, pool, 10)
+	if err == nil {
+		t.Fatal("migration 010 applied over accounts differing only by case")
+	}
+	want := "bob@example.com: " + first.String() + ", " + second.String()
+	if !strings.Contains(err.Error(), "users share an email address ignoring case") || !strings.Contains(err.Error(), want) {
+		t.Errorf("err = %v, want the colliding accounts listed as %q", err, want)
+	}
+	if strings.Contains(err.Error(), "carol") || strings.Contains(err.Error(), "dave") {
+		t.Errorf("err = %v, lists accounts that do not collide", err)
+	}
+
+	// The failed migration changed nothing
+	var email string
+	if err := pool.QueryRow(ctx, `SELECT email FROM users WHERE id = $1`, carol).Scan(&email); err != nil {
+		t.Fatal(err)
+	}
+	if email != "  Carol@Example.COM " {
+		t.Fatalf("failed migration changed an email to %q", email)
+	}
+
+	// Once the accounts are merged by hand it applies
+	if _, err := pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, second); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateTo(t, pool, 0); err != nil {
+		t.Fatalf("migrate after merging: %v", err)
+	}
+
+	users := NewUserRepository(pool)
+	for id, want := range map[uuid.UUID]string{first: "bob@example.com", carol: "carol@example.com"} {
+		user, err := users.GetByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Email != want {
+			t.Errorf("email = %q, want %q", user.Email, want)
+		}
+	}
+
+	err = users.Create(ctx, &model.User{Email: "BOB@example.com", Name: "Bob", Password: "hash", Role: model.RoleUser, Active: true})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("create differently cased duplicate: err = %v, want ErrDuplicate", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -22,10 +23,13 @@ var (
 
 // UserRepository defines the interface for user data access.
 type UserRepository interface {
+	// Create stores user and assigns its primary role.
 	Create(ctx context.Context, user *model.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
 	Update(ctx context.Context, user *model.User) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	List(ctx context.Context, params model.ListParams) ([]model.User, int64, error)
 }
@@ -35,13 +39,26 @@ type postgresUserRepo struct {
 	pool *pgxpool.Pool
 }
 
+// PasswordFunc returns the current password for a connection, so that
+// rotated credentials are picked up by new connections.
+type PasswordFunc func() string
+
 // NewPostgresPool creates a connection pool with production-ready settings.
-func NewPostgresPool(ctx context.Context, connStr string) (*pgxpool.Pool, error) {
+// If password is not nil it replaces the password in connStr for every new
+// connection; established connections are unaffected by a rotation.
+func NewPostgresPool(ctx context.Context, connStr string, password PasswordFunc) (*pgxpool.Pool, error) {
 	config, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
 		return nil, fmt.Errorf("parse connection string: %w", err)
 	}
 
+	if password != nil {
+		config.BeforeConnect = func(_ context.Context, cc *pgx.ConnConfig) error {
+			cc.Password = password()
+			return nil
+		}
+	}
+
 	// Production-ready pool settings
 	config.MaxConns = 25
 	config.MinConns = 5
@@ -72,23 +89,39 @@ func (r *postgresUserRepo) Create(ctx context.Context, user *model.User) error {
 	user.CreatedAt = time.Now().UTC()
 	user.UpdatedAt = user.CreatedAt
 
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin create user: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
 	query := `
-		INSERT INTO users (id, email, name, password_hash, role, active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO users (id, email, name, password_hash, role, active, email_verified_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 
-	_, err := r.pool.Exec(ctx, query,
+	_, err = tx.Exec(ctx, query,
 		user.ID, user.Email, user.Name, user.Password,
-		user.Role, user.Active, user.CreatedAt, user.UpdatedAt,
+		user.Role, user.Active, user.EmailVerifiedAt, user.CreatedAt, user.UpdatedAt,
 	)
 	if err != nil {
-		// Check for unique constraint violation
+		// Check for unique constraint violation, keeping the driver error
+		// and the constraint it names in the chain
 		if isDuplicateError(err) {
-			return ErrDuplicate
+			return fmt.Errorf("%w: %w", ErrDuplicate, err)
 		}
 		return fmt.Errorf("insert user: %w", err)
 	}
 
+	// Permissions come from assignments only, so the primary role is one
+	if err := assignRole(ctx, tx, user.ID, user.Role); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit create user: %w", err)
+	}
+
 	return nil
 }
 
@@ -113,16 +146,16 @@ func (r *postgresUserRepo) Update(ctx context.Context, user *model.User) error {
 
 	query := `
 		UPDATE users
-		SET email = $2, name = $3, role = $4, active = $5, updated_at = $6
+		SET email = $2, name = $3, role = $4, active = $5, email_verified_at = $6, updated_at = $7
 		WHERE id = $1
 	`
 
 	result, err := r.pool.Exec(ctx, query,
-		user.ID, user.Email, user.Name, user.Role, user.Active, user.UpdatedAt,
+		user.ID, user.Email, user.Name, user.Role, user.Active, user.EmailVerifiedAt, user.UpdatedAt,
 	)
 	if err != nil {
 		if isDuplicateError(err) {
-			return ErrDuplicate
+			return fmt.Errorf("%w: %w", ErrDuplicate, err)
 		}
 		return fmt.Errorf("update user: %w", err)
 	}
@@ -134,35 +167,76 @@ func (r *postgresUserRepo) Update(ctx context.Context, user *model.User) error {
 	return nil
 }
 
+func (r *postgresUserRepo) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $2, updated_at = $3 WHERE id = $1 AND active = true`
+
+	result, err := r.pool.Exec(ctx, query, id, passwordHash, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *postgresUserRepo) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, $2), updated_at = $2
+		WHERE id = $1 AND active = true
+	`
+
+	result, err := r.pool.Exec(ctx, query, id, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("mark email verified: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PostgreSQL error codes (SQLSTATE) mapped to repository errors.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
 // isDuplicateError checks if the error is a PostgreSQL unique violation (code 23505).
 func isDuplicateError(err error) bool {
-	return err != nil && contains(err.Error(), "23505")
+	return pgErrorCode(err) == pgUniqueViolation
 }
 
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && searchString(s, substr)
+// isForeignKeyError checks if the error is a PostgreSQL foreign key violation (code 23503).
+func isForeignKeyError(err error) bool {
+	return pgErrorCode(err) == pgForeignKeyViolation
 }
 
-func searchString(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+// pgErrorCode returns the SQLSTATE of the PostgreSQL error in err's chain,
+// or "" if there is none.
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
 	}
-	return false
+	return ""
 }
 
 func (r *postgresUserRepo) GetByEmail(ctx context.Context, email string) (*model.User, error) {
 	query := `
-		SELECT id, email, name, password_hash, role, active, created_at, updated_at
+		SELECT id, email, name, password_hash, role, active, email_verified_at, created_at, updated_at
 		FROM users
-		WHERE email = $1 AND active = true
+		WHERE lower(email) = lower($1) AND active = true
 	`
 
 	var user model.User
 	err := r.pool.QueryRow(ctx, query, email).Scan(
 		&user.ID, &user.Email, &user.Name, &user.Password,
-		&user.Role, &user.Active, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.Active, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -176,7 +250,7 @@ func (r *postgresUserRepo) GetByEmail(ctx context.Context, email string) (*model
 
 func (r *postgresUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
 	query := `
-		SELECT id, email, name, password_hash, role, active, created_at, updated_at
+		SELECT id, email, name, password_hash, role, active, email_verified_at, created_at, updated_at
 		FROM users
 		WHERE id = $1 AND active = true
 	`
@@ -184,7 +258,7 @@ func (r *postgresUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Us
 	var user model.User
 	err := r.pool.QueryRow(ctx, query, id).Scan(
 		&user.ID, &user.Email, &user.Name, &user.Password,
-		&user.Role, &user.Active, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.Active, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -214,7 +288,7 @@ func (r *postgresUserRepo) List(ctx context.Context, params model.ListParams) ([
 	}
 
 	// Fetch page
-	query := `SELECT id, email, name, password_hash, role, active, created_at, updated_at FROM users WHERE active = true`
+	query := `SELECT id, email, name, password_hash, role, active, email_verified_at, created_at, updated_at FROM users WHERE active = true`
 
 	fetchArgs := []interface{}{}
 	fetchIndex := 1
@@ -250,7 +324,7 @@ func (r *postgresUserRepo) List(ctx context.Context, params model.ListParams) ([
 	var users []model.User
 	for rows.Next() {
 		var u model.User
-		if err := rows.Scan(&u.ID, &u.Email, &u.Name, &u.Password, &u.Role, &u.Active, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.Email, &u.Name, &u.Password, &u.Role, &u.Active, &u.EmailVerifiedAt, &u.CreatedAt, &u.UpdatedAt); err != nil {
 			return nil, 0, fmt.Errorf("scan user: %w", err)
 		}
 		users = append(users, u)
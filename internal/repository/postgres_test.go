@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestPgErrorMapping(t *testing.T) {
+	unique := &pgconn.PgError{Code: "23505", ConstraintName: "users_email_lower_key"}
+	foreignKey := &pgconn.PgError{Code: "23503"}
+
+	tests := []struct {
+		name          string
+		err           error
+		wantDuplicate bool
+		wantFK        bool
+	}{
+		{"unique violation", unique, true, false},
+		{"wrapped unique violation", fmt.Errorf("insert user: %w", unique), true, false},
+		{"foreign key violation", foreignKey, false, true},
+		{"other SQLSTATE", &pgconn.PgError{Code: "40001"}, false, false},
+		{"not a PostgreSQL error", errors.New("duplicate key value violates unique constraint"), false, false},
+		{"nil", nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDuplicateError(tt.err); got != tt.wantDuplicate {
+				t.Errorf("isDuplicateError = %v, want %v", got, tt.wantDuplicate)
+			}
+			if got := isForeignKeyError(tt.err); got != tt.wantFK {
+				t.Errorf("isForeignKeyError = %v, want %v", got, tt.wantFK)
+			}
+		})
+	}
+}
+
+func TestCreateUserConcurrentDuplicate(t *testing.T) {
+	pool := migratedPool(t)
+	users := NewUserRepository(pool)
+
+	// Differently cased spellings of the same address collide on the index
+	emails := []string{"Racer@Example.com", "racer@example.COM"}
+	errs := make([]error, len(emails))
+	var wg sync.WaitGroup
+	for i, email := range emails {
+		wg.Add(1)
+		go func(i int, email string) {
+			defer wg.Done()
+			errs[i] = users.Create(context.Background(), &model.User{Email: email, Name: "Racer", Password: "hash", Role: model.RoleUser, Active: true})
+		}(i, email)
+	}
+	wg.Wait()
+
+	var created, duplicates int
+	for _, err := range errs {
+		var pgErr *pgconn.PgError
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, ErrDuplicate) && errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation:
+			duplicates++
+		default:
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if created != 1 || duplicates != 1 {
+		t.Errorf("errors = %v, want one success and one ErrDuplicate wrapping SQLSTATE 23505", errs)
+	}
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrTokenRevoked is returned when rotating a token that is no longer active.
+var ErrTokenRevoked = errors.New("token already revoked")
+
+// RefreshTokenRepository defines the interface for refresh token storage.
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *model.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*model.RefreshToken, error)
+	// Rotate atomically revokes oldID and stores next as its replacement.
+	// It returns ErrTokenRevoked if oldID was already revoked.
+	Rotate(ctx context.Context, oldID uuid.UUID, next *model.RefreshToken) error
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// postgresRefreshTokenRepo implements RefreshTokenRepository using PostgreSQL.
+type postgresRefreshTokenRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewRefreshTokenRepository creates a new PostgreSQL-backed refresh token repository.
+func NewRefreshTokenRepository(pool *pgxpool.Pool) RefreshTokenRepository {
+	return &postgresRefreshTokenRepo{pool: pool}
+}
+
+func (r *postgresRefreshTokenRepo) Create(ctx context.Context, token *model.RefreshToken) error {
+	return insertRefreshToken(ctx, r.pool, token)
+}
+
+func (r *postgresRefreshTokenRepo) GetByHash(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	var t model.RefreshToken
+	err := r.pool.QueryRow(ctx, query, hash).Scan(
+		&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash,
+		&t.ExpiresAt, &t.RevokedAt, &t.ReplacedBy, &t.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get refresh token: %w", err)
+	}
+
+	return &t, nil
+}
+
+func (r *postgresRefreshTokenRepo) Rotate(ctx context.Context, oldID uuid.UUID, next *model.RefreshToken) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin rotate: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	if err := insertRefreshToken(ctx, tx, next); err != nil {
+		return err
+	}
+
+	// The revoked_at guard makes concurrent rotations of the same token
+	// race on the row lock: only one of them can win.
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2, replaced_by = $3
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+	result, err := tx.Exec(ctx, query, oldID, time.Now().UTC(), next.ID)
+	if err != nil {
+		return fmt.Errorf("revoke rotated token: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTokenRevoked
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit rotate: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresRefreshTokenRepo) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL`
+
+	if _, err := r.pool.Exec(ctx, query, familyID, time.Now().UTC()); err != nil {
+		return fmt.Errorf("revoke token family: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresRefreshTokenRepo) RevokeAllForUser(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL`
+
+	if _, err := r.pool.Exec(ctx, query, userID, time.Now().UTC()); err != nil {
+		return fmt.Errorf("revoke user tokens: %w", err)
+	}
+
+	return nil
+}
+
+// execer is satisfied by both *pgxpool.Pool and pgx.Tx.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func insertRefreshToken(ctx context.Context, db execer, token *model.RefreshToken) error {
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now().UTC()
+
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := db.Exec(ctx, query,
+		token.ID, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt, token.CreatedAt,
+	)
+	if err != nil {
+		if isDuplicateError(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("insert refresh token: %w", err)
+	}
+
+	return nil
+}
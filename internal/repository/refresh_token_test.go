@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func TestRotateRefreshTokenOnlyOnce(t *testing.T) {
+	ctx := context.Background()
+	pool := migratedPool(t)
+	tokens := NewRefreshTokenRepository(pool)
+
+	user := &model.User{Email: "user@example.com", Name: "User", Password: "hash", Role: model.RoleUser, Active: true}
+	if err := NewUserRepository(pool).Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	family := uuid.New()
+	newToken := func(hash string) *model.RefreshToken {
+		return &model.RefreshToken{UserID: user.ID, FamilyID: family, TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)}
+	}
+
+	first := newToken("first")
+	if err := tokens.Create(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	second := newToken("second")
+	if err := tokens.Rotate(ctx, first.ID, second); err != nil {
+		t.Fatalf("first rotation: %v", err)
+	}
+	if err := tokens.Rotate(ctx, first.ID, newToken("third")); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("second rotation err = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := tokens.GetByHash(ctx, "third"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("the losing rotation's token was stored: err = %v", err)
+	}
+
+	rotated, err := tokens.GetByHash(ctx, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.RevokedAt == nil || rotated.ReplacedBy == nil || *rotated.ReplacedBy != second.ID {
+		t.Errorf("rotated token revoked at %v, replaced by %v, want replaced by %s", rotated.RevokedAt, rotated.ReplacedBy, second.ID)
+	}
+
+	if err := tokens.RevokeFamily(ctx, family); err != nil {
+		t.Fatal(err)
+	}
+	current, err := tokens.GetByHash(ctx, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.RevokedAt == nil {
+		t.Error("RevokeFamily left the current token valid")
+	}
+}
@@ -0,0 +1,388 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Go-Microservice-Template/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrPrimaryRole is returned when unassigning the default role from an
+// account whose primary role it is.
+var ErrPrimaryRole = errors.New("role is the account's primary role")
+
+// RoleRepository defines the interface for role and permission data access.
+//
+// A user's permissions are those of their assigned roles. The primary role in
+// users.role is assigned when the account is created and stays assigned:
+// unassigning it makes the default user role primary instead.
+type RoleRepository interface {
+	ListRoles(ctx context.Context) ([]model.RoleDefinition, error)
+	GetRole(ctx context.Context, name model.Role) (*model.RoleDefinition, error)
+	CreateRole(ctx context.Context, role *model.RoleDefinition) error
+	SetRolePermissions(ctx context.Context, name model.Role, perms []model.Permission) error
+	DeleteRole(ctx context.Context, name model.Role) error
+
+	ListPermissions(ctx context.Context) ([]model.PermissionDefinition, error)
+	CreatePermission(ctx context.Context, perm *model.PermissionDefinition) error
+
+	ListUserRoles(ctx context.Context, userID uuid.UUID) ([]model.Role, error)
+	AssignRole(ctx context.Context, userID uuid.UUID, name model.Role) error
+	// UnassignRole removes a role from userID. Removing the primary role
+	// demotes the account to the default user role; it returns
+	// ErrPrimaryRole if that is the role being removed.
+	UnassignRole(ctx context.Context, userID uuid.UUID, name model.Role) error
+	// UserPermissions resolves the permissions granted to a user through
+	// their assigned roles.
+	UserPermissions(ctx context.Context, userID uuid.UUID) ([]model.Permission, error)
+}
+
+// postgresRoleRepo implements RoleRepository using PostgreSQL.
+type postgresRoleRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewRoleRepository creates a new PostgreSQL-backed role repository.
+func NewRoleRepository(pool *pgxpool.Pool) RoleRepository {
+	return &postgresRoleRepo{pool: pool}
+}
+
+const selectRoles = `
+	SELECT r.id, r.name, r.description, r.created_at,
+	       COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+	FROM roles r
+	LEFT JOIN role_permissions rp ON rp.role_id = r.id
+	LEFT JOIN permissions p ON p.id = rp.permission_id
+`
+
+func scanRole(row pgx.Row) (*model.RoleDefinition, error) {
+	var (
+		role  model.RoleDefinition
+		perms []string
+	)
+	if err := row.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, &perms); err != nil {
+		return nil, err
+	}
+
+	role.Permissions = make([]model.Permission, len(perms))
+	for i, p := range perms {
+		role.Permissions[i] = model.Permission(p)
+	}
+
+	return &role, nil
+}
+
+func (r *postgresRoleRepo) ListRoles(ctx context.Context) ([]model.RoleDefinition, error) {
+	rows, err := r.pool.Query(ctx, selectRoles+` GROUP BY r.id ORDER BY r.name`)
+	if err != nil {
+		return nil, fmt.Errorf("list roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []model.RoleDefinition
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan role: %w", err)
+		}
+		roles = append(roles, *role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (r *postgresRoleRepo) GetRole(ctx context.Context, name model.Role) (*model.RoleDefinition, error) {
+	role, err := scanRole(r.pool.QueryRow(ctx, selectRoles+` WHERE r.name = $1 GROUP BY r.id`, name))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get role: %w", err)
+	}
+
+	return role, nil
+}
+
+func (r *postgresRoleRepo) CreateRole(ctx context.Context, role *model.RoleDefinition) error {
+	role.ID = uuid.New()
+	role.CreatedAt = time.Now().UTC()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin create role: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	query := `INSERT INTO roles (id, name, description, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(ctx, query, role.ID, role.Name, role.Description, role.CreatedAt); err != nil {
+		if isDuplicateError(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("insert role: %w", err)
+	}
+
+	if err := grantPermissions(ctx, tx, role.ID, role.Permissions); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit create role: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresRoleRepo) SetRolePermissions(ctx context.Context, name model.Role, perms []model.Permission) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin set role permissions: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	var roleID uuid.UUID
+	if err := tx.QueryRow(ctx, `SELECT id FROM roles WHERE name = $1 FOR UPDATE`, name).Scan(&roleID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("find role: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM role_permissions WHERE role_id = $1`, roleID); err != nil {
+		return fmt.Errorf("clear role permissions: %w", err)
+	}
+
+	if err := grantPermissions(ctx, tx, roleID, perms); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit set role permissions: %w", err)
+	}
+
+	return nil
+}
+
+// grantPermissions links perms to roleID. It returns ErrInvalidInput if any
+// permission does not exist.
+func grantPermissions(ctx context.Context, tx pgx.Tx, roleID uuid.UUID, perms []model.Permission) error {
+	if len(perms) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(perms))
+	seen := make(map[model.Permission]bool, len(perms))
+	for _, p := range perms {
+		if !seen[p] {
+			seen[p] = true
+			names = append(names, string(p))
+		}
+	}
+
+	query := `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, id FROM permissions WHERE name = ANY($2)
+	`
+	result, err := tx.Exec(ctx, query, roleID, names)
+	if err != nil {
+		return fmt.Errorf("grant permissions: %w", err)
+	}
+	if int(result.RowsAffected()) != len(names) {
+		return ErrInvalidInput
+	}
+
+	return nil
+}
+
+func (r *postgresRoleRepo) DeleteRole(ctx context.Context, name model.Role) error {
+	result, err := r.pool.Exec(ctx, `DELETE FROM roles WHERE name = $1`, name)
+	if err != nil {
+		// users.role still references the role
+		if isForeignKeyError(err) {
+			return ErrInvalidInput
+		}
+		return fmt.Errorf("delete role: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *postgresRoleRepo) ListPermissions(ctx context.Context) ([]model.PermissionDefinition, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, name, description, created_at FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list permissions: %w", err)
+	}
+	defer rows.Close()
+
+	var perms []model.PermissionDefinition
+	for rows.Next() {
+		var p model.PermissionDefinition
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan permission: %w", err)
+		}
+		perms = append(perms, p)
+	}
+
+	return perms, rows.Err()
+}
+
+func (r *postgresRoleRepo) CreatePermission(ctx context.Context, perm *model.PermissionDefinition) error {
+	perm.ID = uuid.New()
+	perm.CreatedAt = time.Now().UTC()
+
+	query := `INSERT INTO permissions (id, name, description, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err := r.pool.Exec(ctx, query, perm.ID, perm.Name, perm.Description, perm.CreatedAt); err != nil {
+		if isDuplicateError(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("insert permission: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresRoleRepo) ListUserRoles(ctx context.Context, userID uuid.UUID) ([]model.Role, error) {
+	query := `
+		SELECT r.name
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY r.name
+	`
+
+	rows, err := r.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list user roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []model.Role
+	for rows.Next() {
+		var role model.Role
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("scan user role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (r *postgresRoleRepo) AssignRole(ctx context.Context, userID uuid.UUID, name model.Role) error {
+	if err := assignRole(ctx, r.pool, userID, name); err != nil {
+		return err
+	}
+
+	// Distinguish "already assigned" from "no such role"
+	var exists bool
+	if err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`, name).Scan(&exists); err != nil {
+		return fmt.Errorf("check role: %w", err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *postgresRoleRepo) UnassignRole(ctx context.Context, userID uuid.UUID, name model.Role) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin unassign role: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	var primary model.Role
+	if err := tx.QueryRow(ctx, `SELECT role FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&primary); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("find user: %w", err)
+	}
+	if primary == name && name == model.RoleUser {
+		return ErrPrimaryRole
+	}
+
+	query := `
+		DELETE FROM user_roles
+		WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)
+	`
+
+	result, err := tx.Exec(ctx, query, userID, name)
+	if err != nil {
+		return fmt.Errorf("unassign role: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	if primary == name {
+		if _, err := tx.Exec(ctx, `UPDATE users SET role = $2, updated_at = $3 WHERE id = $1`, userID, model.RoleUser, time.Now().UTC()); err != nil {
+			return fmt.Errorf("reset primary role: %w", err)
+		}
+		if err := assignRole(ctx, tx, userID, model.RoleUser); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit unassign role: %w", err)
+	}
+
+	return nil
+}
+
+// assignRole links userID to the role name, if it exists and is not
+// assigned yet.
+func assignRole(ctx context.Context, db execer, userID uuid.UUID, name model.Role) error {
+	query := `
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE name = $2
+		ON CONFLICT DO NOTHING
+	`
+
+	if _, err := db.Exec(ctx, query, userID, name); err != nil {
+		return fmt.Errorf("assign role: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresRoleRepo) UserPermissions(ctx context.Context, userID uuid.UUID) ([]model.Permission, error) {
+	query := `
+		SELECT DISTINCT p.name
+		FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		JOIN roles r ON r.id = rp.role_id
+		JOIN user_roles ur ON ur.role_id = r.id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`
+
+	rows, err := r.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve user permissions: %w", err)
+	}
+	defer rows.Close()
+
+	var perms []model.Permission
+	for rows.Next() {
+		var p model.Permission
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("scan permission: %w", err)
+		}
+		perms = append(perms, p)
+	}
+
+	return perms, rows.Err()
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"Go-Microservice-Template/internal/model"
+)
+
+func TestUserPermissionsComeFromAssignedRoles(t *testing.T) {
+	ctx := context.Background()
+	pool := migratedPool(t)
+	users := NewUserRepository(pool)
+	roles := NewRoleRepository(pool)
+
+	if err := roles.CreateRole(ctx, &model.RoleDefinition{Name: "auditor", Permissions: []model.Permission{model.PermRolesRead}}); err != nil {
+		t.Fatal(err)
+	}
+	admin := &model.User{Email: "admin@example.com", Name: "Admin", Password: "hash", Role: model.RoleAdmin, Active: true}
+	if err := users.Create(ctx, admin); err != nil {
+		t.Fatal(err)
+	}
+
+	allAdmin := []model.Permission{
+		model.PermMeRead, model.PermRolesRead, model.PermRolesWrite, model.PermSessionsRevoke,
+		model.PermUsersDelete, model.PermUsersRead, model.PermUsersWrite,
+	}
+	steps := []struct {
+		name      string
+		change    func() error
+		wantErr   error
+		wantRole  model.Role
+		wantRoles []model.Role
+		wantPerms []model.Permission
+	}{
+		{"created with its primary role", func() error { return nil }, nil,
+			model.RoleAdmin, []model.Role{model.RoleAdmin}, allAdmin},
+		{"assign a role", func() error { return roles.AssignRole(ctx, admin.ID, "auditor") }, nil,
+			model.RoleAdmin, []model.Role{model.RoleAdmin, "auditor"}, allAdmin},
+		{"assign an unknown role", func() error { return roles.AssignRole(ctx, admin.ID, "nobody") }, ErrNotFound,
+			model.RoleAdmin, []model.Role{model.RoleAdmin, "auditor"}, allAdmin},
+		{"unassign the primary role", func() error { return roles.UnassignRole(ctx, admin.ID, model.RoleAdmin) }, nil,
+			model.RoleUser, []model.Role{"auditor", model.RoleUser}, []model.Permission{model.PermMeRead, model.PermRolesRead}},
+		{"unassign a secondary role", func() error { return roles.UnassignRole(ctx, admin.ID, "auditor") }, nil,
+			model.RoleUser, []model.Role{model.RoleUser}, []model.Permission{model.PermMeRead}},
+		{"unassign the default primary role", func() error { return roles.UnassignRole(ctx, admin.ID, model.RoleUser) }, ErrPrimaryRole,
+			model.RoleUser, []model.Role{model.RoleUser}, []model.Permission{model.PermMeRead}},
+		{"unassign a role not held", func() error { return roles.UnassignRole(ctx, admin.ID, model.RoleAdmin) }, ErrNotFound,
+			model.RoleUser, []model.Role{model.RoleUser}, []model.Permission{model.PermMeRead}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := step.change(); !errors.Is(err, step.wantErr) {
+				t.Fatalf("err = %v, want %v", err, step.wantErr)
+			}
+
+			user, err := users.GetByID(ctx, admin.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Role != step.wantRole {
+				t.Errorf("primary role = %s, want %s", user.Role, step.wantRole)
+			}
+
+			assigned, err := roles.ListUserRoles(ctx, admin.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(assigned, step.wantRoles) {
+				t.Errorf("roles = %v, want %v", assigned, step.wantRoles)
+			}
+
+			perms, err := roles.UserPermissions(ctx, admin.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+			if !reflect.DeepEqual(perms, step.wantPerms) {
+				t.Errorf("permissions = %v, want %v", perms, step.wantPerms)
+			}
+		})
+	}
+}
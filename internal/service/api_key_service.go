@@ -0,0 +1,199 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/repository"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrScopeNotGranted is returned when an API key asks for a permission its
+// owner does not hold.
+var ErrScopeNotGranted = newError("scope_not_granted", http.StatusForbidden, codes.PermissionDenied, "scope not granted to the key owner")
+
+// ErrInvalidExpiry is returned when a new key would already be expired.
+var ErrInvalidExpiry = newError("invalid_expiry", http.StatusBadRequest, codes.InvalidArgument, "expiry must be in the future")
+
+// apiKeyPrefix starts every API key, so that leaked keys are easy to spot by
+// secret scanners.
+const apiKeyPrefix = "gmt_"
+
+// APIKeyService manages API keys and authenticates requests that carry one.
+type APIKeyService interface {
+	Create(ctx context.Context, userID uuid.UUID, req model.CreateAPIKeyRequest) (*model.CreateAPIKeyResponse, error)
+	List(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error)
+	Revoke(ctx context.Context, userID, id uuid.UUID) error
+	// AuthenticateAPIKey resolves a raw key to the identity it acts as. It
+	// returns nil without an error when the key is unknown, revoked, expired
+	// or belongs to an inactive user.
+	AuthenticateAPIKey(ctx context.Context, rawKey string) (*model.APIKeyPrincipal, error)
+}
+
+type apiKeyService struct {
+	keys  repository.APIKeyRepository
+	users repository.UserRepository
+	roles repository.RoleRepository
+}
+
+// NewAPIKeyService creates a new API key service.
+func NewAPIKeyService(keys repository.APIKeyRepository, users repository.UserRepository, roles repository.RoleRepository) APIKeyService {
+	return &apiKeyService{keys: keys, users: users, roles: roles}
+}
+
+// Create issues a key for userID. Scopes must be a subset of the owner's
+// current permissions; the raw key is returned only from this call.
+func (s *apiKeyService) Create(ctx context.Context, userID uuid.UUID, req model.CreateAPIKeyRequest) (*model.CreateAPIKeyResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 || len(req.Scopes) == 0 {
+		return nil, repository.ErrInvalidInput
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidExpiry
+	}
+
+	scopes, err := toPermissions(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	owned, err := s.roles.UserPermissions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve permissions: %w", err)
+	}
+	for _, scope := range scopes {
+		if !containsPermission(owned, scope) {
+			return nil, ErrScopeNotGranted.WithDetail("scope", scope)
+		}
+	}
+
+	prefix, rawKey, err := generateAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("generate api key: %w", err)
+	}
+
+	key := model.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(rawKey),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.keys.Create(ctx, &key); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("user_id", userID.String()).
+		Str("key_prefix", prefix).
+		Msg("api key created")
+
+	return &model.CreateAPIKeyResponse{APIKey: key, Key: rawKey}, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error) {
+	return s.keys.ListForUser(ctx, userID)
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	if err := s.keys.Revoke(ctx, userID, id); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("user_id", userID.String()).
+		Str("key_id", id.String()).
+		Msg("api key revoked")
+
+	return nil
+}
+
+// AuthenticateAPIKey grants the key's scopes that the owner still holds, so
+// removing a permission from a user also removes it from their keys.
+func (s *apiKeyService) AuthenticateAPIKey(ctx context.Context, rawKey string) (*model.APIKeyPrincipal, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, nil
+	}
+
+	key, err := s.keys.GetByHash(ctx, hashToken(rawKey))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !key.Usable(time.Now()) {
+		return nil, nil
+	}
+
+	user, err := s.users.GetByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("find key owner: %w", err)
+	}
+	if !user.Active {
+		return nil, nil
+	}
+
+	owned, err := s.roles.UserPermissions(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve permissions: %w", err)
+	}
+
+	perms := make([]model.Permission, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		if containsPermission(owned, scope) {
+			perms = append(perms, scope)
+		}
+	}
+
+	if err := s.keys.TouchLastUsed(ctx, key.ID); err != nil {
+		log.Warn().Err(err).Str("key_prefix", key.Prefix).Msg("failed to record api key use")
+	}
+
+	return &model.APIKeyPrincipal{
+		KeyID:       key.ID,
+		UserID:      user.ID,
+		Role:        user.Role,
+		Permissions: perms,
+	}, nil
+}
+
+// generateAPIKey returns a key of the form "gmt_<prefix>_<secret>" and its
+// displayable prefix.
+func generateAPIKey() (string, string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix := apiKeyPrefix + hex.EncodeToString(b)
+
+	secret, err := generateToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	return prefix, prefix + "_" + secret, nil
+}
+
+func containsPermission(perms []model.Permission, p model.Permission) bool {
+	for _, granted := range perms {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/repository"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCreateAPIKeyWithinOwnerPermissions(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	keys := NewAPIKeyService(svc.apiKeys, svc.users, svc.roles)
+
+	// Accounts as registration and create-admin make them, holding the
+	// permissions the migrations seed for their roles
+	user, err := svc.Register(ctx, model.CreateUserRequest{Email: "user@example.com", Name: "User", Password: "correct-horse-battery"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := svc.CreateAdmin(ctx, model.CreateUserRequest{Email: "admin@example.com", Name: "Admin", Password: "correct-horse-battery"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		owner   *model.User
+		scopes  []string
+		wantErr error
+	}{
+		{"user scoped to own account", user, []string{"me:read"}, nil},
+		{"user asking for an admin permission", user, []string{"users:read"}, ErrScopeNotGranted},
+		{"user without scopes", user, nil, repository.ErrInvalidInput},
+		{"admin scoped to own account", admin, []string{"me:read"}, nil},
+		{"admin asking for an admin permission", admin, []string{"me:read", "users:read"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, err := keys.Create(ctx, tt.owner.ID, model.CreateAPIKeyRequest{Name: "ci", Scopes: tt.scopes})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			principal, err := keys.AuthenticateAPIKey(ctx, created.Key)
+			if err != nil || principal == nil {
+				t.Fatalf("AuthenticateAPIKey = %v, %v", principal, err)
+			}
+			want := make([]model.Permission, len(tt.scopes))
+			for i, s := range tt.scopes {
+				want[i] = model.Permission(s)
+			}
+			if principal.UserID != tt.owner.ID || !reflect.DeepEqual(principal.Permissions, want) {
+				t.Errorf("key acts as %s with %v, want %s with %v", principal.UserID, principal.Permissions, tt.owner.ID, want)
+			}
+		})
+	}
+}
+
+func TestRevokingSessionsRevokesAPIKeys(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		revoke func(t *testing.T, svc *testService, user *model.User) error
+	}{
+		{"sessions revoked", func(t *testing.T, svc *testService, user *model.User) error {
+			return svc.RevokeSessions(ctx, user.ID)
+		}},
+		{"password reset", func(t *testing.T, svc *testService, user *model.User) error {
+			return svc.ResetPassword(ctx, requestReset(t, svc), newPassword)
+		}},
+		{"password changed", func(t *testing.T, svc *testService, user *model.User) error {
+			_, err := svc.ChangePassword(ctx, user.ID, model.ChangePasswordRequest{CurrentPassword: "correct-horse-battery", NewPassword: newPassword})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t)
+			keys := NewAPIKeyService(svc.apiKeys, svc.users, svc.roles)
+			user, err := svc.Register(ctx, model.CreateUserRequest{Email: "reset@example.com", Name: "Reset", Password: "correct-horse-battery"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// As a stolen session could have
+			created, err := keys.Create(ctx, user.ID, model.CreateAPIKeyRequest{Name: "persistence", Scopes: []string{"me:read"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.revoke(t, svc, user); err != nil {
+				t.Fatal(err)
+			}
+
+			principal, err := keys.AuthenticateAPIKey(ctx, created.Key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal != nil {
+				t.Error("the API key still authenticates")
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrInvalidEmail is returned for addresses that cannot be normalized, such
+// as ones whose domain is not a valid internationalized domain name.
+var ErrInvalidEmail = newError("invalid_email", http.StatusBadRequest, codes.InvalidArgument, "invalid email address")
+
+// ErrEmailTooLong is returned for addresses that pass the length check of
+// the request but exceed it once their domain is converted to punycode.
+var ErrEmailTooLong = newError("email_too_long", http.StatusBadRequest, codes.InvalidArgument, "email address is too long")
+
+// maxEmailLength is the size of the users.email column.
+const maxEmailLength = 255
+
+// NormalizeEmail returns the canonical form of an email address, under which
+// it is stored and looked up: surrounding space trimmed, the local part
+// lower-cased and the domain converted to lower-case ASCII (punycode), so
+// that "Bob@Bücher.example" and "bob@xn--bcher-kva.example" are the same
+// account. The result is at most maxEmailLength bytes long.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 || at == len(email)-1 {
+		return "", ErrInvalidEmail
+	}
+
+	domain, err := idna.Lookup.ToASCII(email[at+1:])
+	if err != nil {
+		return "", ErrInvalidEmail
+	}
+
+	email = strings.ToLower(email[:at]) + "@" + strings.ToLower(domain)
+	if len(email) > maxEmailLength {
+		return "", ErrEmailTooLong
+	}
+	return email, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// longIDNEmail is 144 characters long, but 276 once its domain is converted
+// to punycode.
+var longIDNEmail = strings.Repeat("a", 64) + "@" + strings.Repeat("日本語のドメイン名例です一二三四五.", 4) + "example"
+
+// longestEmail is exactly as long as the users.email column allows.
+var longestEmail = strings.Repeat("a", 64) + "@" + strings.Repeat(strings.Repeat("b", 62)+".", 3) + "c"
+
+func TestNormalizeEmail(t *testing.T) {
+	if len(longestEmail) != maxEmailLength {
+		t.Fatalf("longestEmail is %d bytes long, want %d", len(longestEmail), maxEmailLength)
+	}
+
+	tests := []struct {
+		name    string
+		email   string
+		want    string
+		wantErr error
+	}{
+		{"already normalized", "bob@example.com", "bob@example.com", nil},
+		{"surrounding space", " \tbob@example.com\n", "bob@example.com", nil},
+		{"mixed case", "Bob.Smith@Example.COM", "bob.smith@example.com", nil},
+		{"internationalized domain", "Bob@Bücher.example", "bob@xn--bcher-kva.example", nil},
+		{"upper-case internationalized domain", "bob@BÜCHER.example", "bob@xn--bcher-kva.example", nil},
+		{"punycode domain", "bob@XN--BCHER-KVA.example", "bob@xn--bcher-kva.example", nil},
+		{"last @ separates the domain", `"a@b"@example.com`, `"a@b"@example.com`, nil},
+		{"no @", "bob.example.com", "", ErrInvalidEmail},
+		{"no local part", "@example.com", "", ErrInvalidEmail},
+		{"no domain", "bob@", "", ErrInvalidEmail},
+		{"invalid domain", "bob@exa mple.com", "", ErrInvalidEmail},
+		{"longest storable", longestEmail, longestEmail, nil},
+		{"too long once converted to punycode", longIDNEmail, "", ErrEmailTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeEmail(tt.email)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeEmail(%q) = %q, want %q", tt.email, got, tt.want)
+			}
+		})
+	}
+}
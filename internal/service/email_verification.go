@@ -0,0 +1,104 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/notify"
+	"Go-Microservice-Template/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrInvalidVerificationToken is returned for unknown, expired or already
+// used email verification tokens.
+var ErrInvalidVerificationToken = newError("invalid_verification_token", http.StatusBadRequest, codes.InvalidArgument, "invalid or expired verification token")
+
+// VerifyEmail redeems a verification token and marks the email as verified.
+func (s *userService) VerifyEmail(ctx context.Context, rawToken string) error {
+	token, err := s.actionTokens.Consume(ctx, model.PurposeEmailVerification, hashToken(rawToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		return fmt.Errorf("consume verification token: %w", err)
+	}
+
+	if err := s.repo.MarkEmailVerified(ctx, token.UserID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		return err
+	}
+
+	if err := s.cache.Delete(ctx, token.UserID); err != nil {
+		log.Warn().Err(err).Msg("failed to invalidate cache")
+	}
+
+	log.Info().Str("user_id", token.UserID.String()).Msg("email verified")
+
+	return nil
+}
+
+// ResendVerification sends a fresh verification token to an unverified
+// account. Like ForgotPassword it succeeds whether or not the account exists.
+func (s *userService) ResendVerification(ctx context.Context, email string) error {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("find user: %w", err)
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	if err := s.sendVerification(ctx, user); err != nil {
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("failed to send email verification")
+	}
+
+	return nil
+}
+
+// sendVerification issues a verification token for the user's current email
+// and sends it through the notifier. Issuing a token invalidates older ones.
+func (s *userService) sendVerification(ctx context.Context, user *model.User) error {
+	rawToken, err := generateToken()
+	if err != nil {
+		return fmt.Errorf("generate verification token: %w", err)
+	}
+
+	token := &model.ActionToken{
+		UserID:    user.ID,
+		Purpose:   model.PurposeEmailVerification,
+		TokenHash: hashToken(rawToken),
+		ExpiresAt: time.Now().Add(s.opts.EmailVerificationTTL).UTC(),
+	}
+	if err := s.actionTokens.Create(ctx, token); err != nil {
+		return fmt.Errorf("store verification token: %w", err)
+	}
+
+	link := s.opts.VerifyURL + "?token=" + url.QueryEscape(rawToken)
+	msg := notify.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Confirm that this is your email address by opening:\n%s\n\nThe link expires at %s.",
+			link, token.ExpiresAt.Format(time.RFC1123),
+		),
+	}
+
+	return s.notifier.Send(ctx, msg)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/model"
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var verifyTokenPattern = regexp.MustCompile(`\?token=(\S+)`)
+
+func TestVerifyEmailTokensAreSingleUse(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// token returns the token to redeem for the registered user
+		token        func(t *testing.T, svc *testService) string
+		wantErr      error
+		wantVerified bool
+	}{
+		{
+			name:         "first use",
+			token:        sentVerifyToken,
+			wantVerified: true,
+		},
+		{
+			name: "second use",
+			token: func(t *testing.T, svc *testService) string {
+				token := sentVerifyToken(t, svc)
+				if err := svc.VerifyEmail(ctx, token); err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			wantErr:      ErrInvalidVerificationToken,
+			wantVerified: true,
+		},
+		{
+			name: "superseded by a resent token",
+			token: func(t *testing.T, svc *testService) string {
+				older := sentVerifyToken(t, svc)
+				if err := svc.ResendVerification(ctx, "verify@example.com"); err != nil {
+					t.Fatal(err)
+				}
+				return older
+			},
+			wantErr: ErrInvalidVerificationToken,
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T, svc *testService) string {
+				svc.opts.EmailVerificationTTL = -time.Minute
+				if err := svc.ResendVerification(ctx, "verify@example.com"); err != nil {
+					t.Fatal(err)
+				}
+				return sentVerifyToken(t, svc)
+			},
+			wantErr: ErrInvalidVerificationToken,
+		},
+		{
+			name: "password reset token",
+			token: func(t *testing.T, svc *testService) string {
+				if err := svc.ForgotPassword(ctx, "verify@example.com"); err != nil {
+					t.Fatal(err)
+				}
+				return svc.notifier.token(t, resetTokenPattern)
+			},
+			wantErr: ErrInvalidVerificationToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t)
+			user, err := svc.Register(ctx, model.CreateUserRequest{
+				Email:    "verify@example.com",
+				Name:     "Verify",
+				Password: "correct-horse-battery",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := svc.VerifyEmail(ctx, tt.token(t, svc)); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, _ := svc.users.GetByID(ctx, user.ID)
+			if verified := stored.EmailVerifiedAt != nil; verified != tt.wantVerified {
+				t.Errorf("verified = %t, want %t", verified, tt.wantVerified)
+			}
+		})
+	}
+}
+
+// sentVerifyToken returns the token of the last verification link sent.
+func sentVerifyToken(t *testing.T, svc *testService) string {
+	t.Helper()
+	token, err := url.QueryUnescape(svc.notifier.token(t, verifyTokenPattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/repository"
+	"Go-Microservice-Template/internal/validation"
+	"errors"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Error is an application error with a stable, machine-readable code. It
+// carries everything a transport needs to report it: the HTTP status, the
+// gRPC code and optional details. Clients should branch on Code; Message is
+// meant for people and may change.
+//
+// Errors compare equal under errors.Is when their codes match, so a copy
+// with details still matches the sentinel it was made from.
+type Error struct {
+	Code       string
+	Message    string
+	HTTPStatus int
+	GRPCCode   codes.Code
+	// Fields lists invalid request fields, for validation errors.
+	Fields []validation.FieldError
+	// Details holds extra members, such as policy violations.
+	Details map[string]interface{}
+	// RetryAfter, if positive, tells the client when to try again.
+	RetryAfter time.Duration
+}
+
+func newError(code string, httpStatus int, grpcCode codes.Code, message string) *Error {
+	return &Error{Code: code, Message: message, HTTPStatus: httpStatus, GRPCCode: grpcCode}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is an *Error with the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDetail returns a copy of e with key set to value in its details.
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	c := *e
+	c.Details = make(map[string]interface{}, len(e.Details)+1)
+	for k, v := range e.Details {
+		c.Details[k] = v
+	}
+	c.Details[key] = value
+	return &c
+}
+
+// BadRequest returns a bad_request error for malformed input that the
+// request DTO cannot describe, such as an unparsable path parameter.
+func BadRequest(message string) *Error {
+	return newError("bad_request", http.StatusBadRequest, codes.InvalidArgument, message)
+}
+
+// Generic errors, used for failures that no more specific error describes.
+var (
+	ErrInternal        = newError("internal", http.StatusInternalServerError, codes.Internal, "internal server error")
+	ErrNotFound        = newError("not_found", http.StatusNotFound, codes.NotFound, "resource not found")
+	ErrConflict        = newError("already_exists", http.StatusConflict, codes.AlreadyExists, "resource already exists")
+	ErrInvalidInput    = newError("invalid_input", http.StatusBadRequest, codes.InvalidArgument, "invalid input")
+	ErrValidation      = newError("validation_failed", http.StatusBadRequest, codes.InvalidArgument, "request validation failed")
+	ErrUnauthenticated = newError("unauthenticated", http.StatusUnauthorized, codes.Unauthenticated, "authentication required")
+	ErrForbidden       = newError("forbidden", http.StatusForbidden, codes.PermissionDenied, "forbidden")
+)
+
+// AsError converts err to the *Error that should be reported to the client.
+// Repository sentinels map to the generic errors above and anything unknown
+// becomes ErrInternal, so internal details never reach clients. Callers
+// should log err itself when the result is ErrInternal.
+func AsError(err error) *Error {
+	// Typed errors first: they unwrap to sentinels but carry details
+	var locked *LockedError
+	if errors.As(err, &locked) {
+		e := *ErrAccountLocked
+		e.RetryAfter = time.Until(locked.Until)
+		return &e
+	}
+
+	var weak *PasswordPolicyError
+	if errors.As(err, &weak) {
+		return ErrWeakPassword.WithDetail("violations", weak.Violations)
+	}
+
+	var invalid *validation.Error
+	if errors.As(err, &invalid) {
+		e := *ErrValidation
+		e.Fields = invalid.Fields
+		return &e
+	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrNotFound
+	case errors.Is(err, repository.ErrDuplicate):
+		return ErrConflict
+	case errors.Is(err, repository.ErrInvalidInput):
+		return ErrInvalidInput
+	}
+
+	return ErrInternal
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/repository"
+	"Go-Microservice-Template/internal/validation"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAsError(t *testing.T) {
+	fields := []validation.FieldError{{Field: "email", Rule: "required", Message: "is required"}}
+
+	tests := []struct {
+		name        string
+		err         error
+		want        *Error
+		wantFields  []validation.FieldError
+		wantDetails map[string]interface{}
+		wantRetry   bool
+	}{
+		{"application error", ErrInvalidCredentials, ErrInvalidCredentials, nil, nil, false},
+		{"wrapped application error", fmt.Errorf("login: %w", ErrEmailTaken), ErrEmailTaken, nil, nil, false},
+		{"application error with details", ErrWeakPassword.WithDetail("hint", "longer"), ErrWeakPassword, nil, map[string]interface{}{"hint": "longer"}, false},
+		{"lockout", &LockedError{Until: time.Now().Add(time.Minute)}, ErrAccountLocked, nil, nil, true},
+		{"password policy", &PasswordPolicyError{Violations: []string{"too short", "too common"}}, ErrWeakPassword, nil, map[string]interface{}{"violations": []string{"too short", "too common"}}, false},
+		{"validation", fmt.Errorf("decode: %w", &validation.Error{Fields: fields}), ErrValidation, fields, nil, false},
+		{"not found", fmt.Errorf("get: %w", repository.ErrNotFound), ErrNotFound, nil, nil, false},
+		{"duplicate", fmt.Errorf("%w: unique violation", repository.ErrDuplicate), ErrConflict, nil, nil, false},
+		{"invalid input", repository.ErrInvalidInput, ErrInvalidInput, nil, nil, false},
+		{"unknown", errors.New("dial tcp 10.0.0.5:5432: connection refused"), ErrInternal, nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AsError(tt.err)
+
+			if got.Code != tt.want.Code || got.Message != tt.want.Message ||
+				got.HTTPStatus != tt.want.HTTPStatus || got.GRPCCode != tt.want.GRPCCode {
+				t.Errorf("AsError = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(got.Fields, tt.wantFields) {
+				t.Errorf("fields = %+v, want %+v", got.Fields, tt.wantFields)
+			}
+			if !reflect.DeepEqual(got.Details, tt.wantDetails) {
+				t.Errorf("details = %v, want %v", got.Details, tt.wantDetails)
+			}
+			if retry := got.RetryAfter > 0; retry != tt.wantRetry {
+				t.Errorf("RetryAfter = %s, want one: %v", got.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestAsErrorLeavesSentinelsUntouched(t *testing.T) {
+	AsError(&LockedError{Until: time.Now().Add(time.Minute)})
+	AsError(&PasswordPolicyError{Violations: []string{"too short"}})
+	AsError(&validation.Error{Fields: []validation.FieldError{{Field: "email"}}})
+
+	if ErrAccountLocked.RetryAfter != 0 || ErrWeakPassword.Details != nil || ErrValidation.Fields != nil {
+		t.Errorf("sentinels modified: %+v, %+v, %+v", ErrAccountLocked, ErrWeakPassword, ErrValidation)
+	}
+}
+
+func TestErrorIsMatchesByCode(t *testing.T) {
+	withDetail := ErrWeakPassword.WithDetail("violations", []string{"too short"})
+
+	if !errors.Is(withDetail, ErrWeakPassword) {
+		t.Error("copy with details does not match its sentinel")
+	}
+	if errors.Is(withDetail, ErrValidation) {
+		t.Error("error matches a sentinel with another code")
+	}
+	if !errors.Is(fmt.Errorf("wrapped: %w", BadRequest("bad id")), BadRequest("other message")) {
+		t.Error("bad_request errors with different messages do not match")
+	}
+}
@@ -0,0 +1,746 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/auth"
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/notify"
+	"Go-Microservice-Template/internal/repository"
+	"context"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ── In-memory repositories ────────────────────────────────
+//
+// The fakes embed their interface so that methods a test does not expect to
+// be called panic instead of silently succeeding.
+
+// fakeUsers enforces a unique email like the database index, reporting
+// ErrDuplicate on a conflicting Create.
+type fakeUsers struct {
+	repository.UserRepository
+
+	mu    sync.Mutex
+	users map[uuid.UUID]*model.User
+	// beforeCreate, if set, runs before every Create outside the lock.
+	beforeCreate func(user *model.User)
+	// roles, if set, is assigned the primary role of every new user.
+	roles *fakeRoles
+}
+
+func newFakeUsers() *fakeUsers {
+	return &fakeUsers{users: make(map[uuid.UUID]*model.User)}
+}
+
+func (f *fakeUsers) Create(_ context.Context, user *model.User) error {
+	if f.beforeCreate != nil {
+		f.beforeCreate(user)
+	}
+
+	f.mu.Lock()
+	for _, u := range f.users {
+		if u.Email == user.Email {
+			f.mu.Unlock()
+			return repository.ErrDuplicate
+		}
+	}
+	user.ID = uuid.New()
+	user.CreatedAt = time.Now().UTC()
+	user.UpdatedAt = user.CreatedAt
+	stored := *user
+	f.users[user.ID] = &stored
+	f.mu.Unlock()
+
+	if f.roles != nil {
+		f.roles.assign(user.ID, user.Role)
+	}
+	return nil
+}
+
+func (f *fakeUsers) GetByID(_ context.Context, id uuid.UUID) (*model.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	found := *u
+	return &found, nil
+}
+
+func (f *fakeUsers) GetByEmail(_ context.Context, email string) (*model.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, u := range f.users {
+		if u.Email == email {
+			found := *u
+			return &found, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (f *fakeUsers) UpdatePassword(_ context.Context, id uuid.UUID, passwordHash string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	u.Password = passwordHash
+	return nil
+}
+
+func (f *fakeUsers) setRole(id uuid.UUID, role model.Role) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users[id].Role = role
+}
+
+func (f *fakeUsers) MarkEmailVerified(_ context.Context, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	now := time.Now().UTC()
+	u.EmailVerifiedAt = &now
+	return nil
+}
+
+// add stores user directly, returning it with its new ID.
+func (f *fakeUsers) add(t *testing.T, user model.User) *model.User {
+	t.Helper()
+	if err := f.Create(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+func (f *fakeUsers) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.users)
+}
+
+type fakeIdentities struct {
+	repository.IdentityRepository
+
+	mu         sync.Mutex
+	identities map[string]model.LinkedIdentity
+}
+
+func newFakeIdentities() *fakeIdentities {
+	return &fakeIdentities{identities: make(map[string]model.LinkedIdentity)}
+}
+
+func (f *fakeIdentities) Get(_ context.Context, provider, subject string) (*model.LinkedIdentity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	identity, ok := f.identities[provider+"|"+subject]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &identity, nil
+}
+
+func (f *fakeIdentities) Create(_ context.Context, identity *model.LinkedIdentity) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := identity.Provider + "|" + identity.Subject
+	if _, ok := f.identities[key]; ok {
+		return repository.ErrDuplicate
+	}
+	identity.ID = uuid.New()
+	f.identities[key] = *identity
+	return nil
+}
+
+// fakeRefreshTokens stores refresh tokens like the refresh_tokens queries,
+// including Rotate's guard against rotating a token twice.
+type fakeRefreshTokens struct {
+	repository.RefreshTokenRepository
+
+	mu     sync.Mutex
+	tokens map[uuid.UUID]*model.RefreshToken
+	// beforeRotate, if set, runs before every Rotate outside the lock.
+	beforeRotate func(oldID uuid.UUID)
+}
+
+func newFakeRefreshTokens() *fakeRefreshTokens {
+	return &fakeRefreshTokens{tokens: make(map[uuid.UUID]*model.RefreshToken)}
+}
+
+func (f *fakeRefreshTokens) Create(_ context.Context, token *model.RefreshToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.insert(token)
+	return nil
+}
+
+func (f *fakeRefreshTokens) insert(token *model.RefreshToken) {
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now().UTC()
+	stored := *token
+	f.tokens[token.ID] = &stored
+}
+
+func (f *fakeRefreshTokens) GetByHash(_ context.Context, hash string) (*model.RefreshToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, t := range f.tokens {
+		if t.TokenHash == hash {
+			found := *t
+			return &found, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (f *fakeRefreshTokens) Rotate(_ context.Context, oldID uuid.UUID, next *model.RefreshToken) error {
+	if f.beforeRotate != nil {
+		f.beforeRotate(oldID)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old, ok := f.tokens[oldID]
+	if !ok || old.RevokedAt != nil {
+		return repository.ErrTokenRevoked
+	}
+	f.insert(next)
+	now := time.Now().UTC()
+	old.RevokedAt = &now
+	old.ReplacedBy = &next.ID
+	return nil
+}
+
+func (f *fakeRefreshTokens) RevokeFamily(_ context.Context, familyID uuid.UUID) error {
+	f.revokeWhere(func(t *model.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (f *fakeRefreshTokens) RevokeAllForUser(_ context.Context, userID uuid.UUID) error {
+	f.revokeWhere(func(t *model.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+func (f *fakeRefreshTokens) revokeWhere(match func(*model.RefreshToken) bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now().UTC()
+	for _, t := range f.tokens {
+		if match(t) && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+}
+
+// family returns the tokens of a family.
+func (f *fakeRefreshTokens) family(familyID uuid.UUID) []model.RefreshToken {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var tokens []model.RefreshToken
+	for _, t := range f.tokens {
+		if t.FamilyID == familyID {
+			tokens = append(tokens, *t)
+		}
+	}
+	return tokens
+}
+
+// expire moves the expiry of the refresh token raw into the past.
+func (f *fakeRefreshTokens) expire(raw string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, t := range f.tokens {
+		if t.TokenHash == hashToken(raw) {
+			t.ExpiresAt = time.Now().Add(-time.Second)
+		}
+	}
+}
+
+// fakeDenylist records revocations like the database does, with the
+// microsecond precision of its timestamps.
+type fakeDenylist struct {
+	repository.TokenDenylist
+
+	mu     sync.Mutex
+	jtis   map[string]time.Time
+	before map[uuid.UUID]time.Time
+}
+
+func newFakeDenylist() *fakeDenylist {
+	return &fakeDenylist{jtis: make(map[string]time.Time), before: make(map[uuid.UUID]time.Time)}
+}
+
+func (f *fakeDenylist) RevokeToken(_ context.Context, jti string, expiresAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jtis[jti] = expiresAt
+	return nil
+}
+
+func (f *fakeDenylist) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	expiresAt, ok := f.jtis[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func (f *fakeDenylist) RevokeUserTokens(_ context.Context, userID uuid.UUID, before time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.before[userID] = before.Truncate(time.Microsecond)
+	return nil
+}
+
+func (f *fakeDenylist) UserTokensRevokedBefore(_ context.Context, userID uuid.UUID) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.before[userID], nil
+}
+
+// fakeActionTokens redeems tokens like the action_tokens queries: a token
+// is usable until it expires, is consumed or is superseded by a newer token
+// for the same user and purpose.
+type fakeActionTokens struct {
+	repository.ActionTokenRepository
+
+	mu     sync.Mutex
+	tokens map[string]*model.ActionToken // by hash
+}
+
+func newFakeActionTokens() *fakeActionTokens {
+	return &fakeActionTokens{tokens: make(map[string]*model.ActionToken)}
+}
+
+func (f *fakeActionTokens) Create(_ context.Context, token *model.ActionToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.tokens[token.TokenHash]; ok {
+		return repository.ErrDuplicate
+	}
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now().UTC()
+	for _, t := range f.tokens {
+		if t.UserID == token.UserID && t.Purpose == token.Purpose && t.UsedAt == nil {
+			t.UsedAt = &token.CreatedAt
+		}
+	}
+	stored := *token
+	f.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+// redeemable returns the usable token with hash. Callers must hold mu.
+func (f *fakeActionTokens) redeemable(purpose model.ActionTokenPurpose, hash string) (*model.ActionToken, error) {
+	t, ok := f.tokens[hash]
+	if !ok || t.Purpose != purpose || t.UsedAt != nil || !t.ExpiresAt.After(time.Now()) {
+		return nil, repository.ErrNotFound
+	}
+	return t, nil
+}
+
+func (f *fakeActionTokens) Get(_ context.Context, purpose model.ActionTokenPurpose, hash string) (*model.ActionToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.redeemable(purpose, hash)
+	if err != nil {
+		return nil, err
+	}
+	found := *t
+	return &found, nil
+}
+
+func (f *fakeActionTokens) Consume(_ context.Context, purpose model.ActionTokenPurpose, hash string) (*model.ActionToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.redeemable(purpose, hash)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	t.UsedAt = &now
+	used := *t
+	return &used, nil
+}
+
+// fakeNotifier records every message sent.
+type fakeNotifier struct {
+	mu       sync.Mutex
+	messages []notify.Message
+}
+
+func (f *fakeNotifier) Send(_ context.Context, msg notify.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, msg)
+	return nil
+}
+
+// token returns the token sent in the last message, found by pattern, which
+// must capture it in its first group.
+func (f *fakeNotifier) token(t *testing.T, pattern *regexp.Regexp) string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.messages) == 0 {
+		t.Fatal("no message sent")
+	}
+	m := pattern.FindStringSubmatch(f.messages[len(f.messages)-1].Body)
+	if m == nil {
+		t.Fatalf("no token in message %q", f.messages[len(f.messages)-1].Body)
+	}
+	return m[1]
+}
+
+// fakeRoles resolves permissions from role assignments like the role
+// queries, starting from the built-in roles the migrations seed.
+type fakeRoles struct {
+	repository.RoleRepository
+
+	users *fakeUsers
+
+	mu          sync.Mutex
+	permissions map[model.Role][]model.Permission
+	assigned    map[uuid.UUID]map[model.Role]bool
+}
+
+// newFakeRoles returns roles for users, whose new accounts are assigned
+// their primary role.
+func newFakeRoles(users *fakeUsers) *fakeRoles {
+	f := &fakeRoles{
+		users: users,
+		permissions: map[model.Role][]model.Permission{
+			model.RoleUser: {model.PermMeRead},
+			model.RoleAdmin: {
+				model.PermMeRead, model.PermUsersRead, model.PermUsersWrite, model.PermUsersDelete,
+				model.PermSessionsRevoke, model.PermRolesRead, model.PermRolesWrite,
+			},
+		},
+		assigned: make(map[uuid.UUID]map[model.Role]bool),
+	}
+	users.roles = f
+	return f
+}
+
+// define creates role granting perms.
+func (f *fakeRoles) define(role model.Role, perms ...model.Permission) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.permissions[role] = perms
+}
+
+func (f *fakeRoles) assign(userID uuid.UUID, role model.Role) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.assigned[userID] == nil {
+		f.assigned[userID] = make(map[model.Role]bool)
+	}
+	f.assigned[userID][role] = true
+}
+
+func (f *fakeRoles) AssignRole(_ context.Context, userID uuid.UUID, name model.Role) error {
+	f.mu.Lock()
+	_, ok := f.permissions[name]
+	f.mu.Unlock()
+	if !ok {
+		return repository.ErrNotFound
+	}
+	f.assign(userID, name)
+	return nil
+}
+
+func (f *fakeRoles) UnassignRole(ctx context.Context, userID uuid.UUID, name model.Role) error {
+	user, err := f.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Role == name && name == model.RoleUser {
+		return repository.ErrPrimaryRole
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.assigned[userID][name] {
+		return repository.ErrNotFound
+	}
+	delete(f.assigned[userID], name)
+	if user.Role == name {
+		f.users.setRole(userID, model.RoleUser)
+		f.assigned[userID][model.RoleUser] = true
+	}
+	return nil
+}
+
+func (f *fakeRoles) ListUserRoles(_ context.Context, userID uuid.UUID) ([]model.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var roles []model.Role
+	for role := range f.assigned[userID] {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+	return roles, nil
+}
+
+func (f *fakeRoles) UserPermissions(_ context.Context, userID uuid.UUID) ([]model.Permission, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	seen := make(map[model.Permission]bool)
+	var perms []model.Permission
+	for role := range f.assigned[userID] {
+		for _, p := range f.permissions[role] {
+			if !seen[p] {
+				seen[p] = true
+				perms = append(perms, p)
+			}
+		}
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms, nil
+}
+
+// fakeAPIKeys stores keys like the api_keys queries.
+type fakeAPIKeys struct {
+	repository.APIKeyRepository
+
+	mu   sync.Mutex
+	keys map[uuid.UUID]*model.APIKey
+}
+
+func newFakeAPIKeys() *fakeAPIKeys {
+	return &fakeAPIKeys{keys: make(map[uuid.UUID]*model.APIKey)}
+}
+
+func (f *fakeAPIKeys) Create(_ context.Context, key *model.APIKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key.ID = uuid.New()
+	key.CreatedAt = time.Now().UTC()
+	stored := *key
+	f.keys[key.ID] = &stored
+	return nil
+}
+
+func (f *fakeAPIKeys) GetByHash(_ context.Context, keyHash string) (*model.APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, k := range f.keys {
+		if k.KeyHash == keyHash {
+			found := *k
+			return &found, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (f *fakeAPIKeys) RevokeAllForUser(_ context.Context, userID uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now().UTC()
+	for _, k := range f.keys {
+		if k.UserID == userID && k.RevokedAt == nil {
+			k.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (f *fakeAPIKeys) TouchLastUsed(context.Context, uuid.UUID) error { return nil }
+
+// fakeMFA stores enrollments and recovery codes like the user_mfa and
+// mfa_recovery_codes queries.
+type fakeMFA struct {
+	repository.MFARepository
+
+	mu       sync.Mutex
+	settings map[uuid.UUID]*model.MFASettings
+	// codes maps each user's recovery code hashes to whether they were used.
+	codes map[uuid.UUID]map[string]bool
+}
+
+func newFakeMFA() *fakeMFA {
+	return &fakeMFA{settings: make(map[uuid.UUID]*model.MFASettings), codes: make(map[uuid.UUID]map[string]bool)}
+}
+
+func (f *fakeMFA) Get(_ context.Context, userID uuid.UUID) (*model.MFASettings, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.settings[userID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	found := *m
+	return &found, nil
+}
+
+func (f *fakeMFA) SaveSecret(_ context.Context, userID uuid.UUID, secret string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.settings[userID].Enabled() {
+		return repository.ErrDuplicate
+	}
+	f.settings[userID] = &model.MFASettings{UserID: userID, Secret: secret, CreatedAt: time.Now().UTC()}
+	return nil
+}
+
+func (f *fakeMFA) ReplaceSecret(_ context.Context, userID uuid.UUID, old, secret string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if m, ok := f.settings[userID]; ok && m.Secret == old {
+		m.Secret = secret
+	}
+	return nil
+}
+
+func (f *fakeMFA) Enable(_ context.Context, userID uuid.UUID, recoveryCodeHashes []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.settings[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	now := time.Now().UTC()
+	m.EnabledAt = &now
+	f.codes[userID] = make(map[string]bool)
+	for _, hash := range recoveryCodeHashes {
+		f.codes[userID][hash] = false
+	}
+	return nil
+}
+
+func (f *fakeMFA) UseStep(_ context.Context, userID uuid.UUID, step int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.settings[userID]
+	if !ok || m.LastUsedStep >= step {
+		return repository.ErrTokenRevoked
+	}
+	m.LastUsedStep = step
+	return nil
+}
+
+func (f *fakeMFA) UseRecoveryCode(_ context.Context, userID uuid.UUID, codeHash string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	used, ok := f.codes[userID][codeHash]
+	if !ok || used {
+		return repository.ErrNotFound
+	}
+	f.codes[userID][codeHash] = true
+	return nil
+}
+
+// countingHasher counts the passwords checked by the hasher it wraps.
+type countingHasher struct {
+	auth.PasswordHasher
+	verified atomic.Int32
+}
+
+func (h *countingHasher) Verify(hash, password string) (bool, error) {
+	h.verified.Add(1)
+	return h.PasswordHasher.Verify(hash, password)
+}
+
+// ── Service under test ────────────────────────────────────
+
+type testService struct {
+	*userService
+	users         *fakeUsers
+	roles         *fakeRoles
+	identities    *fakeIdentities
+	apiKeys       *fakeAPIKeys
+	refreshTokens *fakeRefreshTokens
+	denylist      *fakeDenylist
+	mfa           *fakeMFA
+	actionTokens  *fakeActionTokens
+	notifier      *fakeNotifier
+	hasher        *countingHasher
+}
+
+// newTestService wires a userService to in-memory fakes. Without Redis the
+// login guard and cache fall back to their local behaviour.
+func newTestService(t *testing.T, providers ...*auth.OIDCProvider) *testService {
+	t.Helper()
+
+	keys, err := auth.NewKeySet(nil, "", "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher, err := auth.NewPasswordHasher(auth.PasswordHashConfig{Algorithm: "bcrypt", BcryptCost: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mfaKeys, err := auth.NewMFAKeys("test-mfa-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := newFakeUsers()
+	roles := newFakeRoles(users)
+	identities := newFakeIdentities()
+	apiKeys := newFakeAPIKeys()
+	refreshTokens := newFakeRefreshTokens()
+	denylist := newFakeDenylist()
+	mfa := newFakeMFA()
+	actionTokens := newFakeActionTokens()
+	notifier := &fakeNotifier{}
+	counting := &countingHasher{PasswordHasher: hasher}
+	svc := NewUserService(
+		users,
+		repository.NewUserCache(nil, time.Minute),
+		refreshTokens,
+		denylist,
+		roles,
+		actionTokens,
+		mfa,
+		identities,
+		apiKeys,
+		providers,
+		auth.NewIssuer(keys, time.Hour, 24*time.Hour),
+		counting,
+		mfaKeys,
+		NewLoginGuard(repository.NewLoginAttemptStore(nil, 100), LockoutPolicy{
+			MaxAttempts:     100,
+			IPMaxAttempts:   100,
+			LockoutDuration: time.Minute,
+		}),
+		notifier,
+		AccountOptions{
+			PasswordResetTTL:     time.Hour,
+			EmailVerificationTTL: time.Hour,
+			VerifyURL:            "http://localhost/verify",
+			MFAIssuer:            "test",
+			MFAChallengeTTL:      time.Minute,
+			PasswordPolicy:       PasswordPolicy{MinLength: 8, MaxBytes: 72},
+		},
+	)
+
+	return &testService{
+		userService:   svc.(*userService),
+		users:         users,
+		roles:         roles,
+		identities:    identities,
+		apiKeys:       apiKeys,
+		refreshTokens: refreshTokens,
+		denylist:      denylist,
+		mfa:           mfa,
+		actionTokens:  actionTokens,
+		notifier:      notifier,
+		hasher:        counting,
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/repository"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrAccountLocked is returned when login attempts are temporarily blocked.
+var ErrAccountLocked = newError("account_locked", http.StatusTooManyRequests, codes.ResourceExhausted, "too many failed login attempts")
+
+// LockedError reports when a locked email or IP may try again.
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrAccountLocked, e.Until.Format(time.RFC3339))
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrAccountLocked
+}
+
+// LockoutPolicy configures brute-force protection for logins.
+type LockoutPolicy struct {
+	// MaxAttempts failures per email lock the account for LockoutDuration.
+	MaxAttempts int
+	// IPMaxAttempts failures per client IP lock that IP for LockoutDuration.
+	IPMaxAttempts int
+	// LockoutDuration is both the lock length and the failure counting window.
+	LockoutDuration time.Duration
+	// BaseDelay is the enforced wait after the first failure; it doubles with
+	// every further failure until MaxAttempts is reached.
+	BaseDelay time.Duration
+}
+
+// LoginGuard counts failed logins per email and per IP and enforces
+// progressive delays followed by a temporary lockout.
+type LoginGuard struct {
+	store  repository.LoginAttemptStore
+	policy LockoutPolicy
+}
+
+// NewLoginGuard creates a LoginGuard backed by store.
+func NewLoginGuard(store repository.LoginAttemptStore, policy LockoutPolicy) *LoginGuard {
+	return &LoginGuard{store: store, policy: policy}
+}
+
+func emailAttemptKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns a *LockedError if either the email or the IP is locked.
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) error {
+	keys := []string{emailAttemptKey(email)}
+	if ip != "" {
+		keys = append(keys, ipAttemptKey(ip))
+	}
+
+	for _, key := range keys {
+		until, err := g.store.LockedUntil(ctx, key)
+		if err != nil {
+			return fmt.Errorf("check login lock: %w", err)
+		}
+		if time.Now().Before(until) {
+			return &LockedError{Until: until}
+		}
+	}
+
+	return nil
+}
+
+// Failure records a failed attempt and applies any resulting delay or lock.
+func (g *LoginGuard) Failure(ctx context.Context, email, ip string) {
+	emailKey := emailAttemptKey(email)
+
+	n, err := g.store.RegisterFailure(ctx, emailKey, g.policy.LockoutDuration)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to record login failure")
+		return
+	}
+	g.lock(ctx, emailKey, n, g.policy.MaxAttempts, g.delay(n))
+
+	if ip == "" {
+		return
+	}
+
+	ipKey := ipAttemptKey(ip)
+	n, err = g.store.RegisterFailure(ctx, ipKey, g.policy.LockoutDuration)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to record login failure")
+		return
+	}
+	g.lock(ctx, ipKey, n, g.policy.IPMaxAttempts, 0)
+}
+
+// Success clears the failure history for the email. IP counters are left
+// alone so that one valid account cannot be used to reset an attacker's IP.
+func (g *LoginGuard) Success(ctx context.Context, email string) {
+	if err := g.store.Reset(ctx, emailAttemptKey(email)); err != nil {
+		log.Warn().Err(err).Msg("failed to reset login failures")
+	}
+}
+
+// Unlock lifts a lockout on the email and clears its failure history.
+func (g *LoginGuard) Unlock(ctx context.Context, email string) error {
+	return g.store.Reset(ctx, emailAttemptKey(email))
+}
+
+// delay returns the progressive wait after the n-th failure.
+func (g *LoginGuard) delay(n int) time.Duration {
+	if g.policy.BaseDelay <= 0 || n < 1 {
+		return 0
+	}
+	d := g.policy.BaseDelay << (n - 1)
+	if d <= 0 || d > g.policy.LockoutDuration {
+		return g.policy.LockoutDuration
+	}
+	return d
+}
+
+func (g *LoginGuard) lock(ctx context.Context, key string, n, limit int, delay time.Duration) {
+	d := delay
+	if limit > 0 && n >= limit {
+		d = g.policy.LockoutDuration
+		log.Warn().
+			Str("key", key).
+			Int("failures", n).
+			Dur("duration", d).
+			Msg("login locked out after repeated failures")
+	}
+	if d <= 0 {
+		return
+	}
+
+	if err := g.store.Lock(ctx, key, d); err != nil {
+		log.Error().Err(err).Str("key", key).Msg("failed to lock login")
+	}
+}
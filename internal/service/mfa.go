@@ -0,0 +1,359 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/auth"
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/repository"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+)
+
+// Errors returned by the two-factor authentication flow.
+var (
+	ErrMFARequired       = errors.New("mfa_required")
+	ErrInvalidMFAToken   = newError("invalid_mfa_token", http.StatusUnauthorized, codes.Unauthenticated, "invalid or expired mfa token")
+	ErrInvalidMFACode    = newError("invalid_mfa_code", http.StatusBadRequest, codes.InvalidArgument, "invalid mfa code")
+	ErrMFANotEnrolled    = newError("mfa_not_enrolled", http.StatusConflict, codes.FailedPrecondition, "mfa is not enrolled")
+	ErrMFAAlreadyEnabled = newError("mfa_already_enabled", http.StatusConflict, codes.FailedPrecondition, "mfa is already enabled")
+)
+
+// MFARequiredError is returned by Login when the password was correct but
+// the account requires a second factor. Challenge must be exchanged for a
+// session through VerifyMFA.
+type MFARequiredError struct {
+	Challenge model.MFAChallenge
+}
+
+func (e *MFARequiredError) Error() string {
+	return ErrMFARequired.Error()
+}
+
+func (e *MFARequiredError) Unwrap() error {
+	return ErrMFARequired
+}
+
+const (
+	totpPeriod        = 30 // seconds
+	recoveryCodeCount = 10
+	// recoveryCodeBytes gives 80 bits of entropy, 16 base32 characters.
+	recoveryCodeBytes = 10
+	// legacyRecoveryCodeLen is the length of the 8-character codes issued
+	// before recovery codes were lengthened, stored as plain SHA-256 hashes.
+	// They keep working until MFA is next enabled.
+	legacyRecoveryCodeLen = 8
+)
+
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+// EnrollMFA generates a new TOTP secret for the user. MFA is not enforced
+// until EnableMFA confirms a code from the authenticator.
+func (s *userService) EnrollMFA(ctx context.Context, userID uuid.UUID) (*model.MFAEnrollResponse, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.opts.MFAIssuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("generate totp secret: %w", err)
+	}
+
+	sealed, err := s.mfaKeys.SealSecret(userID, key.Secret())
+	if err != nil {
+		return nil, fmt.Errorf("encrypt totp secret: %w", err)
+	}
+
+	if err := s.mfa.SaveSecret(ctx, userID, sealed); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return &model.MFAEnrollResponse{Secret: key.Secret(), URI: key.URL()}, nil
+}
+
+// EnableMFA turns on the second factor once code proves the authenticator
+// is set up, and returns a fresh set of recovery codes.
+func (s *userService) EnableMFA(ctx context.Context, userID uuid.UUID, code string) (*model.MFARecoveryCodesResponse, error) {
+	settings, err := s.mfa.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+
+	if settings.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	ok, err := s.checkTOTP(ctx, settings, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes(s.mfaKeys)
+	if err != nil {
+		return nil, fmt.Errorf("generate recovery codes: %w", err)
+	}
+
+	if err := s.mfa.Enable(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", userID.String()).Msg("mfa enabled")
+
+	return &model.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableMFA turns off the second factor after checking a TOTP or recovery code.
+func (s *userService) DisableMFA(ctx context.Context, userID uuid.UUID, code string) error {
+	settings, err := s.mfa.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrMFANotEnrolled
+		}
+		return err
+	}
+
+	if !settings.Enabled() {
+		return ErrMFANotEnrolled
+	}
+
+	ok, err := s.checkCode(ctx, settings, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	if err := s.mfa.Disable(ctx, userID); err != nil {
+		return err
+	}
+
+	log.Warn().Str("user_id", userID.String()).Msg("mfa disabled")
+
+	return nil
+}
+
+// VerifyMFA completes a two-step login by exchanging a challenge token and a
+// TOTP or recovery code for a session. Each challenge can be used once, and
+// wrong codes count towards the login lockout.
+func (s *userService) VerifyMFA(ctx context.Context, mfaToken, code, clientIP string) (*model.LoginResponse, error) {
+	claims, err := s.issuer.VerifyChallenge(mfaToken, auth.PurposeMFAChallenge)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	revoked, err := s.denylist.IsTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("check mfa token: %w", err)
+	}
+	if revoked {
+		return nil, ErrInvalidMFAToken
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+
+	if err := s.guard.Check(ctx, user.Email, clientIP); err != nil {
+		return nil, err
+	}
+
+	settings, err := s.mfa.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+
+	ok, err := s.checkCode(ctx, settings, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.guard.Failure(ctx, user.Email, clientIP)
+		return nil, ErrInvalidMFACode
+	}
+
+	s.guard.Success(ctx, user.Email)
+
+	if err := s.denylist.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, fmt.Errorf("revoke mfa token: %w", err)
+	}
+
+	resp, refresh, err := s.newSession(ctx, user, uuid.New())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokens.Create(ctx, refresh); err != nil {
+		return nil, fmt.Errorf("store refresh token: %w", err)
+	}
+
+	return resp, nil
+}
+
+// mfaChallenge returns an *MFARequiredError if the user has MFA enabled.
+func (s *userService) mfaChallenge(ctx context.Context, user *model.User) error {
+	settings, err := s.mfa.Get(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("get mfa settings: %w", err)
+	}
+
+	if !settings.Enabled() {
+		return nil
+	}
+
+	token, expiresAt, err := s.issuer.IssueChallenge(user.ID, auth.PurposeMFAChallenge, s.opts.MFAChallengeTTL)
+	if err != nil {
+		return fmt.Errorf("sign mfa token: %w", err)
+	}
+
+	return &MFARequiredError{Challenge: model.MFAChallenge{
+		Status:    ErrMFARequired.Error(),
+		MFAToken:  token,
+		ExpiresAt: expiresAt,
+	}}
+}
+
+// checkCode accepts either a current TOTP code or an unused recovery code.
+func (s *userService) checkCode(ctx context.Context, settings *model.MFASettings, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == int(otp.DigitsSix) {
+		return s.checkTOTP(ctx, settings, code)
+	}
+
+	code = normalizeRecoveryCode(code)
+	hash := s.mfaKeys.HashRecoveryCode(code)
+	if len(code) == legacyRecoveryCodeLen {
+		hash = hashToken(code)
+	}
+
+	err := s.mfa.UseRecoveryCode(ctx, settings.UserID, hash)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	log.Info().Str("user_id", settings.UserID.String()).Msg("mfa recovery code used")
+
+	return true, nil
+}
+
+// checkTOTP validates code against the current time step and one step either
+// side for clock drift. A step is accepted at most once to stop replay.
+func (s *userService) checkTOTP(ctx context.Context, settings *model.MFASettings, code string) (bool, error) {
+	secret, err := s.totpSecret(ctx, settings)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	for _, offset := range []int{0, -1, 1} {
+		t := now.Add(time.Duration(offset*totpPeriod) * time.Second)
+		ok, err := totp.ValidateCustom(code, secret, t, totpOpts)
+		if err != nil || !ok {
+			continue
+		}
+
+		err = s.mfa.UseStep(ctx, settings.UserID, t.Unix()/totpPeriod)
+		if err != nil {
+			if errors.Is(err, repository.ErrTokenRevoked) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// totpSecret decrypts the user's TOTP secret. A secret stored in plain text
+// before encryption was introduced is encrypted on the way.
+func (s *userService) totpSecret(ctx context.Context, settings *model.MFASettings) (string, error) {
+	secret, legacy, err := s.mfaKeys.OpenSecret(settings.UserID, settings.Secret)
+	if err != nil {
+		return "", fmt.Errorf("decrypt totp secret: %w", err)
+	}
+
+	if legacy {
+		sealed, err := s.mfaKeys.SealSecret(settings.UserID, secret)
+		if err == nil {
+			err = s.mfa.ReplaceSecret(ctx, settings.UserID, settings.Secret, sealed)
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("user_id", settings.UserID.String()).Msg("failed to encrypt legacy totp secret")
+		}
+	}
+
+	return secret, nil
+}
+
+// generateRecoveryCodes returns display codes like "ABCD-EFGH-IJKL-MNOP"
+// with their storage hashes.
+func generateRecoveryCodes(keys *auth.MFAKeys) ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := base32.StdEncoding.EncodeToString(b) // 16 characters, no padding
+		codes[i] = raw[:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:]
+		hashes[i] = keys.HashRecoveryCode(raw)
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/model"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+// enableMFA enrolls the user and returns the TOTP secret and recovery codes.
+func enableMFA(t *testing.T, svc *testService, user *model.User) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	enrolled, err := svc.EnrollMFA(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.GenerateCodeCustom(enrolled.Secret, time.Now(), totpOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovery, err := svc.EnableMFA(ctx, user.ID, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enrolled.Secret, recovery.RecoveryCodes
+}
+
+// challenge logs the user in and returns the MFA challenge token.
+func challenge(t *testing.T, svc *testService) string {
+	t.Helper()
+	_, err := svc.Login(context.Background(), model.LoginRequest{Email: "mfa@example.com", Password: "correct-horse-battery"}, "192.0.2.1")
+	var required *MFARequiredError
+	if !errors.As(err, &required) {
+		t.Fatalf("Login err = %v, want an MFA challenge", err)
+	}
+	return required.Challenge.MFAToken
+}
+
+func newMFAUser(t *testing.T, svc *testService) *model.User {
+	t.Helper()
+	hash, err := svc.hasher.Hash("correct-horse-battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc.users.add(t, model.User{Email: "mfa@example.com", Name: "MFA", Password: hash, Role: model.RoleUser, Active: true})
+}
+
+func TestMFACredentialsAreProtectedAtRest(t *testing.T) {
+	svc := newTestService(t)
+	user := newMFAUser(t, svc)
+
+	secret, codes := enableMFA(t, svc, user)
+
+	stored, err := svc.mfa.Get(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored.Secret, secret) {
+		t.Errorf("TOTP secret stored in plain text: %q", stored.Secret)
+	}
+
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("%d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+	format := regexp.MustCompile(`^[A-Z2-7]{4}(-[A-Z2-7]{4}){3}$`)
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("recovery code %q is not four groups of four base32 characters", code)
+		}
+		sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+		if _, ok := svc.mfa.codes[user.ID][hex.EncodeToString(sum[:])]; ok {
+			t.Errorf("recovery code %q stored as its unkeyed SHA-256 hash", code)
+		}
+	}
+}
+
+func TestVerifyMFA(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	user := newMFAUser(t, svc)
+	secret, codes := enableMFA(t, svc, user)
+
+	// EnableMFA used the current time step
+	nextStep, err := totp.GenerateCodeCustom(secret, time.Now().Add(totpPeriod*time.Second), totpOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{"totp code", nextStep, nil},
+		{"replayed totp code", nextStep, ErrInvalidMFACode},
+		{"recovery code", codes[0], nil},
+		{"recovery code in lower case without dashes", strings.ToLower(strings.ReplaceAll(codes[1], "-", "")), nil},
+		{"used recovery code", codes[0], ErrInvalidMFACode},
+		{"unknown recovery code", "AAAA-AAAA-AAAA-AAAA", ErrInvalidMFACode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.VerifyMFA(ctx, challenge(t, svc), tt.code, "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && resp.Token == "" {
+				t.Error("no session issued")
+			}
+		})
+	}
+}
+
+func TestLegacyMFACredentialsKeepWorking(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	user := newMFAUser(t, svc)
+
+	// An enrollment from before secrets were encrypted and codes lengthened
+	const secret = "JBSWY3DPEHPK3PXP"
+	const legacyCode = "ABCD-EFGH"
+	sum := sha256.Sum256([]byte("ABCDEFGH"))
+	if err := svc.mfa.SaveSecret(ctx, user.ID, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.mfa.Enable(ctx, user.ID, []string{hex.EncodeToString(sum[:])}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := totp.GenerateCodeCustom(secret, time.Now(), totpOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.VerifyMFA(ctx, challenge(t, svc), code, "192.0.2.1"); err != nil {
+		t.Fatalf("legacy TOTP secret: %v", err)
+	}
+	stored, err := svc.mfa.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Secret == secret {
+		t.Error("legacy TOTP secret still stored in plain text after use")
+	}
+
+	if _, err := svc.VerifyMFA(ctx, challenge(t, svc), legacyCode, "192.0.2.1"); err != nil {
+		t.Fatalf("legacy recovery code: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/auth"
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc/codes"
+)
+
+// Errors returned by external (OIDC) logins.
+var (
+	ErrUnknownProvider     = newError("unknown_provider", http.StatusNotFound, codes.NotFound, "unknown identity provider")
+	ErrExternalLogin       = newError("external_login_failed", http.StatusUnauthorized, codes.Unauthenticated, "external login failed")
+	ErrIdentityConflict    = newError("identity_conflict", http.StatusConflict, codes.AlreadyExists, "email is registered to an account that is not linked to this provider")
+	ErrIdentityNoEmail     = newError("identity_no_email", http.StatusUnauthorized, codes.Unauthenticated, "identity provider did not return an email address")
+	ErrProviderUnavailable = newError("provider_unavailable", http.StatusBadGateway, codes.Unavailable, "identity provider unavailable")
+)
+
+// BeginOIDCLogin starts an authorization code flow with PKCE. The returned
+// state, nonce and verifier must be presented again to CompleteOIDCLogin.
+func (s *userService) BeginOIDCLogin(ctx context.Context, provider string) (*model.OIDCAuthRequest, error) {
+	p, ok := s.oidc[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	state, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate state: %w", err)
+	}
+	nonce, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	url, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+
+	return &model.OIDCAuthRequest{
+		Provider: provider,
+		URL:      url,
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+	}, nil
+}
+
+// CompleteOIDCLogin redeems the provider's authorization code and signs the
+// linked user in, returning the same session as a password login.
+//
+// Unknown identities are linked to an existing account only when both the
+// provider and the account have verified the email address; without an
+// account a new one is created.
+func (s *userService) CompleteOIDCLogin(ctx context.Context, provider, code, verifier, nonce string) (*model.LoginResponse, error) {
+	p, ok := s.oidc[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	identity, err := p.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		if errors.Is(err, auth.ErrOIDCExchange) {
+			log.Warn().Err(err).Str("provider", provider).Msg("oidc login rejected")
+			return nil, ErrExternalLogin
+		}
+		return nil, err
+	}
+
+	user, err := s.userForIdentity(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(ctx, user)
+}
+
+// userForIdentity resolves, links or creates the local user for identity.
+func (s *userService) userForIdentity(ctx context.Context, identity *auth.ExternalIdentity) (*model.User, error) {
+	linked, err := s.identities.Get(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		return s.repo.GetByID(ctx, linked.UserID)
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	if identity.Email == "" {
+		return nil, ErrIdentityNoEmail
+	}
+	email, err := NormalizeEmail(identity.Email)
+	if err != nil {
+		return nil, ErrIdentityNoEmail
+	}
+	identity.Email = email
+
+	user, err := s.repo.GetByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// Linking on an unverified email would let anyone who can register
+		// that address at the provider take over the account. Linking to an
+		// account whose own email is unverified would hand the owner an
+		// account someone else may have registered, password included.
+		if !identity.EmailVerified || user.EmailVerifiedAt == nil {
+			return nil, ErrIdentityConflict
+		}
+	case errors.Is(err, repository.ErrNotFound):
+		user, err = s.createExternalUser(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+
+	err = s.identities.Create(ctx, &model.LinkedIdentity{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil && !errors.Is(err, repository.ErrDuplicate) {
+		return nil, fmt.Errorf("link identity: %w", err)
+	}
+
+	log.Info().
+		Str("user_id", user.ID.String()).
+		Str("provider", identity.Provider).
+		Msg("linked external identity")
+
+	return user, nil
+}
+
+// createExternalUser registers a user for an external identity. The account
+// gets an unusable random password; a password can be set later through the
+// reset flow.
+func (s *userService) createExternalUser(ctx context.Context, identity *auth.ExternalIdentity) (*model.User, error) {
+	rawPassword, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate password: %w", err)
+	}
+
+	hashedPassword, err := s.hasher.Hash(rawPassword)
+	if err != nil {
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
+
+	name := strings.TrimSpace(identity.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	if r := []rune(name); len(r) > 100 {
+		name = string(r[:100])
+	}
+
+	user := &model.User{
+		Email:    identity.Email,
+		Name:     name,
+		Password: hashedPassword,
+		Role:     model.RoleUser,
+		Active:   true,
+	}
+	if identity.EmailVerified {
+		verifiedAt := time.Now().UTC()
+		user.EmailVerifiedAt = &verifiedAt
+	}
+
+	// Lost a race against a registration of the same email
+	if err := s.repo.Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, fmt.Errorf("%w: %w", ErrEmailTaken, err)
+		}
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+
+	if user.EmailVerifiedAt == nil {
+		if err := s.sendVerification(ctx, user); err != nil {
+			log.Error().Err(err).Str("user_id", user.ID.String()).Msg("failed to send email verification")
+		}
+	}
+
+	return user, nil
+}
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/auth"
+	"Go-Microservice-Template/internal/model"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const fakeClientID = "test-client"
+
+// fakeOIDCProvider is a minimal OpenID provider serving discovery, JWKS and
+// a token endpoint that checks the PKCE verifier.
+type fakeOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]fakeGrant // by authorization code
+}
+
+// fakeGrant is what the user consented to at the authorization endpoint.
+type fakeGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeOIDCProvider{key: key, grants: make(map[string]fakeGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", p.token)
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (p *fakeOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	grant, ok := p.grants[r.PostForm.Get("code")]
+	delete(p.grants, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+// client returns a provider client for p, registered as "fake".
+func (p *fakeOIDCProvider) client() *auth.OIDCProvider {
+	return auth.NewOIDCProvider(auth.OIDCProviderConfig{
+		Name:        "fake",
+		IssuerURL:   p.server.URL,
+		ClientID:    fakeClientID,
+		RedirectURL: "http://localhost/callback",
+	})
+}
+
+// authorize plays the user consenting at the authorization URL and returns
+// the code the provider redirects back with. claims are added to the ID
+// token's standard claims.
+func (p *fakeOIDCProvider) authorize(t *testing.T, authURL string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", q.Get("code_challenge_method"))
+	}
+
+	now := time.Now()
+	all := jwt.MapClaims{
+		"iss":   p.server.URL,
+		"aud":   fakeClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	for k, v := range claims {
+		all[k] = v
+	}
+
+	code := uuid.NewString()
+	p.mu.Lock()
+	p.grants[code] = fakeGrant{challenge: q.Get("code_challenge"), claims: all}
+	p.mu.Unlock()
+	return code
+}
+
+func TestCompleteOIDCLogin(t *testing.T) {
+	verifiedAt := time.Now().UTC()
+
+	tests := []struct {
+		name string
+		// existing, if set, is registered before the login
+		existing *model.User
+		claims   jwt.MapClaims
+		// wrongVerifier presents a PKCE verifier other than the one started with
+		wrongVerifier bool
+		wantErr       error
+		// wantExisting expects the login to be linked to existing
+		wantExisting bool
+	}{
+		{
+			name:   "new user",
+			claims: jwt.MapClaims{"sub": "new", "email": "New@Example.com", "email_verified": true, "name": "New"},
+		},
+		{
+			name:         "links verified account",
+			existing:     &model.User{Email: "alice@example.com", Name: "Alice", Role: model.RoleUser, Active: true, EmailVerifiedAt: &verifiedAt},
+			claims:       jwt.MapClaims{"sub": "alice", "email": "alice@example.com", "email_verified": true},
+			wantExisting: true,
+		},
+		{
+			name:     "refuses unverified account",
+			existing: &model.User{Email: "victim@example.com", Name: "Squatter", Role: model.RoleUser, Active: true},
+			claims:   jwt.MapClaims{"sub": "victim", "email": "victim@example.com", "email_verified": true},
+			wantErr:  ErrIdentityConflict,
+		},
+		{
+			name:     "refuses email unverified by provider",
+			existing: &model.User{Email: "bob@example.com", Name: "Bob", Role: model.RoleUser, Active: true, EmailVerifiedAt: &verifiedAt},
+			claims:   jwt.MapClaims{"sub": "bob", "email": "bob@example.com", "email_verified": false},
+			wantErr:  ErrIdentityConflict,
+		},
+		{
+			name:          "PKCE verifier mismatch",
+			claims:        jwt.MapClaims{"sub": "pkce", "email": "pkce@example.com", "email_verified": true},
+			wrongVerifier: true,
+			wantErr:       ErrExternalLogin,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			provider := newFakeOIDCProvider(t)
+			svc := newTestService(t, provider.client())
+
+			var existing *model.User
+			if tt.existing != nil {
+				existing = svc.users.add(t, *tt.existing)
+			}
+
+			req, err := svc.BeginOIDCLogin(ctx, "fake")
+			if err != nil {
+				t.Fatal(err)
+			}
+			code := provider.authorize(t, req.URL, tt.claims)
+
+			verifier := req.Verifier
+			if tt.wrongVerifier {
+				verifier = "a-different-verifier-of-sufficient-length-0123456789"
+			}
+
+			resp, err := svc.CompleteOIDCLogin(ctx, "fake", code, verifier, req.Nonce)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if _, err := svc.identities.Get(ctx, "fake", tt.claims["sub"].(string)); err == nil {
+					t.Error("identity was linked despite the error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Token == "" {
+				t.Error("no access token issued")
+			}
+
+			linked, err := svc.identities.Get(ctx, "fake", tt.claims["sub"].(string))
+			if err != nil {
+				t.Fatalf("identity not linked: %v", err)
+			}
+			if linked.UserID != resp.User.ID {
+				t.Errorf("identity linked to %s, want %s", linked.UserID, resp.User.ID)
+			}
+			if tt.wantExisting && resp.User.ID != existing.ID {
+				t.Errorf("signed in as %s, want existing account %s", resp.User.ID, existing.ID)
+			}
+			if !tt.wantExisting && svc.users.count() != 1 {
+				t.Errorf("%d users, want the one created", svc.users.count())
+			}
+		})
+	}
+}
+
+func TestCompleteOIDCLoginNonceMismatch(t *testing.T) {
+	ctx := context.Background()
+	provider := newFakeOIDCProvider(t)
+	svc := newTestService(t, provider.client())
+
+	req, err := svc.BeginOIDCLogin(ctx, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := provider.authorize(t, req.URL, jwt.MapClaims{"sub": "s", "email": "s@example.com", "email_verified": true})
+
+	if _, err := svc.CompleteOIDCLogin(ctx, "fake", code, req.Verifier, "another-nonce"); !errors.Is(err, ErrExternalLogin) {
+		t.Fatalf("err = %v, want %v", err, ErrExternalLogin)
+	}
+}
+
+func TestCompleteOIDCLoginRegistrationRace(t *testing.T) {
+	ctx := context.Background()
+	provider := newFakeOIDCProvider(t)
+	svc := newTestService(t, provider.client())
+
+	// A password registration of the same email lands between the lookup
+	// and the insert of the external account
+	svc.users.beforeCreate = func(user *model.User) {
+		svc.users.beforeCreate = nil
+		svc.users.add(t, model.User{Email: user.Email, Name: "Racer", Role: model.RoleUser, Active: true})
+	}
+
+	req, err := svc.BeginOIDCLogin(ctx, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := provider.authorize(t, req.URL, jwt.MapClaims{"sub": "race", "email": "race@example.com", "email_verified": true})
+
+	if _, err := svc.CompleteOIDCLogin(ctx, "fake", code, req.Verifier, req.Nonce); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("err = %v, want %v", err, ErrEmailTaken)
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+)
+
+// ErrWeakPassword is returned when a new password violates the policy.
+var ErrWeakPassword = newError("weak_password", http.StatusBadRequest, codes.InvalidArgument, "password does not meet the password policy")
+
+// bcryptMaxBytes is the longest input bcrypt uses; anything after it is
+// silently ignored, so longer passwords are rejected instead.
+const bcryptMaxBytes = 72
+
+// PasswordPolicyError lists every rule a rejected password broke.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return ErrWeakPassword.Error() + ": " + strings.Join(e.Violations, "; ")
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// PasswordPolicy decides which new passwords are acceptable. It applies to
+// registration, password changes and resets alike.
+type PasswordPolicy struct {
+	MinLength     int // characters
+	MaxBytes      int // capped at bcrypt's 72-byte limit
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Breached, if set, rejects passwords known from public breaches.
+	Breached *BreachedPasswords
+}
+
+// Check returns a *PasswordPolicyError if password breaks any rule. email and
+// name are the account's, and must not appear in the password.
+func (p PasswordPolicy) Check(password, email, name string) error {
+	var violations []string
+
+	maxBytes := p.MaxBytes
+	if maxBytes <= 0 || maxBytes > bcryptMaxBytes {
+		maxBytes = bcryptMaxBytes
+	}
+
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if len(password) > maxBytes {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes", maxBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r), unicode.IsSymbol(r), unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "must contain an upper-case letter")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "must contain a lower-case letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if containsIdentity(password, email, name) {
+		violations = append(violations, "must not contain your email address or name")
+	}
+
+	if p.Breached.Contains(password) {
+		violations = append(violations, "appears in a known data breach")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsIdentity reports whether password contains the email, its local
+// part or any part of name, ignoring case. Fragments shorter than three
+// characters are ignored.
+func containsIdentity(password, email, name string) bool {
+	password = strings.ToLower(password)
+
+	fragments := strings.Fields(strings.ToLower(name))
+	if email != "" {
+		email = strings.ToLower(email)
+		local, _, _ := strings.Cut(email, "@")
+		fragments = append(fragments, email, local)
+	}
+
+	for _, f := range fragments {
+		if utf8.RuneCountInString(f) >= 3 && strings.Contains(password, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// ── Breached Passwords ────────────────────────────────────
+
+// breachedRangeLen is the number of hex characters used to bucket hashes,
+// as in the k-anonymity range API of Have I Been Pwned.
+const breachedRangeLen = 5
+
+// minBreachedEntryLen keeps very short prefixes, which would match most
+// passwords, out of the list.
+const minBreachedEntryLen = 10
+
+// BreachedPasswords is an offline set of SHA-1 hashes of breached passwords.
+// Entries may be full hashes or hash prefixes; storing only prefixes keeps
+// the list small at the cost of some false positives.
+type BreachedPasswords struct {
+	ranges map[string][]string // 5-char range -> sorted remaining suffixes
+	size   int
+}
+
+// LoadBreachedPasswords reads a breached-password list from path. Each line
+// holds an upper- or lower-case hex SHA-1 hash or hash prefix of at least 10
+// characters, optionally followed by ":count" as in the Pwned Passwords
+// downloads. Blank lines and lines starting with "#" are skipped.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	b := &BreachedPasswords{ranges: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		entry, _, _ = strings.Cut(entry, ":")
+		entry = strings.ToUpper(entry)
+
+		if len(entry) < minBreachedEntryLen || len(entry) > sha1.Size*2 {
+			return nil, fmt.Errorf("breached password list line %d: entry must be %d-%d hex characters", line, minBreachedEntryLen, sha1.Size*2)
+		}
+		if _, err := hex.DecodeString(entry[:len(entry)&^1]); err != nil {
+			return nil, fmt.Errorf("breached password list line %d: not a hex hash", line)
+		}
+
+		r := entry[:breachedRangeLen]
+		b.ranges[r] = append(b.ranges[r], entry[breachedRangeLen:])
+		b.size++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breached password list: %w", err)
+	}
+
+	for _, suffixes := range b.ranges {
+		sort.Strings(suffixes)
+	}
+
+	return b, nil
+}
+
+// Len returns the number of entries in the list.
+func (b *BreachedPasswords) Len() int {
+	if b == nil {
+		return 0
+	}
+	return b.size
+}
+
+// Contains reports whether password's SHA-1 hash starts with any entry. A
+// nil list contains nothing.
+func (b *BreachedPasswords) Contains(password string) bool {
+	if b == nil {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// Only the bucket sharing the hash's range is searched, mirroring the
+	// k-anonymity lookup so the list can be swapped for the online API.
+	suffixes := b.ranges[hash[:breachedRangeLen]]
+	rest := hash[breachedRangeLen:]
+
+	// Any matching entry is a prefix of rest, so it sorts at or before rest;
+	// entries that are prefixes of one another sort next to each other.
+	i := sort.SearchStrings(suffixes, rest)
+	if i < len(suffixes) && suffixes[i] == rest {
+		return true
+	}
+	for j := i - 1; j >= 0; j-- {
+		if strings.HasPrefix(rest, suffixes[j]) {
+			return true
+		}
+		if suffixes[j][0] != rest[0] {
+			break
+		}
+	}
+	return false
+}
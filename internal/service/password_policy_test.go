@@ -0,0 +1,139 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func writeBreachedList(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	breached, err := LoadBreachedPasswords(writeBreachedList(t, sha1Hex("Password123!")+":42"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	strict := PasswordPolicy{
+		MinLength:     10,
+		MaxBytes:      72,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		Breached:      breached,
+	}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		// want lists the expected violations; nil accepts the password
+		want []string
+	}{
+		{"meets every rule", strict, "Tr0ub4dor&3x", nil},
+		{"too short", strict, "Tr0ub&3x", []string{"must be at least 10 characters"}},
+		{"length counts characters not bytes", PasswordPolicy{MinLength: 4}, "ñöçü", nil},
+		{"over the byte limit", PasswordPolicy{MinLength: 1, MaxBytes: 16}, strings.Repeat("a", 17), []string{"must be at most 16 bytes"}},
+		{"byte limit capped at 72", PasswordPolicy{MinLength: 1, MaxBytes: 100}, strings.Repeat("a", 73), []string{"must be at most 72 bytes"}},
+		{"missing character classes", strict, "abcdefghijkl", []string{
+			"must contain an upper-case letter",
+			"must contain a digit",
+			"must contain a symbol",
+		}},
+		{"space counts as a symbol", PasswordPolicy{MinLength: 1, RequireSymbol: true}, "correct horse", nil},
+		{"contains the name", strict, "Tr0ub4dor&Alice", []string{"must not contain your email address or name"}},
+		{"contains the email local part", strict, "X9!alice.smith", []string{"must not contain your email address or name"}},
+		{"name fragments under three characters ignored", PasswordPolicy{MinLength: 1}, "jo-is-here", nil},
+		{"breached", strict, "Password123!", []string{"appears in a known data breach"}},
+		{"everything wrong", strict, "alice", []string{
+			"must be at least 10 characters",
+			"must contain an upper-case letter",
+			"must contain a digit",
+			"must contain a symbol",
+			"must not contain your email address or name",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.password, "Alice.Smith@example.com", "Alice Smith Jo")
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Check: %v", err)
+				}
+				return
+			}
+
+			var perr *PasswordPolicyError
+			if !errors.As(err, &perr) {
+				t.Fatalf("err = %v, want a *PasswordPolicyError", err)
+			}
+			if !reflect.DeepEqual(perr.Violations, tt.want) {
+				t.Errorf("violations = %q, want %q", perr.Violations, tt.want)
+			}
+			if !errors.Is(err, ErrWeakPassword) {
+				t.Errorf("err does not match ErrWeakPassword")
+			}
+		})
+	}
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	full := sha1Hex("hunter2")
+	prefixOnly := sha1Hex("letmein")[:12]
+
+	list, err := LoadBreachedPasswords(writeBreachedList(t,
+		"# Pwned Passwords excerpt",
+		"",
+		strings.ToLower(full)+":1234",
+		prefixOnly,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Len() != 2 {
+		t.Errorf("Len = %d, want 2", list.Len())
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"hunter2", true},
+		{"letmein", true},
+		{"Hunter2", false},
+		{"correct horse battery staple", false},
+	}
+	for _, tt := range tests {
+		if got := list.Contains(tt.password); got != tt.want {
+			t.Errorf("Contains(%q) = %t, want %t", tt.password, got, tt.want)
+		}
+	}
+
+	var none *BreachedPasswords
+	if none.Contains("hunter2") || none.Len() != 0 {
+		t.Error("a nil list must contain nothing")
+	}
+
+	for _, bad := range []string{"ABCDEF", "not-hex-at-all-but-long", strings.Repeat("A", 41)} {
+		if _, err := LoadBreachedPasswords(writeBreachedList(t, bad)); err == nil {
+			t.Errorf("entry %q was accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/notify"
+	"Go-Microservice-Template/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrInvalidResetToken is returned for unknown, expired or already used
+// password reset tokens.
+var ErrInvalidResetToken = newError("invalid_reset_token", http.StatusBadRequest, codes.InvalidArgument, "invalid or expired reset token")
+
+// ForgotPassword sends a single-use reset token to the account registered
+// under email. It succeeds whether or not the account exists, so callers
+// cannot use it to discover registered emails.
+func (s *userService) ForgotPassword(ctx context.Context, email string) error {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("find user: %w", err)
+	}
+
+	rawToken, err := generateToken()
+	if err != nil {
+		return fmt.Errorf("generate reset token: %w", err)
+	}
+
+	token := &model.ActionToken{
+		UserID:    user.ID,
+		Purpose:   model.PurposePasswordReset,
+		TokenHash: hashToken(rawToken),
+		ExpiresAt: time.Now().Add(s.opts.PasswordResetTTL).UTC(),
+	}
+	if err := s.actionTokens.Create(ctx, token); err != nil {
+		return fmt.Errorf("store reset token: %w", err)
+	}
+
+	msg := notify.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Use this token to reset your password: %s\n\nIt expires at %s. If you did not ask for a reset, ignore this message.",
+			rawToken, token.ExpiresAt.Format(time.RFC1123),
+		),
+	}
+	if err := s.notifier.Send(ctx, msg); err != nil {
+		// Report success anyway; a failure here must not reveal the account
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("failed to send password reset")
+		return nil
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Msg("password reset requested")
+
+	return nil
+}
+
+// ResetPassword redeems a reset token, sets the new password and revokes
+// every existing session of the user.
+func (s *userService) ResetPassword(ctx context.Context, rawToken, newPassword string) error {
+	// Check the new password before redeeming, so a rejected password does
+	// not use up the token
+	pending, err := s.actionTokens.Get(ctx, model.PurposePasswordReset, hashToken(rawToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("find reset token: %w", err)
+	}
+
+	user, err := s.repo.GetByID(ctx, pending.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("find user: %w", err)
+	}
+
+	if err := s.opts.PasswordPolicy.Check(newPassword, user.Email, user.Name); err != nil {
+		return err
+	}
+
+	if _, err := s.actionTokens.Consume(ctx, model.PurposePasswordReset, pending.TokenHash); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("consume reset token: %w", err)
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	if err := s.repo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+
+	if err := s.cache.Delete(ctx, user.ID); err != nil {
+		log.Warn().Err(err).Msg("failed to invalidate cache")
+	}
+
+	// Whoever held the old password must not keep a session
+	if err := s.RevokeSessions(ctx, user.ID); err != nil {
+		return err
+	}
+
+	// The owner proved control of the account, so lift any lockout
+	if err := s.guard.Unlock(ctx, user.Email); err != nil {
+		log.Warn().Err(err).Msg("failed to clear login lockout")
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Msg("password reset")
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/model"
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+var resetTokenPattern = regexp.MustCompile(`reset your password: (\S+)`)
+
+const newPassword = "new-correct-horse"
+
+func TestResetPasswordTokensAreSingleUse(t *testing.T) {
+	tests := []struct {
+		name string
+		// redeem requests reset tokens and redeems them, returning the
+		// result of the redemption under test
+		redeem  func(t *testing.T, svc *testService) error
+		wantErr error
+		// wantReset expects the password to end up as newPassword
+		wantReset bool
+	}{
+		{
+			name: "first use",
+			redeem: func(t *testing.T, svc *testService) error {
+				return svc.ResetPassword(context.Background(), requestReset(t, svc), newPassword)
+			},
+			wantReset: true,
+		},
+		{
+			name: "second use",
+			redeem: func(t *testing.T, svc *testService) error {
+				token := requestReset(t, svc)
+				if err := svc.ResetPassword(context.Background(), token, newPassword); err != nil {
+					t.Fatal(err)
+				}
+				return svc.ResetPassword(context.Background(), token, "another-correct-horse")
+			},
+			wantErr:   ErrInvalidResetToken,
+			wantReset: true,
+		},
+		{
+			name: "superseded by a newer token",
+			redeem: func(t *testing.T, svc *testService) error {
+				older := requestReset(t, svc)
+				requestReset(t, svc)
+				return svc.ResetPassword(context.Background(), older, newPassword)
+			},
+			wantErr: ErrInvalidResetToken,
+		},
+		{
+			name: "expired",
+			redeem: func(t *testing.T, svc *testService) error {
+				svc.opts.PasswordResetTTL = -time.Minute
+				return svc.ResetPassword(context.Background(), requestReset(t, svc), newPassword)
+			},
+			wantErr: ErrInvalidResetToken,
+		},
+		{
+			name: "after a rejected password",
+			redeem: func(t *testing.T, svc *testService) error {
+				// A password the policy rejects does not use up the token
+				token := requestReset(t, svc)
+				if err := svc.ResetPassword(context.Background(), token, "short"); !errors.Is(err, ErrWeakPassword) {
+					t.Fatalf("err = %v, want %v", err, ErrWeakPassword)
+				}
+				return svc.ResetPassword(context.Background(), token, newPassword)
+			},
+			wantReset: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t)
+			user := svc.users.add(t, model.User{Email: "reset@example.com", Name: "Reset", Role: model.RoleUser, Active: true})
+
+			err := tt.redeem(t, svc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, _ := svc.users.GetByID(context.Background(), user.ID)
+			reset, _ := svc.hasher.Verify(stored.Password, newPassword)
+			if reset != tt.wantReset {
+				t.Errorf("password reset = %t, want %t", reset, tt.wantReset)
+			}
+		})
+	}
+}
+
+func TestResetPasswordConcurrentRedemption(t *testing.T) {
+	const racers = 8
+	ctx := context.Background()
+	svc := newTestService(t)
+	svc.users.add(t, model.User{Email: "reset@example.com", Name: "Reset", Role: model.RoleUser, Active: true})
+	token := requestReset(t, svc)
+
+	errs := make([]error, racers)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = svc.ResetPassword(ctx, token, newPassword)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrInvalidResetToken):
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d redemptions succeeded, want exactly 1", succeeded)
+	}
+}
+
+// requestReset asks for a reset of reset@example.com and returns the token
+// sent.
+func requestReset(t *testing.T, svc *testService) string {
+	t.Helper()
+	if err := svc.ForgotPassword(context.Background(), "reset@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	return svc.notifier.token(t, resetTokenPattern)
+}
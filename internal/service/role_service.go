@@ -0,0 +1,194 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrBuiltInRole is returned when trying to delete a role the application relies on.
+var ErrBuiltInRole = newError("built_in_role", http.StatusConflict, codes.FailedPrecondition, "built-in role cannot be deleted")
+
+// ErrDefaultRole is returned when unassigning the user role from an account
+// whose primary role it is. It also matches repository.ErrPrimaryRole.
+var ErrDefaultRole = newError("default_role", http.StatusConflict, codes.FailedPrecondition, "the default role cannot be unassigned from an account whose primary role it is")
+
+// ErrInvalidName is returned for role or permission names that do not match
+// the naming rules. It also matches repository.ErrInvalidInput.
+var ErrInvalidName = newError("invalid_name", http.StatusBadRequest, codes.InvalidArgument, "invalid role or permission name")
+
+var (
+	roleNamePattern       = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,19}$`)
+	permissionNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*:[a-z][a-z0-9_-]*$`)
+)
+
+// RoleService defines the business operations for roles and permissions.
+// Changes to a user's roles take effect the next time they obtain a token.
+// Unassigning a user's primary role demotes them to the default user role.
+type RoleService interface {
+	ListRoles(ctx context.Context) ([]model.RoleDefinition, error)
+	GetRole(ctx context.Context, name model.Role) (*model.RoleDefinition, error)
+	CreateRole(ctx context.Context, req model.CreateRoleRequest) (*model.RoleDefinition, error)
+	SetRolePermissions(ctx context.Context, name model.Role, req model.SetRolePermissionsRequest) (*model.RoleDefinition, error)
+	DeleteRole(ctx context.Context, name model.Role) error
+
+	ListPermissions(ctx context.Context) ([]model.PermissionDefinition, error)
+	CreatePermission(ctx context.Context, req model.CreatePermissionRequest) (*model.PermissionDefinition, error)
+
+	ListUserRoles(ctx context.Context, userID uuid.UUID) ([]model.Role, error)
+	AssignRole(ctx context.Context, userID uuid.UUID, name model.Role) error
+	UnassignRole(ctx context.Context, userID uuid.UUID, name model.Role) error
+}
+
+type roleService struct {
+	roles repository.RoleRepository
+	users repository.UserRepository
+	cache repository.UserCache
+}
+
+// NewRoleService creates a new role service. cache is invalidated when a
+// user's primary role changes.
+func NewRoleService(roles repository.RoleRepository, users repository.UserRepository, cache repository.UserCache) RoleService {
+	return &roleService{roles: roles, users: users, cache: cache}
+}
+
+func (s *roleService) ListRoles(ctx context.Context) ([]model.RoleDefinition, error) {
+	return s.roles.ListRoles(ctx)
+}
+
+func (s *roleService) GetRole(ctx context.Context, name model.Role) (*model.RoleDefinition, error) {
+	return s.roles.GetRole(ctx, name)
+}
+
+func (s *roleService) CreateRole(ctx context.Context, req model.CreateRoleRequest) (*model.RoleDefinition, error) {
+	if !roleNamePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidName, repository.ErrInvalidInput)
+	}
+
+	perms, err := toPermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &model.RoleDefinition{
+		Name:        model.Role(req.Name),
+		Description: req.Description,
+		Permissions: perms,
+	}
+
+	if err := s.roles.CreateRole(ctx, role); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("role", req.Name).Msg("role created")
+
+	return role, nil
+}
+
+func (s *roleService) SetRolePermissions(ctx context.Context, name model.Role, req model.SetRolePermissionsRequest) (*model.RoleDefinition, error) {
+	perms, err := toPermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.roles.SetRolePermissions(ctx, name, perms); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("role", string(name)).Strs("permissions", req.Permissions).Msg("role permissions updated")
+
+	return s.roles.GetRole(ctx, name)
+}
+
+func (s *roleService) DeleteRole(ctx context.Context, name model.Role) error {
+	if (model.RoleDefinition{Name: name}).IsBuiltIn() {
+		return ErrBuiltInRole
+	}
+
+	if err := s.roles.DeleteRole(ctx, name); err != nil {
+		return err
+	}
+
+	log.Info().Str("role", string(name)).Msg("role deleted")
+
+	return nil
+}
+
+func (s *roleService) ListPermissions(ctx context.Context) ([]model.PermissionDefinition, error) {
+	return s.roles.ListPermissions(ctx)
+}
+
+func (s *roleService) CreatePermission(ctx context.Context, req model.CreatePermissionRequest) (*model.PermissionDefinition, error) {
+	if !permissionNamePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidName, repository.ErrInvalidInput)
+	}
+
+	perm := &model.PermissionDefinition{
+		Name:        model.Permission(req.Name),
+		Description: req.Description,
+	}
+
+	if err := s.roles.CreatePermission(ctx, perm); err != nil {
+		return nil, err
+	}
+
+	return perm, nil
+}
+
+func (s *roleService) ListUserRoles(ctx context.Context, userID uuid.UUID) ([]model.Role, error) {
+	if _, err := s.users.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.roles.ListUserRoles(ctx, userID)
+}
+
+func (s *roleService) AssignRole(ctx context.Context, userID uuid.UUID, name model.Role) error {
+	if _, err := s.users.GetByID(ctx, userID); err != nil {
+		return fmt.Errorf("find user: %w", err)
+	}
+
+	if err := s.roles.AssignRole(ctx, userID, name); err != nil {
+		return err
+	}
+
+	log.Info().Str("user_id", userID.String()).Str("role", string(name)).Msg("role assigned")
+
+	return nil
+}
+
+func (s *roleService) UnassignRole(ctx context.Context, userID uuid.UUID, name model.Role) error {
+	if err := s.roles.UnassignRole(ctx, userID, name); err != nil {
+		if errors.Is(err, repository.ErrPrimaryRole) {
+			return fmt.Errorf("%w: %w", ErrDefaultRole, err)
+		}
+		return err
+	}
+
+	// The primary role may have been reset
+	if err := s.cache.Delete(ctx, userID); err != nil {
+		log.Warn().Err(err).Msg("failed to invalidate cache")
+	}
+
+	log.Info().Str("user_id", userID.String()).Str("role", string(name)).Msg("role unassigned")
+
+	return nil
+}
+
+func toPermissions(names []string) ([]model.Permission, error) {
+	perms := make([]model.Permission, 0, len(names))
+	for _, n := range names {
+		if !permissionNamePattern.MatchString(n) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidName, repository.ErrInvalidInput)
+		}
+		perms = append(perms, model.Permission(n))
+	}
+	return perms, nil
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/repository"
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRoleAssignmentsDecideTokenPermissions(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	roles := NewRoleService(svc.roles, svc.users, repository.NewUserCache(nil, time.Minute))
+	svc.roles.define("auditor", model.PermRolesRead)
+
+	admin, err := svc.CreateAdmin(ctx, model.CreateUserRequest{Email: "admin@example.com", Name: "Admin", Password: "correct-horse-battery"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allAdmin := []string{"me:read", "roles:read", "roles:write", "sessions:revoke", "users:delete", "users:read", "users:write"}
+	steps := []struct {
+		name string
+		// change alters the admin's roles before the next login
+		change    func() error
+		wantErr   error
+		wantRole  model.Role
+		wantRoles []model.Role
+		wantPerms []string
+	}{
+		{
+			name:      "created with its primary role",
+			change:    func() error { return nil },
+			wantRole:  model.RoleAdmin,
+			wantRoles: []model.Role{model.RoleAdmin},
+			wantPerms: allAdmin,
+		},
+		{
+			name:      "assign a role",
+			change:    func() error { return roles.AssignRole(ctx, admin.ID, "auditor") },
+			wantRole:  model.RoleAdmin,
+			wantRoles: []model.Role{model.RoleAdmin, "auditor"},
+			wantPerms: allAdmin,
+		},
+		{
+			name:      "unassign the primary role",
+			change:    func() error { return roles.UnassignRole(ctx, admin.ID, model.RoleAdmin) },
+			wantRole:  model.RoleUser,
+			wantRoles: []model.Role{"auditor", model.RoleUser},
+			wantPerms: []string{"me:read", "roles:read"},
+		},
+		{
+			name:      "unassign a secondary role",
+			change:    func() error { return roles.UnassignRole(ctx, admin.ID, "auditor") },
+			wantRole:  model.RoleUser,
+			wantRoles: []model.Role{model.RoleUser},
+			wantPerms: []string{"me:read"},
+		},
+		{
+			name:      "unassign the default primary role",
+			change:    func() error { return roles.UnassignRole(ctx, admin.ID, model.RoleUser) },
+			wantErr:   ErrDefaultRole,
+			wantRole:  model.RoleUser,
+			wantRoles: []model.Role{model.RoleUser},
+			wantPerms: []string{"me:read"},
+		},
+		{
+			name:      "unassign a role not held",
+			change:    func() error { return roles.UnassignRole(ctx, admin.ID, model.RoleAdmin) },
+			wantErr:   repository.ErrNotFound,
+			wantRole:  model.RoleUser,
+			wantRoles: []model.Role{model.RoleUser},
+			wantPerms: []string{"me:read"},
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := step.change(); !errors.Is(err, step.wantErr) {
+				t.Fatalf("err = %v, want %v", err, step.wantErr)
+			}
+
+			assigned, err := roles.ListUserRoles(ctx, admin.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(assigned, step.wantRoles) {
+				t.Errorf("roles = %v, want %v", assigned, step.wantRoles)
+			}
+
+			resp, err := svc.Login(ctx, model.LoginRequest{Email: "admin@example.com", Password: "correct-horse-battery"}, "192.0.2.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := svc.issuer.Verify(resp.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Role != string(step.wantRole) || resp.User.Role != step.wantRole {
+				t.Errorf("role = %s in token and %s on the account, want %s", claims.Role, resp.User.Role, step.wantRole)
+			}
+			if !reflect.DeepEqual(claims.Permissions, step.wantPerms) {
+				t.Errorf("token permissions = %v, want %v", claims.Permissions, step.wantPerms)
+			}
+		})
+	}
+
+	if got := AsError(roles.UnassignRole(ctx, admin.ID, model.RoleUser)).HTTPStatus; got != http.StatusConflict {
+		t.Errorf("unassigning the default primary role: status %d, want %d", got, http.StatusConflict)
+	}
+}
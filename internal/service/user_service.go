@@ -1,61 +1,232 @@
 package service
 
 import (
+	"Go-Microservice-Template/internal/auth"
 	"Go-Microservice-Template/internal/model"
+	"Go-Microservice-Template/internal/notify"
 	"Go-Microservice-Template/internal/repository"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
-	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
 )
 
+// Errors returned by the refresh token flow.
+var (
+	ErrInvalidRefreshToken = newError("invalid_refresh_token", http.StatusUnauthorized, codes.Unauthenticated, "invalid refresh token")
+	ErrRefreshTokenReused  = newError("refresh_token_reused", http.StatusUnauthorized, codes.Unauthenticated, "refresh token reuse detected")
+)
+
+// ErrIncorrectPassword is returned when a caller's current password does
+// not match, for example when changing it.
+var ErrIncorrectPassword = newError("incorrect_password", http.StatusForbidden, codes.PermissionDenied, "current password is incorrect")
+
+// ErrInvalidCredentials is returned by Login for an unknown email or a wrong
+// password alike, so that the response does not reveal which one it was.
+var ErrInvalidCredentials = newError("invalid_credentials", http.StatusUnauthorized, codes.Unauthenticated, "invalid credentials")
+
+// ErrEmailTaken is returned when an email address belongs to another account.
+// It also matches repository.ErrDuplicate.
+var ErrEmailTaken = newError("email_taken", http.StatusConflict, codes.AlreadyExists, "email already registered")
+
+// ErrEmailNotVerified is returned by Login when verification is required and
+// the account has not verified its email address yet.
+var ErrEmailNotVerified = newError("email_not_verified", http.StatusForbidden, codes.PermissionDenied, "email address not verified")
+
+// AccountOptions configures the out-of-band account flows.
+type AccountOptions struct {
+	// PasswordResetTTL bounds how long a password reset token stays valid.
+	PasswordResetTTL time.Duration
+	// EmailVerificationTTL bounds how long a verification token stays valid.
+	EmailVerificationTTL time.Duration
+	// VerifyURL is the link sent in verification messages; the token is
+	// appended as a query parameter.
+	VerifyURL string
+	// RequireVerifiedEmail blocks Login until the email is verified.
+	RequireVerifiedEmail bool
+	// MFAIssuer names the service in authenticator apps.
+	MFAIssuer string
+	// MFAChallengeTTL bounds the time between the password and code steps.
+	MFAChallengeTTL time.Duration
+	// PasswordPolicy is enforced whenever a password is chosen.
+	PasswordPolicy PasswordPolicy
+}
+
 // UserService defines the business operations for users.
 type UserService interface {
 	Register(ctx context.Context, req model.CreateUserRequest) (*model.User, error)
-	Login(ctx context.Context, req model.LoginRequest, jwtSecret string, expHours int) (*model.LoginResponse, error)
+	CreateAdmin(ctx context.Context, req model.CreateUserRequest) (*model.User, error)
+	Login(ctx context.Context, req model.LoginRequest, clientIP string) (*model.LoginResponse, error)
+	Refresh(ctx context.Context, refreshToken string) (*model.LoginResponse, error)
+	Logout(ctx context.Context, userID uuid.UUID, jti string, expiresAt time.Time, refreshToken string) error
+	RevokeSessions(ctx context.Context, userID uuid.UUID) error
+	UnlockAccount(ctx context.Context, userID uuid.UUID) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+	VerifyMFA(ctx context.Context, mfaToken, code, clientIP string) (*model.LoginResponse, error)
+	EnrollMFA(ctx context.Context, userID uuid.UUID) (*model.MFAEnrollResponse, error)
+	EnableMFA(ctx context.Context, userID uuid.UUID, code string) (*model.MFARecoveryCodesResponse, error)
+	DisableMFA(ctx context.Context, userID uuid.UUID, code string) error
+	BeginOIDCLogin(ctx context.Context, provider string) (*model.OIDCAuthRequest, error)
+	CompleteOIDCLogin(ctx context.Context, provider, code, verifier, nonce string) (*model.LoginResponse, error)
+	IsTokenRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
 	Update(ctx context.Context, id uuid.UUID, req model.UpdateUserRequest) (*model.User, error)
+	ChangePassword(ctx context.Context, id uuid.UUID, req model.ChangePasswordRequest) (*model.LoginResponse, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	List(ctx context.Context, params model.ListParams) (*model.ListResponse[model.User], error)
 }
 
 type userService struct {
-	repo  repository.UserRepository
-	cache repository.UserCache
+	repo          repository.UserRepository
+	cache         repository.UserCache
+	refreshTokens repository.RefreshTokenRepository
+	denylist      repository.TokenDenylist
+	roles         repository.RoleRepository
+	actionTokens  repository.ActionTokenRepository
+	mfa           repository.MFARepository
+	identities    repository.IdentityRepository
+	apiKeys       repository.APIKeyRepository
+	oidc          map[string]*auth.OIDCProvider
+	issuer        *auth.Issuer
+	hasher        auth.PasswordHasher
+	mfaKeys       *auth.MFAKeys
+	guard         *LoginGuard
+	notifier      notify.Notifier
+	opts          AccountOptions
+
+	// dummyHash is verified against for unknown emails, so that a login
+	// takes as long whether or not the account exists.
+	dummyHash string
 }
 
 // NewUserService creates a new user service with repository and cache dependencies.
-func NewUserService(repo repository.UserRepository, cache repository.UserCache) UserService {
-	return &userService{repo: repo, cache: cache}
+// Access and refresh tokens are issued through issuer; failed logins are
+// throttled by guard. Passwords are hashed with hasher. Password reset and verification tokens are sent
+// through notifier. oidcProviders enables external login, keyed by name.
+// apiKeys are revoked together with the user's sessions.
+func NewUserService(
+	repo repository.UserRepository,
+	cache repository.UserCache,
+	refreshTokens repository.RefreshTokenRepository,
+	denylist repository.TokenDenylist,
+	roles repository.RoleRepository,
+	actionTokens repository.ActionTokenRepository,
+	mfa repository.MFARepository,
+	identities repository.IdentityRepository,
+	apiKeys repository.APIKeyRepository,
+	oidcProviders []*auth.OIDCProvider,
+	issuer *auth.Issuer,
+	hasher auth.PasswordHasher,
+	mfaKeys *auth.MFAKeys,
+	guard *LoginGuard,
+	notifier notify.Notifier,
+	opts AccountOptions,
+) UserService {
+	providers := make(map[string]*auth.OIDCProvider, len(oidcProviders))
+	for _, p := range oidcProviders {
+		providers[p.Name()] = p
+	}
+
+	dummyHash, err := hasher.Hash(uuid.NewString())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to hash the dummy password, unknown emails are answered faster")
+	}
+
+	return &userService{
+		repo:          repo,
+		cache:         cache,
+		refreshTokens: refreshTokens,
+		denylist:      denylist,
+		roles:         roles,
+		actionTokens:  actionTokens,
+		mfa:           mfa,
+		identities:    identities,
+		apiKeys:       apiKeys,
+		oidc:          providers,
+		issuer:        issuer,
+		hasher:        hasher,
+		mfaKeys:       mfaKeys,
+		dummyHash:     dummyHash,
+		guard:         guard,
+		notifier:      notifier,
+		opts:          opts,
+	}
 }
 
 func (s *userService) Register(ctx context.Context, req model.CreateUserRequest) (*model.User, error) {
-	// Check if email already exists
-	existing, _ := s.repo.GetByEmail(ctx, req.Email)
-	if existing != nil {
-		return nil, repository.ErrDuplicate
+	user, err := s.createUser(ctx, req, model.RoleUser, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sendVerification(ctx, user); err != nil {
+		// The account exists either way; the user can ask for a new token
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("failed to send email verification")
+	}
+
+	return user, nil
+}
+
+// CreateAdmin creates an account holding the admin role, for bootstrapping a
+// deployment. The email is taken as verified, so nothing is sent.
+func (s *userService) CreateAdmin(ctx context.Context, req model.CreateUserRequest) (*model.User, error) {
+	verifiedAt := time.Now().UTC()
+	user, err := s.createUser(ctx, req, model.RoleAdmin, &verifiedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Msg("admin account created")
+
+	return user, nil
+}
+
+// createUser normalizes the email, enforces the password policy and stores
+// a new active account with the given primary role.
+func (s *userService) createUser(ctx context.Context, req model.CreateUserRequest, role model.Role, verifiedAt *time.Time) (*model.User, error) {
+	email, err := NormalizeEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+	req.Email = email
+
+	if err := s.opts.PasswordPolicy.Check(req.Password, req.Email, req.Name); err != nil {
+		return nil, err
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(req.Password)
 	if err != nil {
 		return nil, fmt.Errorf("hash password: %w", err)
 	}
 
 	user := &model.User{
-		Email:    req.Email,
-		Name:     req.Name,
-		Password: string(hashedPassword),
-		Role:     model.RoleUser,
-		Active:   true,
+		Email:           req.Email,
+		Name:            req.Name,
+		Password:        hashedPassword,
+		Role:            role,
+		Active:          true,
+		EmailVerifiedAt: verifiedAt,
 	}
 
+	// The unique index on the email is the only uniqueness check, so of two
+	// concurrent registrations exactly one succeeds
 	if err := s.repo.Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, fmt.Errorf("%w: %w", ErrEmailTaken, err)
+		}
 		return nil, fmt.Errorf("create user: %w", err)
 	}
 
@@ -67,41 +238,305 @@ func (s *userService) Register(ctx context.Context, req model.CreateUserRequest)
 	return user, nil
 }
 
-func (s *userService) Login(ctx context.Context, req model.LoginRequest, jwtSecret string, expHours int) (*model.LoginResponse, error) {
+// Login verifies credentials and starts a new session. Failures are counted
+// per email and per clientIP; once locked, a *LockedError is returned
+// without checking the password. If the account has MFA enabled, an
+// *MFARequiredError carrying a challenge token is returned instead.
+func (s *userService) Login(ctx context.Context, req model.LoginRequest, clientIP string) (*model.LoginResponse, error) {
+	email, err := NormalizeEmail(req.Email)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	req.Email = email
+
+	if err := s.guard.Check(ctx, req.Email, clientIP); err != nil {
+		return nil, err
+	}
+
 	user, err := s.repo.GetByEmail(ctx, req.Email)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
-			return nil, fmt.Errorf("invalid credentials")
+			// Spend the time of a password check, and count unknown emails
+			// too, so that probing for accounts is neither timed nor cheap
+			_, _ = s.hasher.Verify(s.dummyHash, req.Password)
+			s.guard.Failure(ctx, req.Email, clientIP)
+			return nil, ErrInvalidCredentials
 		}
 		return nil, fmt.Errorf("find user: %w", err)
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+	ok, err := s.hasher.Verify(user.Password, req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("verify password: %w", err)
+	}
+	if !ok {
+		s.guard.Failure(ctx, req.Email, clientIP)
+		return nil, ErrInvalidCredentials
+	}
+
+	s.guard.Success(ctx, req.Email)
+	s.upgradePasswordHash(ctx, user, req.Password)
+
+	return s.completeLogin(ctx, user)
+}
+
+// completeLogin applies the checks shared by every first-factor login, then
+// starts a session for user.
+func (s *userService) completeLogin(ctx context.Context, user *model.User) (*model.LoginResponse, error) {
+	if s.opts.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
+	// Accounts with a second factor get a challenge instead of a session
+	if err := s.mfaChallenge(ctx, user); err != nil {
+		return nil, err
+	}
+
+	// Every login starts a new refresh token family
+	resp, refresh, err := s.newSession(ctx, user, uuid.New())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokens.Create(ctx, refresh); err != nil {
+		return nil, fmt.Errorf("store refresh token: %w", err)
+	}
+
+	return resp, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and rotates the
+// refresh token. Presenting a token that has already been rotated is treated
+// as theft and revokes every token in its family.
+func (s *userService) Refresh(ctx context.Context, refreshToken string) (*model.LoginResponse, error) {
+	current, err := s.refreshTokens.GetByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("find refresh token: %w", err)
+	}
+
+	if current.RevokedAt != nil {
+		if current.ReplacedBy != nil {
+			return nil, s.revokeReusedFamily(ctx, current)
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.repo.GetByID(ctx, current.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("find user: %w", err)
+	}
+
+	resp, next, err := s.newSession(ctx, user, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokens.Rotate(ctx, current.ID, next); err != nil {
+		if errors.Is(err, repository.ErrTokenRevoked) {
+			// Lost a race against another rotation of the same token
+			return nil, s.revokeReusedFamily(ctx, current)
+		}
+		return nil, fmt.Errorf("rotate refresh token: %w", err)
+	}
+
+	return resp, nil
+}
+
+// Logout revokes the presented access token and, if given, the refresh
+// token family it was issued with.
+func (s *userService) Logout(ctx context.Context, userID uuid.UUID, jti string, expiresAt time.Time, refreshToken string) error {
+	if jti != "" {
+		if err := s.denylist.RevokeToken(ctx, jti, expiresAt); err != nil {
+			return fmt.Errorf("revoke access token: %w", err)
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	token, err := s.refreshTokens.GetByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("find refresh token: %w", err)
+	}
+
+	// Never let a caller revoke someone else's session
+	if token.UserID != userID {
+		return nil
+	}
+
+	if err := s.refreshTokens.RevokeFamily(ctx, token.FamilyID); err != nil {
+		return fmt.Errorf("revoke token family: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeSessions invalidates every access and refresh token issued to the
+// user up to now, and every API key, which a stolen session could have
+// created to outlive it.
+func (s *userService) RevokeSessions(ctx context.Context, userID uuid.UUID) error {
+	if _, err := s.repo.GetByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.denylist.RevokeUserTokens(ctx, userID, time.Now()); err != nil {
+		return fmt.Errorf("revoke access tokens: %w", err)
+	}
+
+	if err := s.refreshTokens.RevokeAllForUser(ctx, userID); err != nil {
+		return fmt.Errorf("revoke refresh tokens: %w", err)
+	}
+
+	if err := s.apiKeys.RevokeAllForUser(ctx, userID); err != nil {
+		return fmt.Errorf("revoke api keys: %w", err)
+	}
+
+	log.Info().Str("user_id", userID.String()).Msg("revoked all sessions")
+
+	return nil
+}
+
+// UnlockAccount lifts a login lockout on the user's email.
+func (s *userService) UnlockAccount(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.Unlock(ctx, user.Email); err != nil {
+		return fmt.Errorf("unlock account: %w", err)
+	}
+
+	log.Info().Str("user_id", userID.String()).Msg("account unlocked")
+
+	return nil
+}
+
+// IsTokenRevoked reports whether an access token has been logged out or
+// issued no later than the user's sessions were revoked.
+func (s *userService) IsTokenRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	if jti != "" {
+		revoked, err := s.denylist.IsTokenRevoked(ctx, jti)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	before, err := s.denylist.UserTokensRevokedBefore(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return !before.IsZero() && !issuedAt.After(before), nil
+}
+
+func (s *userService) revokeReusedFamily(ctx context.Context, token *model.RefreshToken) error {
+	log.Warn().
+		Str("user_id", token.UserID.String()).
+		Str("family_id", token.FamilyID.String()).
+		Msg("refresh token reuse detected, revoking family")
+
+	if err := s.refreshTokens.RevokeFamily(ctx, token.FamilyID); err != nil {
+		return fmt.Errorf("revoke token family: %w", err)
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// newSession signs an access token for user and generates a refresh token in
+// the given family. The returned refresh token record is not yet persisted.
+func (s *userService) newSession(ctx context.Context, user *model.User, familyID uuid.UUID) (*model.LoginResponse, *model.RefreshToken, error) {
+	now := time.Now()
+
+	// Permissions are embedded so that authorization needs no lookup per request
+	perms, err := s.roles.UserPermissions(ctx, user.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolve permissions: %w", err)
+	}
+	permNames := make([]string, len(perms))
+	for i, p := range perms {
+		permNames[i] = string(p)
 	}
 
 	// Generate JWT
-	expiresAt := time.Now().Add(time.Duration(expHours) * time.Hour)
-	claims := jwt.MapClaims{
-		"sub":   user.ID.String(),
-		"email": user.Email,
-		"role":  string(user.Role),
-		"exp":   expiresAt.Unix(),
-		"iat":   time.Now().Unix(),
+	tokenStr, expiresAt, err := s.issuer.Issue(user.ID, user.Email, string(user.Role), permNames)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sign token: %w", err)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString([]byte(jwtSecret))
+	// Generate refresh token
+	rawRefresh, err := generateToken()
 	if err != nil {
-		return nil, fmt.Errorf("sign token: %w", err)
+		return nil, nil, fmt.Errorf("generate refresh token: %w", err)
+	}
+
+	refresh := &model.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(rawRefresh),
+		ExpiresAt: now.Add(s.issuer.RefreshTTL()).UTC(),
 	}
 
 	return &model.LoginResponse{
-		Token:     tokenStr,
-		ExpiresAt: expiresAt,
-		User:      *user,
-	}, nil
+		Token:            tokenStr,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     rawRefresh,
+		RefreshExpiresAt: refresh.ExpiresAt,
+		User:             *user,
+	}, refresh, nil
+}
+
+// upgradePasswordHash rehashes a just-verified password when its stored hash
+// uses an outdated algorithm or parameters. Failures only cost the upgrade,
+// never the login.
+func (s *userService) upgradePasswordHash(ctx context.Context, user *model.User, password string) {
+	if !s.hasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", user.ID.String()).Msg("failed to rehash password")
+		return
+	}
+
+	if err := s.repo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		log.Warn().Err(err).Str("user_id", user.ID.String()).Msg("failed to store rehashed password")
+		return
+	}
+
+	user.Password = hashedPassword
+	log.Info().Str("user_id", user.ID.String()).Msg("password hash upgraded")
+}
+
+// generateToken returns a URL-safe random token with 256 bits of entropy.
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 digest used to store opaque tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 func (s *userService) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
@@ -133,14 +568,27 @@ func (s *userService) Update(ctx context.Context, id uuid.UUID, req model.Update
 	}
 
 	// Apply partial updates
+	var emailChanged bool
 	if req.Email != nil {
-		user.Email = *req.Email
+		email, err := NormalizeEmail(*req.Email)
+		if err != nil {
+			return nil, err
+		}
+		if email != user.Email {
+			// A new address has to be proven again
+			emailChanged = true
+			user.Email = email
+			user.EmailVerifiedAt = nil
+		}
 	}
 	if req.Name != nil {
 		user.Name = *req.Name
 	}
 
 	if err := s.repo.Update(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, fmt.Errorf("%w: %w", ErrEmailTaken, err)
+		}
 		return nil, err
 	}
 
@@ -149,9 +597,67 @@ func (s *userService) Update(ctx context.Context, id uuid.UUID, req model.Update
 		log.Warn().Err(err).Msg("failed to invalidate cache")
 	}
 
+	if emailChanged {
+		if err := s.sendVerification(ctx, user); err != nil {
+			log.Error().Err(err).Str("user_id", user.ID.String()).Msg("failed to send email verification")
+		}
+	}
+
 	return user, nil
 }
 
+// ChangePassword replaces the user's password after checking the current
+// one. Every existing session is revoked and a fresh one is returned, so the
+// caller stays signed in while anyone else holding a token is signed out.
+func (s *userService) ChangePassword(ctx context.Context, id uuid.UUID, req model.ChangePasswordRequest) (*model.LoginResponse, error) {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.hasher.Verify(user.Password, req.CurrentPassword)
+	if err != nil {
+		return nil, fmt.Errorf("verify password: %w", err)
+	}
+	if !ok {
+		return nil, ErrIncorrectPassword
+	}
+
+	if err := s.opts.PasswordPolicy.Check(req.NewPassword, user.Email, user.Name); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
+
+	if err := s.repo.UpdatePassword(ctx, id, hashedPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.cache.Delete(ctx, id); err != nil {
+		log.Warn().Err(err).Msg("failed to invalidate cache")
+	}
+
+	if err := s.RevokeSessions(ctx, id); err != nil {
+		return nil, err
+	}
+
+	resp, refresh, err := s.newSession(ctx, user, uuid.New())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokens.Create(ctx, refresh); err != nil {
+		return nil, fmt.Errorf("store refresh token: %w", err)
+	}
+
+	log.Info().Str("user_id", id.String()).Msg("password changed")
+
+	return resp, nil
+}
+
 func (s *userService) Delete(ctx context.Context, id uuid.UUID) error {
 	if err := s.repo.Delete(ctx, id); err != nil {
 		return err
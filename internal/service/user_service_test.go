@@ -0,0 +1,424 @@
+package service
+
+import (
+	"Go-Microservice-Template/internal/auth"
+	"Go-Microservice-Template/internal/model"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRegisterConcurrentDuplicate(t *testing.T) {
+	const racers = 2
+	svc := newTestService(t)
+
+	// Hold every insert until all racers have passed the checks before it,
+	// so that only the unique index can decide
+	var arrived sync.WaitGroup
+	arrived.Add(racers)
+	svc.users.beforeCreate = func(*model.User) {
+		arrived.Done()
+		arrived.Wait()
+	}
+
+	statuses := make([]int, racers)
+	var wg sync.WaitGroup
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Differently cased spellings of the same address collide too
+			email := "Racer@Example.com"
+			if i%2 == 1 {
+				email = "racer@example.COM"
+			}
+			_, err := svc.Register(context.Background(), model.CreateUserRequest{
+				Email:    email,
+				Name:     "Racer",
+				Password: "correct-horse-battery",
+			})
+			switch {
+			case err == nil:
+				statuses[i] = http.StatusCreated
+			case errors.Is(err, ErrEmailTaken):
+				statuses[i] = AsError(err).HTTPStatus
+			default:
+				t.Errorf("racer %d: unexpected error %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	counts := make(map[int]int)
+	for _, s := range statuses {
+		counts[s]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != racers-1 {
+		t.Errorf("statuses = %v, want exactly one %d and %d × %d", statuses, http.StatusCreated, racers-1, http.StatusConflict)
+	}
+	if n := svc.users.count(); n != 1 {
+		t.Errorf("%d users stored, want 1", n)
+	}
+}
+
+func TestLoginChecksAPasswordForUnknownEmails(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	hash, err := svc.hasher.Hash("correct-horse-battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.users.add(t, model.User{Email: "known@example.com", Name: "Known", Password: hash, Role: model.RoleUser, Active: true})
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{"unknown email", "unknown@example.com", "correct-horse-battery", ErrInvalidCredentials},
+		{"wrong password", "known@example.com", "wrong-horse-battery", ErrInvalidCredentials},
+		{"right password", "known@example.com", "correct-horse-battery", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := svc.hasher.verified.Load()
+
+			_, err := svc.Login(ctx, model.LoginRequest{Email: tt.email, Password: tt.password}, "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if n := svc.hasher.verified.Load() - before; n != 1 {
+				t.Errorf("%d passwords verified, want 1", n)
+			}
+		})
+	}
+}
+
+func TestRevokeSessionsCutsOffTokensIssuedUpToIt(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	user := svc.users.add(t, model.User{Email: "user@example.com", Name: "User", Role: model.RoleUser, Active: true})
+	cutoff := time.Date(2026, 1, 1, 12, 0, 0, 500_000_000, time.UTC)
+	svc.denylist.before[user.ID] = cutoff
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		want     bool
+	}{
+		{"earlier second", cutoff.Add(-time.Second), true},
+		{"same second, before", cutoff.Add(-time.Microsecond), true},
+		{"at the cut-off", cutoff, true},
+		{"same second, after", cutoff.Add(time.Microsecond), false},
+		{"later second", cutoff.Add(time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked, err := svc.IsTokenRevoked(ctx, "", user.ID, tt.issuedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tt.want {
+				t.Errorf("revoked = %v, want %v", revoked, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevokeSessionsKeepsTokensIssuedRightAfter(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	hash, err := svc.hasher.Hash("correct-horse-battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := svc.users.add(t, model.User{Email: "user@example.com", Name: "User", Password: hash, Role: model.RoleUser, Active: true})
+	login := func() *auth.Claims {
+		t.Helper()
+		resp, err := svc.Login(ctx, model.LoginRequest{Email: "user@example.com", Password: "correct-horse-battery"}, "192.0.2.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := svc.issuer.Verify(resp.Token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claims
+	}
+
+	// all three usually fall within one second
+	old := login()
+	if err := svc.RevokeSessions(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	fresh := login()
+
+	for _, tt := range []struct {
+		name   string
+		claims *auth.Claims
+		want   bool
+	}{
+		{"issued before", old, true},
+		{"issued after", fresh, false},
+	} {
+		revoked, err := svc.IsTokenRevoked(ctx, tt.claims.ID, user.ID, tt.claims.IssueTime())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked != tt.want {
+			t.Errorf("%s the revocation: revoked = %v, want %v", tt.name, revoked, tt.want)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	type session struct {
+		svc   *testService
+		user  *model.User
+		login *model.LoginResponse
+	}
+	tests := []struct {
+		name string
+		// use presents the session's refresh token in some way and returns
+		// the result of the last Refresh
+		use     func(t *testing.T, s session) (*model.LoginResponse, error)
+		wantErr error
+		// familyRevoked is whether every token of the login's family,
+		// including those rotated from it, must be revoked afterwards
+		familyRevoked bool
+	}{
+		{
+			name: "rotate",
+			use: func(t *testing.T, s session) (*model.LoginResponse, error) {
+				return s.svc.Refresh(ctx, s.login.RefreshToken)
+			},
+		},
+		{
+			name: "replay of a rotated token",
+			use: func(t *testing.T, s session) (*model.LoginResponse, error) {
+				if _, err := s.svc.Refresh(ctx, s.login.RefreshToken); err != nil {
+					t.Fatal(err)
+				}
+				return s.svc.Refresh(ctx, s.login.RefreshToken)
+			},
+			wantErr:       ErrRefreshTokenReused,
+			familyRevoked: true,
+		},
+		{
+			name: "rotation lost to a concurrent one",
+			use: func(t *testing.T, s session) (*model.LoginResponse, error) {
+				s.svc.refreshTokens.beforeRotate = func(uuid.UUID) {
+					s.svc.refreshTokens.beforeRotate = nil
+					if _, err := s.svc.Refresh(ctx, s.login.RefreshToken); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return s.svc.Refresh(ctx, s.login.RefreshToken)
+			},
+			wantErr:       ErrRefreshTokenReused,
+			familyRevoked: true,
+		},
+		{
+			name: "expired token",
+			use: func(t *testing.T, s session) (*model.LoginResponse, error) {
+				s.svc.refreshTokens.expire(s.login.RefreshToken)
+				return s.svc.Refresh(ctx, s.login.RefreshToken)
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "token revoked by logout",
+			use: func(t *testing.T, s session) (*model.LoginResponse, error) {
+				claims, err := s.svc.issuer.Verify(s.login.Token)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := s.svc.Logout(ctx, s.user.ID, claims.ID, claims.ExpiresAt.Time, s.login.RefreshToken); err != nil {
+					t.Fatal(err)
+				}
+				if revoked, err := s.svc.IsTokenRevoked(ctx, claims.ID, s.user.ID, claims.IssueTime()); err != nil || !revoked {
+					t.Errorf("access token revoked = %v, %v after logout, want true", revoked, err)
+				}
+				return s.svc.Refresh(ctx, s.login.RefreshToken)
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "unknown token",
+			use: func(t *testing.T, s session) (*model.LoginResponse, error) {
+				return s.svc.Refresh(ctx, "not-a-refresh-token")
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t)
+			hash, err := svc.hasher.Hash("correct-horse-battery")
+			if err != nil {
+				t.Fatal(err)
+			}
+			user := svc.users.add(t, model.User{Email: "user@example.com", Name: "User", Password: hash, Role: model.RoleUser, Active: true})
+			login, err := svc.Login(ctx, model.LoginRequest{Email: "user@example.com", Password: "correct-horse-battery"}, "192.0.2.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := session{svc: svc, user: user, login: login}
+
+			resp, err := tt.use(t, s)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			first, err := svc.refreshTokens.GetByHash(ctx, hashToken(login.RefreshToken))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr == nil {
+				if resp.RefreshToken == login.RefreshToken {
+					t.Fatal("refresh token not rotated")
+				}
+				if _, err := svc.issuer.Verify(resp.Token); err != nil {
+					t.Errorf("new access token: %v", err)
+				}
+				next, err := svc.refreshTokens.GetByHash(ctx, hashToken(resp.RefreshToken))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if first.RevokedAt == nil || first.ReplacedBy == nil || *first.ReplacedBy != next.ID {
+					t.Errorf("rotated token revoked at %v, replaced by %v, want replaced by %s", first.RevokedAt, first.ReplacedBy, next.ID)
+				}
+				if next.FamilyID != first.FamilyID {
+					t.Errorf("new token in family %s, want %s", next.FamilyID, first.FamilyID)
+				}
+				return
+			}
+
+			if tt.familyRevoked {
+				for _, token := range svc.refreshTokens.family(first.FamilyID) {
+					if token.RevokedAt == nil {
+						t.Errorf("token %s of the reused family is still valid", token.ID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		req        model.ChangePasswordRequest
+		wantErr    error
+		wantStatus int
+	}{
+		{
+			name:       "wrong current password",
+			req:        model.ChangePasswordRequest{CurrentPassword: "wrong-horse-battery", NewPassword: "new-horse-battery"},
+			wantErr:    ErrIncorrectPassword,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "new password rejected by the policy",
+			req:        model.ChangePasswordRequest{CurrentPassword: "correct-horse-battery", NewPassword: "short"},
+			wantErr:    ErrWeakPassword,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "changed",
+			req:  model.ChangePasswordRequest{CurrentPassword: "correct-horse-battery", NewPassword: "new-horse-battery"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t)
+			hash, err := svc.hasher.Hash("correct-horse-battery")
+			if err != nil {
+				t.Fatal(err)
+			}
+			user := svc.users.add(t, model.User{Email: "user@example.com", Name: "User", Password: hash, Role: model.RoleUser, Active: true})
+			other, err := svc.Login(ctx, model.LoginRequest{Email: "user@example.com", Password: "correct-horse-battery"}, "192.0.2.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := svc.ChangePassword(ctx, user.ID, tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if status := AsError(err).HTTPStatus; status != tt.wantStatus {
+					t.Errorf("status = %d, want %d", status, tt.wantStatus)
+				}
+			}
+
+			// Whatever the outcome, exactly one password logs in
+			wantPassword, oldPassword := tt.req.NewPassword, "correct-horse-battery"
+			if tt.wantErr != nil {
+				wantPassword, oldPassword = oldPassword, tt.req.NewPassword
+			}
+			if _, err := svc.Login(ctx, model.LoginRequest{Email: "user@example.com", Password: wantPassword}, "192.0.2.1"); err != nil {
+				t.Errorf("login with %q: %v", wantPassword, err)
+			}
+			if _, err := svc.Login(ctx, model.LoginRequest{Email: "user@example.com", Password: oldPassword}, "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("login with %q: err = %v, want %v", oldPassword, err, ErrInvalidCredentials)
+			}
+
+			otherClaims, err := svc.issuer.Verify(other.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			otherRevoked, err := svc.IsTokenRevoked(ctx, otherClaims.ID, user.ID, otherClaims.IssueTime())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantErr != nil {
+				if otherRevoked {
+					t.Error("failed change signed out another session")
+				}
+				return
+			}
+
+			// Every other session is signed out
+			if !otherRevoked {
+				t.Error("access token of another session still valid")
+			}
+			if _, err := svc.Refresh(ctx, other.RefreshToken); err == nil {
+				t.Error("refresh token of another session still valid")
+			}
+
+			// while the returned one keeps working
+			claims, err := svc.issuer.Verify(resp.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != user.ID.String() {
+				t.Errorf("session subject = %s, want %s", claims.Subject, user.ID)
+			}
+			revoked, err := svc.IsTokenRevoked(ctx, claims.ID, user.ID, claims.IssueTime())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked {
+				t.Error("access token of the returned session revoked")
+			}
+			if _, err := svc.Refresh(ctx, resp.RefreshToken); err != nil {
+				t.Errorf("refresh token of the returned session: %v", err)
+			}
+		})
+	}
+}
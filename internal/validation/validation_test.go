@@ -0,0 +1,162 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City    string `json:"city" validate:"required"`
+	Country string `json:"country" validate:"len=2"`
+}
+
+type item struct {
+	Name string `json:"name" validate:"required,max=5"`
+}
+
+type request struct {
+	Email    string   `json:"email" validate:"required,email,max=255"`
+	Name     string   `json:"name,omitempty" validate:"min=2,max=10"`
+	Role     string   `json:"role" validate:"omitempty,oneof=admin user"`
+	ID       string   `json:"id" validate:"omitempty,uuid"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Untagged string   `validate:"required"`
+	Hidden   string   `json:"-" validate:"required"`
+	Home     address  `json:"home"`
+	Items    []item   `json:"items" validate:"dive"`
+}
+
+// valid returns a request passing every rule, for cases to break.
+func valid() request {
+	return request{
+		Email:    "user@example.com",
+		Name:     "User",
+		Untagged: "x",
+		Hidden:   "x",
+		Home:     address{City: "Oslo", Country: "NO"},
+		Items:    []item{{Name: "a"}},
+	}
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(r *request)
+		want   []FieldError
+	}{
+		{"valid", func(*request) {}, nil},
+		{
+			name:   "missing required field",
+			change: func(r *request) { r.Email = "" },
+			want:   []FieldError{{Field: "email", Rule: "required", Message: "is required"}},
+		},
+		{
+			name:   "malformed email",
+			change: func(r *request) { r.Email = "not-an-email" },
+			want:   []FieldError{{Field: "email", Rule: "email", Message: "must be a valid email address"}},
+		},
+		{
+			name:   "string too long",
+			change: func(r *request) { r.Email = strings.Repeat("a", 250) + "@example.com" },
+			want:   []FieldError{{Field: "email", Rule: "max", Param: "255", Message: "must be at most 255 characters"}},
+		},
+		{
+			name:   "string too short, named by its JSON tag without options",
+			change: func(r *request) { r.Name = "U" },
+			want:   []FieldError{{Field: "name", Rule: "min", Param: "2", Message: "must be at least 2 characters"}},
+		},
+		{
+			name:   "too many items",
+			change: func(r *request) { r.Tags = []string{"a", "b", "c"} },
+			want:   []FieldError{{Field: "tags", Rule: "max", Param: "2", Message: "must be at most 2 items"}},
+		},
+		{
+			name:   "not one of",
+			change: func(r *request) { r.Role = "root" },
+			want:   []FieldError{{Field: "role", Rule: "oneof", Param: "admin user", Message: "must be one of: admin, user"}},
+		},
+		{
+			name:   "not a UUID",
+			change: func(r *request) { r.ID = "42" },
+			want:   []FieldError{{Field: "id", Rule: "uuid", Message: "must be a UUID"}},
+		},
+		{
+			name:   "field without a JSON tag keeps its Go name",
+			change: func(r *request) { r.Untagged = "" },
+			want:   []FieldError{{Field: "Untagged", Rule: "required", Message: "is required"}},
+		},
+		{
+			name:   "nested struct",
+			change: func(r *request) { r.Home = address{Country: "NOR"} },
+			want: []FieldError{
+				{Field: "home.city", Rule: "required", Message: "is required"},
+				{Field: "home.country", Rule: "len", Param: "2", Message: "must be exactly 2 characters"},
+			},
+		},
+		{
+			name:   "nested slice element",
+			change: func(r *request) { r.Items = []item{{Name: "ok"}, {Name: "too long"}} },
+			want:   []FieldError{{Field: "items[1].name", Rule: "max", Param: "5", Message: "must be at most 5 characters"}},
+		},
+		{
+			name: "every violation at once",
+			change: func(r *request) {
+				r.Email = ""
+				r.Name = "U"
+			},
+			want: []FieldError{
+				{Field: "email", Rule: "required", Message: "is required"},
+				{Field: "name", Rule: "min", Param: "2", Message: "must be at least 2 characters"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.change(&r)
+
+			err := Struct(r)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Struct = %v, want nil", err)
+				}
+				return
+			}
+
+			var verr *Error
+			if !errors.As(err, &verr) {
+				t.Fatalf("Struct = %v, want an *Error", err)
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Error("error does not wrap ErrValidation")
+			}
+			if !reflect.DeepEqual(verr.Fields, tt.want) {
+				t.Errorf("fields = %+v, want %+v", verr.Fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorMessageListsEveryField(t *testing.T) {
+	err := &Error{Fields: []FieldError{
+		{Field: "email", Message: "is required"},
+		{Field: "name", Message: "must be at least 2 characters"},
+	}}
+
+	want := "validation failed: email: is required; name: must be at least 2 characters"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestStructRejectsNonStructs(t *testing.T) {
+	var nilRequest *request
+
+	err := Struct(nilRequest)
+	var verr *Error
+	if err == nil || errors.As(err, &verr) {
+		t.Errorf("Struct(nil) = %v, want an error that is not an *Error", err)
+	}
+}
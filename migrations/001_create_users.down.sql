@@ -0,0 +1,7 @@
+-- 001_create_users.down.sql
+-- Drop the users table
+
+DROP TABLE IF EXISTS users;
+DROP FUNCTION IF EXISTS update_updated_at_column();
+
+-- The extensions are left installed; other schemas may rely on them.
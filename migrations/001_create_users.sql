@@ -1,7 +1,13 @@
 -- 001_create_users.sql
 -- User management table with standard fields
+--
+-- Every statement is guarded, so that databases created before the
+-- migration runner can be brought under it. The version that created the
+-- trigger unguarded is accepted as applied.
+-- migrate:replaces 67c692ddca3b27617434427950bec731a5bca56212783b936b3092f3e247cd2e
 
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+CREATE EXTENSION IF NOT EXISTS pg_trgm; -- gin_trgm_ops for name search
 
 CREATE TABLE IF NOT EXISTS users (
     id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -29,6 +35,7 @@ BEGIN
 END;
 $$ language 'plpgsql';
 
+DROP TRIGGER IF EXISTS update_users_updated_at ON users;
 CREATE TRIGGER update_users_updated_at
     BEFORE UPDATE ON users
     FOR EACH ROW
@@ -0,0 +1,4 @@
+-- 002_create_refresh_tokens.down.sql
+-- Drop refresh tokens
+
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,5 @@
+-- 003_create_token_revocations.down.sql
+-- Drop the token denylists
+
+DROP TABLE IF EXISTS user_token_revocations;
+DROP TABLE IF EXISTS revoked_tokens;
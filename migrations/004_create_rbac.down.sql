@@ -0,0 +1,9 @@
+-- 004_create_rbac.down.sql
+-- Drop roles and permissions; users.role stays as plain text
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS fk_users_role;
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
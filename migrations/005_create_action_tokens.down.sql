@@ -0,0 +1,4 @@
+-- 005_create_action_tokens.down.sql
+-- Drop single-use action tokens
+
+DROP TABLE IF EXISTS action_tokens;
@@ -0,0 +1,15 @@
+-- 005_create_action_tokens.sql
+-- Single-use tokens for account actions such as password resets
+
+CREATE TABLE IF NOT EXISTS action_tokens (
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id    UUID         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose    VARCHAR(32)  NOT NULL,
+    token_hash VARCHAR(64)  NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ  NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_action_tokens_user_purpose ON action_tokens (user_id, purpose);
+CREATE INDEX IF NOT EXISTS idx_action_tokens_expires_at ON action_tokens (expires_at);
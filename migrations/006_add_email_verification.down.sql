@@ -0,0 +1,4 @@
+-- 006_add_email_verification.down.sql
+-- Forget email verification state
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,8 @@
+-- 006_add_email_verification.sql
+-- Track when a user proved ownership of their email address
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+-- Accounts created before verification existed are trusted as they are,
+-- so that turning on REQUIRE_EMAIL_VERIFICATION does not lock them out.
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
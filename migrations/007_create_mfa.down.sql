@@ -0,0 +1,5 @@
+-- 007_create_mfa.down.sql
+-- Drop TOTP secrets and recovery codes
+
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
@@ -0,0 +1,4 @@
+-- 008_create_linked_identities.down.sql
+-- Drop linked external identities
+
+DROP TABLE IF EXISTS linked_identities;
@@ -0,0 +1,4 @@
+-- 009_create_api_keys.down.sql
+-- Drop API keys
+
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,7 @@
+-- 010_case_insensitive_email.down.sql
+-- Restore case-sensitive email uniqueness; normalized addresses are kept
+
+DROP INDEX IF EXISTS idx_users_email_lower;
+
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+CREATE INDEX IF NOT EXISTS idx_users_email ON users (email);
@@ -0,0 +1,51 @@
+-- 010_case_insensitive_email.sql
+-- Make email uniqueness case-insensitive and store addresses normalized
+
+-- Refuse to run while accounts differ only by case or surrounding space.
+-- Such accounts have to be merged or renamed by hand first; the error lists
+-- every group of colliding accounts. To inspect them beforehand:
+--
+--   SELECT lower(btrim(email)) AS email, array_agg(id ORDER BY created_at)
+--   FROM users GROUP BY 1 HAVING count(*) > 1;
+DO $$
+DECLARE
+    collisions TEXT;
+BEGIN
+    SELECT string_agg(format('%s: %s', email, ids), E'\n')
+    INTO collisions
+    FROM (
+        SELECT lower(btrim(email)) AS email,
+               string_agg(id::text, ', ' ORDER BY created_at) AS ids
+        FROM users
+        GROUP BY 1
+        HAVING count(*) > 1
+    ) dup;
+
+    IF collisions IS NOT NULL THEN
+        RAISE EXCEPTION E'users share an email address ignoring case:\n%', collisions
+            USING HINT = 'Merge or rename these accounts, then rerun the migration.';
+    END IF;
+END $$;
+
+-- Internationalized domains cannot be converted to punycode in SQL. The
+-- application stores new addresses that way, so list old ones for review.
+DO $$
+DECLARE
+    non_ascii TEXT;
+BEGIN
+    SELECT string_agg(format('%s: %s', id, email), E'\n')
+    INTO non_ascii
+    FROM users
+    WHERE email !~ '^[[:ascii:]]*$';
+
+    IF non_ascii IS NOT NULL THEN
+        RAISE NOTICE E'emails with non-ASCII characters are not fully normalized:\n%', non_ascii;
+    END IF;
+END $$;
+
+UPDATE users SET email = lower(btrim(email)) WHERE email <> lower(btrim(email));
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+DROP INDEX IF EXISTS idx_users_email;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
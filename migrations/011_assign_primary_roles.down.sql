@@ -0,0 +1,3 @@
+-- 011_assign_primary_roles.down.sql
+-- Nothing to undo: the backfilled assignments match users.role, which
+-- granted the same permissions before this migration.
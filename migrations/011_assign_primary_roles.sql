@@ -0,0 +1,8 @@
+-- 011_assign_primary_roles.sql
+-- Permissions now come from role assignments only, so make sure every
+-- account has its primary role assigned. Accounts registered since 004
+-- only held it in users.role.
+
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u JOIN roles r ON r.name = u.role
+ON CONFLICT DO NOTHING;
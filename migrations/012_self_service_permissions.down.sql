@@ -0,0 +1,4 @@
+-- 012_self_service_permissions.down.sql
+-- Drop me:read; keys scoped to it lose it with the grant
+
+DELETE FROM permissions WHERE name = 'me:read';
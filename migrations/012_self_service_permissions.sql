@@ -0,0 +1,12 @@
+-- 012_self_service_permissions.sql
+-- Let every account create API keys for its own use: me:read is granted to
+-- the built-in roles, so that their holders can scope a key to it.
+
+INSERT INTO permissions (name, description) VALUES
+    ('me:read', 'Read your own account')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+WHERE r.name IN ('user', 'admin') AND p.name = 'me:read'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,13 @@
+// Package migrations embeds the SQL schema migrations into the binary, so the
+// server can apply them without the files being shipped alongside it.
+//
+// Each migration is a pair of files named NNN_description.sql and
+// NNN_description.down.sql, where NNN is its version.
+package migrations
+
+import "embed"
+
+// FS holds every migration file.
+//
+//go:embed *.sql
+var FS embed.FS